@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"gorm.io/gorm"
+)
+
+// expireAnonymousCarts deletes the anonymous carts nobody touched for ttl
+// every interval until the context is cancelled
+func expireAnonymousCarts(ctx context.Context, db *gorm.DB, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := database.DeleteExpiredAnonymousCarts(db.WithContext(ctx), time.Now().Add(-ttl))
+			if err != nil {
+				log.Printf("Anonymous cart cleanup failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Deleted %d expired anonymous carts", n)
+			}
+		}
+	}
+}
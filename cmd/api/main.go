@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/inventory"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
+	"github.com/alzarasatken/FullStackTest/pkg/payments"
 	"github.com/alzarasatken/FullStackTest/pkg/router"
+	"github.com/alzarasatken/FullStackTest/pkg/shipping"
+	"github.com/alzarasatken/FullStackTest/pkg/utils"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -16,13 +25,68 @@ func main() {
 	}
 
 	// Initialize database
-	_, err := database.InitDB()
+	db, err := database.InitDB()
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if os.Getenv("JWT_SECRET") == "" {
+		log.Fatalf("JWT_SECRET must be set")
+	}
+	// Anyone knowing the secret can mark orders paid through the webhook
+	webhookSecret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		log.Fatalf("PAYMENT_WEBHOOK_SECRET must be set")
+	}
+
+	// Apply pending migrations unless they are run as a separate deploy step
+	if os.Getenv("DB_AUTO_MIGRATE") != "false" {
+		if err := runMigrate(db, []string{"up"}); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
+	// The fake provider is the only one so far; it takes payments in-process
+	// and refunds the returns and cancelled orders paid through it. Every
+	// status change, by the API, 1C or the background jobs, goes through the
+	// same machine so cancelled orders are refunded whoever cancels them.
+	paymentProvider := payments.NewFakeProvider(webhookSecret)
+	machine := fsm.NewOrderMachine(fsm.WithRefunds(paymentProvider))
+
+	// Cancel pending orders whose stock reservation expired
+	sweepInterval := utils.DurationFromEnv("STOCK_SWEEP_INTERVAL", time.Minute)
+	sweeper := inventory.NewSweeper(db, sweepInterval, machine.CancelExpired)
+	go sweeper.Run(context.Background())
+
+	// Delete anonymous carts abandoned for longer than CART_ANONYMOUS_TTL
+	cartTTL := utils.DurationFromEnv("CART_ANONYMOUS_TTL", 30*24*time.Hour)
+	go expireAnonymousCarts(context.Background(), db, cartTTL, time.Hour)
+
+	// Deliver shipped orders once the carrier reports their parcels arrived
+	pollInterval := utils.DurationFromEnv("SHIPPING_POLL_INTERVAL", 5*time.Minute)
+	transit := utils.DurationFromEnv("SHIPPING_FAKE_TRANSIT", shipping.DefaultFakeTransit)
+	poller := shipping.NewPoller(db, shipping.NewFakeCarrier(transit), pollInterval,
+		func(tx *gorm.DB, order *models.Order, status models.OrderStatus) error {
+			return machine.Fire(tx, order, status, models.SystemActor, "delivered by carrier")
+		})
+	go poller.Run(context.Background())
+
 	// Setup router
-	r := router.SetupRouter()
+	r := router.SetupRouter(paymentProvider, machine)
+
+	// Exchange products and orders with 1C when configured
+	stopOneC, err := startOneC(context.Background(), db, r, machine)
+	if err != nil {
+		log.Fatalf("Failed to start 1C integration: %v", err)
+	}
+	defer stopOneC()
 
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
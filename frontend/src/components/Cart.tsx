@@ -32,6 +32,7 @@ import {
 import { FaTrash } from 'react-icons/fa'
 import { useCart } from '../context/CartContext'
 import { createOrder } from '../services/api'
+import { formatMoney, formatCents } from '../utils/money'
 
 export const Cart = () => {
   const navigate = useNavigate()
@@ -144,7 +145,7 @@ export const Cart = () => {
                         SKU: {item.product.sku}
                       </Text>
                     </Td>
-                    <Td>${item.product.price.toFixed(2)}</Td>
+                    <Td>{formatMoney(item.product.price)}</Td>
                     <Td>
                       <NumberInput
                         size="sm"
@@ -164,7 +165,7 @@ export const Cart = () => {
                       </NumberInput>
                     </Td>
                     <Td isNumeric>
-                      ${(item.product.price * item.quantity).toFixed(2)}
+                      {formatMoney(item.product.price, item.quantity)}
                     </Td>
                     <Td>
                       <IconButton
@@ -191,7 +192,7 @@ export const Cart = () => {
                   Subtotal
                 </Text>
                 <Text fontSize="lg" fontWeight="medium">
-                  ${state.total.toFixed(2)}
+                  {formatCents(state.total)}
                 </Text>
               </Stack>
               <Divider />
@@ -200,7 +201,7 @@ export const Cart = () => {
                   Total
                 </Text>
                 <Text fontSize="xl" fontWeight="bold">
-                  ${state.total.toFixed(2)}
+                  {formatCents(state.total)}
                 </Text>
               </Stack>
               <Stack direction="row" spacing={4} justify="flex-end">
@@ -33,6 +33,7 @@ import {
 } from '@chakra-ui/react'
 import { format } from 'date-fns'
 import { getOrder, updateOrderStatus, cancelOrder, Order, OrderStatus } from '../services/api'
+import { formatMoney } from '../utils/money'
 
 const statusColors = {
   pending: 'yellow',
@@ -180,7 +181,7 @@ export const OrderDetails = () => {
               <Stack spacing={4}>
                 <Stat>
                   <StatLabel>Total Amount</StatLabel>
-                  <StatNumber>${order.total.toFixed(2)}</StatNumber>
+                  <StatNumber>{formatMoney(order.total)}</StatNumber>
                   <StatHelpText>{order.items.length} items</StatHelpText>
                 </Stat>
               </Stack>
@@ -234,9 +235,9 @@ export const OrderDetails = () => {
                   <Tr key={item.id}>
                     <Td>{item.product.name}</Td>
                     <Td>{item.product.sku}</Td>
-                    <Td isNumeric>${item.price.toFixed(2)}</Td>
+                    <Td isNumeric>{formatMoney(item.price)}</Td>
                     <Td isNumeric>{item.quantity}</Td>
-                    <Td isNumeric>${(item.price * item.quantity).toFixed(2)}</Td>
+                    <Td isNumeric>{formatMoney(item.price, item.quantity)}</Td>
                   </Tr>
                 ))}
               </Tbody>
@@ -26,6 +26,7 @@ import { FaEye } from 'react-icons/fa'
 import { format } from 'date-fns'
 import { Link } from 'react-router-dom'
 import { getOrders, updateOrderStatus, Order, OrderStatus } from '../services/api'
+import { formatMoney } from '../utils/money'
 
 const statusColors = {
   pending: 'yellow',
@@ -133,7 +134,7 @@ export const OrderList = () => {
                   <Td>#{order.id}</Td>
                   <Td>{format(new Date(order.created_at), 'MMM d, yyyy')}</Td>
                   <Td>{order.user.name}</Td>
-                  <Td>${order.total.toFixed(2)}</Td>
+                  <Td>{formatMoney(order.total)}</Td>
                   <Td>
                     <Select
                       size="sm"
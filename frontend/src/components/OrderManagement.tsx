@@ -31,6 +31,7 @@ import {
 } from '@chakra-ui/react';
 import { format } from 'date-fns';
 import { Order, OrderStatus, OrderSearchParams } from '../types';
+import { formatMoney } from '../utils/money';
 
 const statusColors = {
   [OrderStatus.Pending]: 'yellow',
@@ -170,7 +171,7 @@ export const OrderManagement: React.FC = () => {
                     {order.status}
                   </Badge>
                 </Td>
-                <Td isNumeric>${order.total.toFixed(2)}</Td>
+                <Td isNumeric>{formatMoney(order.total)}</Td>
                 <Td>
                   <HStack spacing={2}>
                     <Button
@@ -253,7 +254,7 @@ export const OrderManagement: React.FC = () => {
                   <Heading size="sm">Order Details</Heading>
                   <Text>Date: {format(new Date(selectedOrder.created_at), 'PPp')}</Text>
                   <Text>Status: <Badge colorScheme={statusColors[selectedOrder.status]}>{selectedOrder.status}</Badge></Text>
-                  <Text>Total: ${selectedOrder.total.toFixed(2)}</Text>
+                  <Text>Total: {formatMoney(selectedOrder.total)}</Text>
                 </Box>
 
                 <Divider />
@@ -278,7 +279,7 @@ export const OrderManagement: React.FC = () => {
                               Quantity: {item.quantity}
                             </Text>
                           </VStack>
-                          <Text>${(item.price * item.quantity).toFixed(2)}</Text>
+                          <Text>{formatMoney(item.price, item.quantity)}</Text>
                         </HStack>
                       </ListItem>
                     ))}
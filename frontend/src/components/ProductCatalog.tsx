@@ -27,6 +27,7 @@ import {
 } from '@chakra-ui/react';
 import debounce from 'lodash/debounce';
 import { Product, ProductSearchParams } from '../types';
+import { formatMoney } from '../utils/money';
 
 const ITEMS_PER_PAGE = 12;
 
@@ -185,7 +186,7 @@ export const ProductCatalog: React.FC = () => {
                     <Text>{product.description}</Text>
                     <HStack justify="space-between">
                       <Text color="blue.600" fontSize="2xl">
-                        ${product.price}
+                        {formatMoney(product.price)}
                       </Text>
                       <Badge
                         colorScheme={product.stock > 0 ? 'green' : 'red'}
@@ -33,7 +33,7 @@ export const ProductDetails = () => {
   const [formData, setFormData] = useState<Partial<Product>>({
     name: '',
     description: '',
-    price: 0,
+    price: '0.00',
     sku: '',
     stock: 0
   })
@@ -145,8 +145,9 @@ export const ProductDetails = () => {
             <FormLabel>Price</FormLabel>
             <NumberInput
               min={0}
+              precision={2}
               value={formData.price}
-              onChange={(value) => handleInputChange('price', Number(value))}
+              onChange={(value) => handleInputChange('price', value)}
             >
               <NumberInputField />
               <NumberInputStepper>
@@ -33,6 +33,7 @@ import { FaEdit } from 'react-icons/fa'
 import { format } from 'date-fns'
 import { getProducts, updateStock, Product } from '../services/api'
 import { Link } from 'react-router-dom'
+import { formatMoney } from '../utils/money'
 
 export const ProductList = () => {
   const [search, setSearch] = useState('')
@@ -161,7 +162,7 @@ export const ProductList = () => {
                 <Tr key={product.id}>
                   <Td>{product.name}</Td>
                   <Td>{product.sku}</Td>
-                  <Td>${product.price.toFixed(2)}</Td>
+                  <Td>{formatMoney(product.price)}</Td>
                   <Td>
                     <NumberInput
                       size="sm"
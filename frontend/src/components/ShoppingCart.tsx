@@ -21,6 +21,7 @@ import {
 } from '@chakra-ui/react';
 import { FaTrash } from 'react-icons/fa';
 import { CartItem, Product } from '../types';
+import { formatMoney, formatCents, sumCents } from '../utils/money';
 
 interface CartItemProps {
   item: CartItem;
@@ -41,7 +42,7 @@ const CartItemComponent: React.FC<CartItemProps> = ({ item, onUpdateQuantity, on
       <VStack align="start" flex={1} spacing={1}>
         <Text fontWeight="medium">{item.product.name}</Text>
         <Text color="gray.600" fontSize="sm">
-          ${item.product.price.toFixed(2)} each
+          {formatMoney(item.product.price)} each
         </Text>
       </VStack>
       <NumberInput
@@ -59,7 +60,7 @@ const CartItemComponent: React.FC<CartItemProps> = ({ item, onUpdateQuantity, on
         </NumberInputStepper>
       </NumberInput>
       <Text fontWeight="medium" w="100px" textAlign="right">
-        ${(item.quantity * item.product.price).toFixed(2)}
+        {formatMoney(item.product.price, item.quantity)}
       </Text>
       <IconButton
         aria-label="Remove item"
@@ -211,10 +212,11 @@ export const ShoppingCart: React.FC = () => {
     );
   }
 
-  const total = cart?.items.reduce(
-    (sum: number, item: CartItem) => sum + item.quantity * item.product.price,
-    0
-  ) || 0;
+  const total = sumCents(
+    cart?.items || [],
+    (item: CartItem) => item.product.price,
+    (item: CartItem) => item.quantity
+  );
 
   return (
     <Box p={4}>
@@ -239,7 +241,7 @@ export const ShoppingCart: React.FC = () => {
                 Total
               </Text>
               <Text fontSize="lg" fontWeight="bold">
-                ${total.toFixed(2)}
+                {formatCents(total)}
               </Text>
             </HStack>
             <Button
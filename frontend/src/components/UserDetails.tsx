@@ -27,6 +27,7 @@ import {
 } from '@chakra-ui/react';
 import { format } from 'date-fns';
 import { User, Order, OrderStatus } from '../types';
+import { formatMoney, formatCents, sumCents } from '../utils/money';
 
 const statusColors = {
   [OrderStatus.Pending]: 'yellow',
@@ -89,8 +90,8 @@ export const UserDetails: React.FC = () => {
   }
 
   const totalOrders = orders?.length || 0;
-  const totalSpent = orders?.reduce((sum, order) => sum + order.total, 0) || 0;
-  const averageOrderValue = totalOrders > 0 ? totalSpent / totalOrders : 0;
+  const totalSpent = sumCents(orders || [], (order) => order.total);
+  const averageOrderValue = totalOrders > 0 ? Math.round(totalSpent / totalOrders) : 0;
 
   return (
     <VStack spacing={6} align="stretch" p={4}>
@@ -110,11 +111,11 @@ export const UserDetails: React.FC = () => {
             </Stat>
             <Stat>
               <StatLabel>Total Spent</StatLabel>
-              <StatNumber>${totalSpent.toFixed(2)}</StatNumber>
+              <StatNumber>{formatCents(totalSpent)}</StatNumber>
             </Stat>
             <Stat>
               <StatLabel>Average Order</StatLabel>
-              <StatNumber>${averageOrderValue.toFixed(2)}</StatNumber>
+              <StatNumber>{formatCents(averageOrderValue)}</StatNumber>
             </Stat>
           </StatGroup>
         </CardBody>
@@ -143,7 +144,7 @@ export const UserDetails: React.FC = () => {
                       {order.status}
                     </Badge>
                   </Td>
-                  <Td isNumeric>${order.total.toFixed(2)}</Td>
+                  <Td isNumeric>{formatMoney(order.total)}</Td>
                   <Td>{order.items.length} items</Td>
                 </Tr>
               ))}
@@ -57,20 +57,20 @@ describe('OrderList Component', () => {
         id: 1, 
         user_id: 1,
         status: 'pending',
-        total: 199.98,
+        total: '199.98',
         created_at: today.toISOString(),
         items: [
-          { id: 1, product_id: 1, quantity: 2, price: 99.99, product: { name: 'Product 1' } }
+          { id: 1, product_id: 1, quantity: 2, price: '99.99', product: { name: 'Product 1' } }
         ]
       },
       {
         id: 2,
         user_id: 1,
         status: 'completed',
-        total: 149.99,
+        total: '149.99',
         created_at: today.toISOString(),
         items: [
-          { id: 2, product_id: 2, quantity: 1, price: 149.99, product: { name: 'Product 2' } }
+          { id: 2, product_id: 2, quantity: 1, price: '149.99', product: { name: 'Product 2' } }
         ]
       }
     ]
@@ -112,10 +112,10 @@ describe('OrderList Component', () => {
         id: 1,
         user_id: 1,
         status: 'pending',
-        total: 199.98,
+        total: '199.98',
         created_at: new Date().toISOString(),
         items: [
-          { id: 1, product_id: 1, quantity: 2, price: 99.99, product: { name: 'Product 1' } }
+          { id: 1, product_id: 1, quantity: 2, price: '99.99', product: { name: 'Product 1' } }
         ]
       }
     ]
@@ -149,10 +149,10 @@ describe('OrderList Component', () => {
         id: 1,
         user_id: 1,
         status: 'pending',
-        total: 199.98,
+        total: '199.98',
         created_at: new Date().toISOString(),
         items: [
-          { id: 1, product_id: 1, quantity: 2, price: 99.99, product: { name: 'Product 1' } }
+          { id: 1, product_id: 1, quantity: 2, price: '99.99', product: { name: 'Product 1' } }
         ]
       }
     ]
@@ -188,11 +188,11 @@ describe('OrderList Component', () => {
         id: 1,
         user_id: 1,
         status: 'pending',
-        total: 249.97,
+        total: '249.97',
         created_at: new Date().toISOString(),
         items: [
-          { id: 1, product_id: 1, quantity: 2, price: 99.99, product: { name: 'Product 1' } },
-          { id: 2, product_id: 2, quantity: 1, price: 49.99, product: { name: 'Product 2' } }
+          { id: 1, product_id: 1, quantity: 2, price: '99.99', product: { name: 'Product 1' } },
+          { id: 2, product_id: 2, quantity: 1, price: '49.99', product: { name: 'Product 2' } }
         ]
       }
     ]
@@ -248,10 +248,10 @@ describe('OrderList Component', () => {
         id: 1,
         user_id: 1,
         status: 'pending',
-        total: 99.99,
+        total: '99.99',
         created_at: new Date().toISOString(),
         items: [
-          { id: 1, product_id: 1, quantity: 1, price: 99.99, product: { name: 'Product 1' } }
+          { id: 1, product_id: 1, quantity: 1, price: '99.99', product: { name: 'Product 1' } }
         ]
       }],
       total: 1,
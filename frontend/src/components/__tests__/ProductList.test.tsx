@@ -51,8 +51,8 @@ describe('ProductList Component', () => {
   it('displays products when data is loaded', async () => {
     // Mock successful response
     const mockProducts = [
-      { id: 1, name: 'Product 1', price: 99.99, stock: 10, sku: 'SKU1', description: 'Description 1' },
-      { id: 2, name: 'Product 2', price: 149.99, stock: 5, sku: 'SKU2', description: 'Description 2' }
+      { id: 1, name: 'Product 1', price: '99.99', stock: 10, sku: 'SKU1', description: 'Description 1' },
+      { id: 2, name: 'Product 2', price: '149.99', stock: 5, sku: 'SKU2', description: 'Description 2' }
     ]
     
     vi.mocked(getProducts).mockResolvedValue({
@@ -87,7 +87,7 @@ describe('ProductList Component', () => {
   it('filters products by price range', async () => {
     // Mock filtered response
     const mockProducts = [
-      { id: 1, name: 'Product 1', price: 99.99, stock: 10, sku: 'SKU1', description: 'Description 1' }
+      { id: 1, name: 'Product 1', price: '99.99', stock: 10, sku: 'SKU1', description: 'Description 1' }
     ]
     
     vi.mocked(getProducts).mockResolvedValue({
@@ -119,7 +119,7 @@ describe('ProductList Component', () => {
     vi.mocked(updateStock).mockResolvedValue({ success: true })
     
     const mockProducts = [
-      { id: 1, name: 'Product 1', price: 99.99, stock: 10, sku: 'SKU1', description: 'Description 1' }
+      { id: 1, name: 'Product 1', price: '99.99', stock: 10, sku: 'SKU1', description: 'Description 1' }
     ]
     
     vi.mocked(getProducts).mockResolvedValue({
@@ -150,7 +150,7 @@ describe('ProductList Component', () => {
   it('displays out of stock badge', async () => {
     // Mock product with zero stock
     const mockProducts = [
-      { id: 1, name: 'Product 1', price: 99.99, stock: 0, sku: 'SKU1', description: 'Description 1' }
+      { id: 1, name: 'Product 1', price: '99.99', stock: 0, sku: 'SKU1', description: 'Description 1' }
     ]
     
     vi.mocked(getProducts).mockResolvedValue({
@@ -170,7 +170,7 @@ describe('ProductList Component', () => {
   it('handles search by SKU', async () => {
     // Mock filtered response
     const mockProducts = [
-      { id: 1, name: 'Product 1', price: 99.99, stock: 10, sku: 'SKU123', description: 'Description 1' }
+      { id: 1, name: 'Product 1', price: '99.99', stock: 10, sku: 'SKU123', description: 'Description 1' }
     ]
     
     vi.mocked(getProducts).mockResolvedValue({
@@ -222,7 +222,7 @@ describe('ProductList Component', () => {
     
     // Test retry functionality
     vi.mocked(getProducts).mockResolvedValueOnce({
-      data: [{ id: 1, name: 'Product 1', price: 99.99, stock: 10, sku: 'SKU1', description: 'Description 1' }],
+      data: [{ id: 1, name: 'Product 1', price: '99.99', stock: 10, sku: 'SKU1', description: 'Description 1' }],
       total: 1,
       page: 1,
       per_page: 10
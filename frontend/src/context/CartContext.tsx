@@ -1,5 +1,6 @@
 import { createContext, useContext, useReducer, ReactNode } from 'react'
 import { Product } from '../services/api'
+import { sumCents } from '../utils/money'
 
 interface CartItem {
   product: Product
@@ -8,6 +9,7 @@ interface CartItem {
 
 interface CartState {
   items: CartItem[]
+  // total is in integer cents
   total: number
 }
 
@@ -27,8 +29,9 @@ interface CartContextType {
 
 const CartContext = createContext<CartContextType | undefined>(undefined)
 
+// calculateTotal adds up the cart in integer cents
 const calculateTotal = (items: CartItem[]): number => {
-  return items.reduce((total, item) => total + item.product.price * item.quantity, 0)
+  return sumCents(items, (item) => item.product.price, (item) => item.quantity)
 }
 
 const cartReducer = (state: CartState, action: CartAction): CartState => {
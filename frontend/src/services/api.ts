@@ -1,4 +1,5 @@
 import axios from 'axios'
+import type { Money } from '../utils/money'
 
 const api = axios.create({
   baseURL: '/api'
@@ -16,7 +17,7 @@ export interface Product {
   id: number
   name: string
   description: string
-  price: number
+  price: Money
   sku: string
   stock: number
   created_at: string
@@ -29,7 +30,7 @@ export interface OrderItem {
   product_id: number
   product: Product
   quantity: number
-  price: number
+  price: Money
 }
 
 export type OrderStatus = 'pending' | 'processing' | 'shipped' | 'delivered' | 'cancelled'
@@ -39,7 +40,7 @@ export interface Order {
   user_id: number
   user: User
   status: OrderStatus
-  total: number
+  total: Money
   items: OrderItem[]
   created_at: string
   updated_at: string
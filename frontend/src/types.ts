@@ -1,3 +1,7 @@
+import type { Money } from './utils/money';
+
+export type { Money };
+
 // User related types
 export interface User {
   id: number;
@@ -6,7 +10,7 @@ export interface User {
   created_at: string;
   updated_at: string;
   orders_count?: number;
-  total_spent?: number;
+  total_spent?: Money;
 }
 
 export interface PaginatedResponse<T> {
@@ -22,7 +26,7 @@ export interface Order {
   id: number;
   user_id: number;
   status: OrderStatus;
-  total: number;
+  total: Money;
   items: OrderItem[];
   created_at: string;
   updated_at: string;
@@ -33,7 +37,7 @@ export interface OrderItem {
   order_id: number;
   product_id: number;
   quantity: number;
-  price: number;
+  price: Money;
   product?: Product;
 }
 
@@ -50,7 +54,7 @@ export interface Product {
   id: number;
   name: string;
   description: string;
-  price: number;
+  price: Money;
   sku: string;
   stock: number;
   category: string;
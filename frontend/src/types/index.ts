@@ -1,3 +1,7 @@
+import type { Money } from '../utils/money';
+
+export type { Money };
+
 export interface User {
   id: number;
   name: string;
@@ -5,20 +9,20 @@ export interface User {
   created_at: string;
   updated_at: string;
   orders_count?: number;
-  total_spent?: number;
+  total_spent?: Money;
 }
 
 export interface Product {
   id: number;
   name: string;
   description: string;
-  price: number;
+  price: Money;
   stock: number;
   image_url?: string;
   created_at: string;
   updated_at: string;
   sales_count?: number;
-  revenue?: number;
+  revenue?: Money;
 }
 
 export interface CartItem {
@@ -28,14 +32,14 @@ export interface CartItem {
 
 export interface Cart {
   items: CartItem[];
-  total: number;
+  total: Money;
 }
 
 export interface OrderItem {
   id: number;
   product: Product;
   quantity: number;
-  price: number;
+  price: Money;
 }
 
 export enum OrderStatus {
@@ -50,7 +54,7 @@ export interface Order {
   id: number;
   user: User;
   status: OrderStatus;
-  total: number;
+  total: Money;
   items: OrderItem[];
   created_at: string;
   updated_at: string;
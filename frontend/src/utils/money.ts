@@ -0,0 +1,29 @@
+// Money amounts come from the API as exact decimal strings such as "199.98".
+// They are added up in integer cents so that no floating point error creeps in.
+export type Money = string
+
+// toCents converts a decimal amount to integer cents
+export const toCents = (amount: Money): number => {
+  const [units, fraction = ''] = amount.trim().split('.')
+  const cents = Math.abs(Number(units || '0')) * 100 + Number(fraction.padEnd(2, '0').slice(0, 2))
+  return units.startsWith('-') ? -cents : cents
+}
+
+// fromCents converts integer cents back to a decimal amount
+export const fromCents = (cents: number): Money => {
+  const sign = cents < 0 ? '-' : ''
+  const abs = Math.abs(Math.round(cents))
+  return `${sign}${Math.floor(abs / 100)}.${String(abs % 100).padStart(2, '0')}`
+}
+
+// formatCents renders integer cents for display
+export const formatCents = (cents: number): string =>
+  cents < 0 ? `-$${fromCents(-cents)}` : `$${fromCents(cents)}`
+
+// formatMoney renders an amount, optionally multiplied by a quantity
+export const formatMoney = (amount: Money, quantity = 1): string =>
+  formatCents(toCents(amount) * quantity)
+
+// sumCents adds up amounts, each multiplied by its quantity, into cents
+export const sumCents = <T>(items: T[], amount: (item: T) => Money, quantity: (item: T) => number = () => 1): number =>
+  items.reduce((sum, item) => sum + toCents(amount(item)) * quantity(item), 0)
@@ -0,0 +1,4 @@
+-- The legacy integer owners cannot be restored; only the indexes are dropped.
+DROP INDEX IF EXISTS idx_active_orders;
+DROP INDEX IF EXISTS idx_orders_user_created;
+DROP INDEX IF EXISTS idx_order_user;
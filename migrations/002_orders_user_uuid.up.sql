@@ -0,0 +1,42 @@
+-- Convert orders.user_id from the legacy integer column to the UUID primary
+-- key of users. This is a no-op on databases created by 001.
+--
+-- Legacy integer IDs cannot be derived from the UUIDs, so the mapping must be
+-- supplied by the operator in a legacy_user_ids (legacy_id BIGINT, user_id
+-- UUID) table. If any order cannot be mapped the migration fails and is rolled
+-- back, instead of silently attaching orders to the wrong customer.
+DO $$
+DECLARE
+    unmapped BIGINT;
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'orders' AND column_name = 'user_id' AND data_type <> 'uuid'
+    ) THEN
+        RETURN;
+    END IF;
+
+    ALTER TABLE orders RENAME COLUMN user_id TO legacy_user_id;
+    ALTER TABLE orders ALTER COLUMN legacy_user_id DROP NOT NULL;
+    ALTER TABLE orders ADD COLUMN user_id UUID;
+
+    IF to_regclass('legacy_user_ids') IS NOT NULL THEN
+        EXECUTE 'UPDATE orders o SET user_id = m.user_id
+            FROM legacy_user_ids m
+            WHERE o.legacy_user_id = m.legacy_id';
+    END IF;
+
+    SELECT COUNT(*) INTO unmapped FROM orders WHERE user_id IS NULL;
+    IF unmapped > 0 THEN
+        RAISE EXCEPTION '% orders have a legacy user_id without an entry in legacy_user_ids', unmapped;
+    END IF;
+
+    ALTER TABLE orders ALTER COLUMN user_id SET NOT NULL;
+    ALTER TABLE orders ADD CONSTRAINT fk_orders_user FOREIGN KEY (user_id) REFERENCES users (id);
+END $$;
+
+-- Indexes on user_id, (re)created after the column swap
+CREATE INDEX IF NOT EXISTS idx_order_user ON orders (user_id);
+CREATE INDEX IF NOT EXISTS idx_orders_user_created ON orders (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_active_orders ON orders (user_id, created_at DESC)
+    WHERE status NOT IN ('delivered', 'cancelled');
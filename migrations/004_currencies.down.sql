@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS exchange_rates;
+ALTER TABLE order_items DROP COLUMN IF EXISTS base_price;
+ALTER TABLE orders DROP COLUMN IF EXISTS exchange_rate;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE products DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,21 @@
+-- Currencies on products and orders, plus exchange rate history
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange_rate NUMERIC(18,6) NOT NULL DEFAULT 1;
+
+-- Existing orders were placed in the base currency, so the base price is the price
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS base_price DECIMAL(10,2);
+UPDATE order_items SET base_price = price WHERE base_price IS NULL;
+ALTER TABLE order_items ALTER COLUMN base_price SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id BIGSERIAL PRIMARY KEY,
+    currency VARCHAR(3) NOT NULL,
+    rate NUMERIC(18,6) NOT NULL,
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_exchange_rates_rate CHECK (rate > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_rate_currency_effective ON exchange_rates (currency, effective_at DESC);
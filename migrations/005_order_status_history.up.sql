@@ -0,0 +1,20 @@
+-- Audit trail of order status transitions
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    actor_type VARCHAR(10) NOT NULL,
+    actor_id UUID,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_order_status_history_actor CHECK (actor_type IN ('user', 'admin', '1c', 'system'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON order_status_history (order_id, created_at);
+
+-- Existing orders get a single entry for the status they are in today
+INSERT INTO order_status_history (order_id, from_status, to_status, actor_type, reason, created_at)
+SELECT id, NULL, status, 'system', 'status recorded when history was introduced', COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)
+FROM orders
+WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_id = orders.id);
@@ -0,0 +1,21 @@
+-- Stock held by pending orders. products.stock stays the quantity on hand;
+-- stock - reserved is what can still be ordered.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reserved BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD CONSTRAINT chk_products_reserved CHECK (reserved >= 0);
+
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    quantity BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_stock_reservations_quantity CHECK (quantity > 0),
+    CONSTRAINT chk_stock_reservations_status CHECK (status IN ('active', 'committed', 'released'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reservation_order ON stock_reservations (order_id);
+-- Lets the sweeper find expired reservations without scanning settled ones
+CREATE INDEX IF NOT EXISTS idx_stock_reservation_expiry ON stock_reservations (expires_at) WHERE status = 'active';
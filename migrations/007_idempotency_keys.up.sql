@@ -0,0 +1,18 @@
+-- Responses of mutating requests sent with an Idempotency-Key header, kept
+-- so retries are answered without running the request again
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id BIGSERIAL PRIMARY KEY,
+    scope VARCHAR(64) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'processing',
+    response_code INTEGER,
+    response_body BYTEA,
+    content_type VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT chk_idempotency_keys_status CHECK (status IN ('processing', 'completed'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_key_scope ON idempotency_keys (scope, key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_key_expiry ON idempotency_keys (expires_at);
@@ -0,0 +1,25 @@
+-- Server-side shopping carts. A cart belongs to a user or, before login, to
+-- the anonymous visitor holding its token.
+CREATE TABLE IF NOT EXISTS carts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users (id) ON DELETE CASCADE,
+    token UUID,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_carts_owner CHECK ((user_id IS NULL) <> (token IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_user ON carts (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_token ON carts (token);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id BIGSERIAL PRIMARY KEY,
+    cart_id BIGINT NOT NULL REFERENCES carts (id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products (id),
+    quantity BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_cart_items_quantity CHECK (quantity > 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_item_product ON cart_items (cart_id, product_id);
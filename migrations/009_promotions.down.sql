@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS order_adjustments;
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotion_targets;
+DROP TABLE IF EXISTS promotions;
+ALTER TABLE orders DROP COLUMN IF EXISTS subtotal;
+DROP INDEX IF EXISTS idx_product_category;
+ALTER TABLE products DROP COLUMN IF EXISTS category;
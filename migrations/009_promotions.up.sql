@@ -0,0 +1,70 @@
+-- Product categories, used by category promotions
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category VARCHAR(100) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_product_category ON products (category);
+
+-- Orders keep the sum of their items next to the adjusted total
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS subtotal DECIMAL(10,2);
+UPDATE orders SET subtotal = total WHERE subtotal IS NULL;
+ALTER TABLE orders ALTER COLUMN subtotal SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS promotions (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    code VARCHAR(64),
+    type VARCHAR(20) NOT NULL,
+    percent INTEGER NOT NULL DEFAULT 0,
+    amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    buy_quantity INTEGER NOT NULL DEFAULT 0,
+    get_quantity INTEGER NOT NULL DEFAULT 0,
+    min_basket DECIMAL(10,2) NOT NULL DEFAULT 0,
+    usage_limit INTEGER,
+    per_user_limit INTEGER,
+    usage_count INTEGER NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_promotions_type CHECK (type IN ('percentage', 'fixed_amount', 'buy_x_get_y')),
+    CONSTRAINT chk_promotions_percent CHECK (percent BETWEEN 0 AND 100),
+    CONSTRAINT chk_promotions_usage CHECK (usage_count >= 0)
+);
+
+-- Coupon codes are stored upper case and matched case-insensitively
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promotion_code ON promotions (code);
+
+CREATE TABLE IF NOT EXISTS promotion_targets (
+    id BIGSERIAL PRIMARY KEY,
+    promotion_id BIGINT NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    CONSTRAINT chk_promotion_targets_kind CHECK (kind IN ('sku', 'category'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_target_promotion ON promotion_targets (promotion_id);
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id BIGSERIAL PRIMARY KEY,
+    promotion_id BIGINT NOT NULL REFERENCES promotions (id),
+    user_id UUID NOT NULL,
+    order_id BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_redemption_user ON promotion_redemptions (promotion_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_promotion_redemption_order ON promotion_redemptions (order_id);
+
+-- Discounts and other lines applied on top of an order's items
+CREATE TABLE IF NOT EXISTS order_adjustments (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    promotion_id BIGINT REFERENCES promotions (id),
+    product_id BIGINT REFERENCES products (id),
+    code VARCHAR(64),
+    description VARCHAR(255),
+    amount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_adjustment_order ON order_adjustments (order_id);
@@ -0,0 +1,10 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS gross;
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax;
+ALTER TABLE order_items DROP COLUMN IF EXISTS net;
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_percent;
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_class;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_total;
+ALTER TABLE orders DROP COLUMN IF EXISTS net_total;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_region;
+ALTER TABLE products DROP COLUMN IF EXISTS tax_class;
+DROP TABLE IF EXISTS tax_rates;
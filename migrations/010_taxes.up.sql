@@ -0,0 +1,38 @@
+-- VAT rates per region and tax class, in whole per cent
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id BIGSERIAL PRIMARY KEY,
+    region VARCHAR(10) NOT NULL,
+    tax_class VARCHAR(50) NOT NULL,
+    percent INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_tax_rates_percent CHECK (percent BETWEEN 0 AND 100)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tax_rate_region_class ON tax_rates (region, tax_class);
+
+INSERT INTO tax_rates (region, tax_class, percent, created_at, updated_at) VALUES
+    ('RU', 'standard', 22, NOW(), NOW()),
+    ('RU', 'reduced', 10, NOW(), NOW()),
+    ('RU', 'zero', 0, NOW(), NOW())
+ON CONFLICT (region, tax_class) DO NOTHING;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_class VARCHAR(50) NOT NULL DEFAULT 'standard';
+
+-- Orders placed before taxes were tracked keep an empty region and are
+-- recorded as untaxed: net equals gross
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_region VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS net_total DECIMAL(10,2);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_total DECIMAL(10,2) NOT NULL DEFAULT 0;
+UPDATE orders SET net_total = total WHERE net_total IS NULL;
+ALTER TABLE orders ALTER COLUMN net_total SET NOT NULL;
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_class VARCHAR(50) NOT NULL DEFAULT 'standard';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_percent INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS net DECIMAL(10,2);
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS gross DECIMAL(10,2);
+UPDATE order_items SET gross = price * quantity WHERE gross IS NULL;
+UPDATE order_items SET net = gross WHERE net IS NULL;
+ALTER TABLE order_items ALTER COLUMN gross SET NOT NULL;
+ALTER TABLE order_items ALTER COLUMN net SET NOT NULL;
@@ -0,0 +1,5 @@
+UPDATE order_status_history SET actor_type = 'system' WHERE actor_type = 'payment';
+ALTER TABLE order_status_history DROP CONSTRAINT IF EXISTS chk_order_status_history_actor;
+ALTER TABLE order_status_history ADD CONSTRAINT chk_order_status_history_actor
+    CHECK (actor_type IN ('user', 'admin', '1c', 'system'));
+DROP TABLE IF EXISTS payments;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS payments (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    intent_id VARCHAR(100) NOT NULL,
+    client_secret VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    amount DECIMAL(10,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    refunded_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_payments_status CHECK (status IN ('pending', 'succeeded', 'failed', 'partially_refunded', 'refunded')),
+    CONSTRAINT chk_payments_refunded CHECK (refunded_amount >= 0 AND refunded_amount <= amount)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_intent ON payments (intent_id);
+CREATE INDEX IF NOT EXISTS idx_payment_order ON payments (order_id);
+
+-- Captured payments mark orders paid as the payment actor
+ALTER TABLE order_status_history DROP CONSTRAINT IF EXISTS chk_order_status_history_actor;
+ALTER TABLE order_status_history ADD CONSTRAINT chk_order_status_history_actor
+    CHECK (actor_type IN ('user', 'admin', '1c', 'system', 'payment'));
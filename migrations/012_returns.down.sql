@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS refunds;
+DROP TABLE IF EXISTS return_items;
+DROP TABLE IF EXISTS returns;
+UPDATE orders SET status = 'delivered' WHERE status IN ('partially_refunded', 'refunded');
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS chk_orders_status;
+ALTER TABLE orders ADD CONSTRAINT chk_orders_status
+    CHECK (status IN ('pending', 'paid', 'shipped', 'delivered', 'cancelled'));
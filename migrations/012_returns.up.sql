@@ -0,0 +1,50 @@
+-- Delivered orders can be partially or fully refunded through returns
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS chk_orders_status;
+ALTER TABLE orders ADD CONSTRAINT chk_orders_status
+    CHECK (status IN ('pending', 'paid', 'shipped', 'delivered', 'cancelled', 'partially_refunded', 'refunded'));
+
+CREATE TABLE IF NOT EXISTS returns (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id),
+    status VARCHAR(20) NOT NULL DEFAULT 'requested',
+    reason TEXT NOT NULL,
+    resolution TEXT,
+    restocked BOOLEAN NOT NULL DEFAULT FALSE,
+    synced BOOLEAN NOT NULL DEFAULT FALSE,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_returns_status CHECK (status IN ('requested', 'approved', 'rejected', 'completed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_return_order ON returns (order_id);
+CREATE INDEX IF NOT EXISTS idx_return_status ON returns (status);
+
+CREATE TABLE IF NOT EXISTS return_items (
+    id BIGSERIAL PRIMARY KEY,
+    return_id BIGINT NOT NULL REFERENCES returns (id) ON DELETE CASCADE,
+    order_item_id BIGINT NOT NULL REFERENCES order_items (id),
+    product_id BIGINT NOT NULL,
+    quantity INTEGER NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    CONSTRAINT chk_return_items_quantity CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_return_item_return ON return_items (return_id);
+CREATE INDEX IF NOT EXISTS idx_return_item_order_item ON return_items (order_item_id);
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    return_id BIGINT REFERENCES returns (id),
+    payment_id BIGINT REFERENCES payments (id),
+    amount DECIMAL(10,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    provider_reference VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_refunds_amount CHECK (amount > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refund_order ON refunds (order_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refund_return ON refunds (return_id);
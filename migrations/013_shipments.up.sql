@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS shipments (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    carrier VARCHAR(50) NOT NULL,
+    tracking_code VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'in_transit',
+    shipped_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_shipments_status CHECK (status IN ('in_transit', 'delivered'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipment_order ON shipments (order_id);
+CREATE INDEX IF NOT EXISTS idx_shipment_status ON shipments (status);
+
+CREATE TABLE IF NOT EXISTS shipment_items (
+    id BIGSERIAL PRIMARY KEY,
+    shipment_id BIGINT NOT NULL REFERENCES shipments (id) ON DELETE CASCADE,
+    order_item_id BIGINT NOT NULL REFERENCES order_items (id),
+    product_id BIGINT NOT NULL,
+    quantity INTEGER NOT NULL,
+    CONSTRAINT chk_shipment_items_quantity CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipment_item_shipment ON shipment_items (shipment_id);
+CREATE INDEX IF NOT EXISTS idx_shipment_item_order_item ON shipment_items (order_item_id);
@@ -0,0 +1,17 @@
+-- Counters handing out gap-free numbers: a value is taken by updating the
+-- row inside the transaction that uses it, so a rollback gives it back
+CREATE TABLE IF NOT EXISTS number_sequences (
+    name VARCHAR(50) PRIMARY KEY,
+    last_value BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders (id),
+    number BIGINT NOT NULL,
+    document BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_order ON invoices (order_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_number ON invoices (number);
@@ -0,0 +1,3 @@
+DELETE FROM number_sequences WHERE name = 'order' OR name LIKE 'order-%';
+DROP INDEX IF EXISTS idx_order_number;
+ALTER TABLE orders DROP COLUMN IF EXISTS number;
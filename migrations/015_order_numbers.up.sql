@@ -0,0 +1,9 @@
+-- Human-readable order numbers. Existing orders are numbered after their ID
+-- in the default format and the counter continues from there.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS number VARCHAR(50) NOT NULL DEFAULT '';
+UPDATE orders SET number = 'ORD-' || LPAD(id::text, 6, '0') WHERE number = '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_number ON orders (number) WHERE number <> '';
+
+INSERT INTO number_sequences (name, last_value)
+SELECT 'order', MAX(id) FROM orders HAVING MAX(id) IS NOT NULL
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,11 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_external_id;
+
+DROP INDEX IF EXISTS idx_order_synced;
+DROP INDEX IF EXISTS idx_order_external_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS synced;
+ALTER TABLE orders DROP COLUMN IF EXISTS user_external_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS external_id;
+
+DROP INDEX IF EXISTS idx_product_external_id;
+ALTER TABLE products DROP COLUMN IF EXISTS code;
+ALTER TABLE products DROP COLUMN IF EXISTS external_id;
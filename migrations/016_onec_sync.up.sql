@@ -0,0 +1,12 @@
+-- References to 1C records and the flags tracking what 1C has been sent
+ALTER TABLE products ADD COLUMN IF NOT EXISTS external_id VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS code VARCHAR(50) NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_external_id ON products (external_id) WHERE external_id <> '';
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS external_id VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS user_external_id VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS synced BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS idx_order_external_id ON orders (external_id) WHERE external_id <> '';
+CREATE INDEX IF NOT EXISTS idx_order_synced ON orders (synced) WHERE NOT synced;
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_external_id VARCHAR(100) NOT NULL DEFAULT '';
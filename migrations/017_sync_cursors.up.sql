@@ -0,0 +1,7 @@
+-- Positions of incremental syncs with 1C: each job fetches the changes
+-- since its cursor and moves it in the transaction applying them
+CREATE TABLE IF NOT EXISTS sync_cursors (
+    name VARCHAR(50) PRIMARY KEY,
+    since TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,17 @@
+-- History of the sync jobs with 1C: one row per run, finished_at stays
+-- NULL while it runs and error is empty for runs that succeeded
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(20) NOT NULL,
+    trigger VARCHAR(20) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    fetched INTEGER NOT NULL DEFAULT 0,
+    created INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    CONSTRAINT chk_sync_runs_trigger CHECK (trigger IN ('worker', 'manual', 'forced'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_run_type_started ON sync_runs (type, started_at DESC);
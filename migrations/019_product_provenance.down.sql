@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS sync_conflicts;
+DROP TABLE IF EXISTS product_sync_values;
+ALTER TABLE products DROP COLUMN IF EXISTS synced_at;
+ALTER TABLE products DROP COLUMN IF EXISTS modified_at;
+ALTER TABLE products DROP COLUMN IF EXISTS modified_by;
@@ -0,0 +1,30 @@
+-- Who changed a product last and when 1C's values were last applied
+ALTER TABLE products ADD COLUMN IF NOT EXISTS modified_by VARCHAR(10) NOT NULL DEFAULT 'shop';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS modified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS synced_at TIMESTAMP WITH TIME ZONE;
+
+-- The synced product fields as 1C last sent them, so a sync can tell which
+-- side changed a field since
+CREATE TABLE IF NOT EXISTS product_sync_values (
+    product_id BIGINT NOT NULL,
+    field VARCHAR(20) NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (product_id, field),
+    CONSTRAINT fk_product_sync_values_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+-- Fields both the shop and 1C changed between two syncs, and whose change won
+CREATE TABLE IF NOT EXISTS sync_conflicts (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL,
+    field VARCHAR(20) NOT NULL,
+    synced_value TEXT NOT NULL,
+    shop_value TEXT NOT NULL,
+    onec_value TEXT NOT NULL,
+    winner VARCHAR(10) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_sync_conflicts_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT chk_sync_conflicts_winner CHECK (winner IN ('shop', '1c'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_conflict_product ON sync_conflicts (product_id, created_at DESC);
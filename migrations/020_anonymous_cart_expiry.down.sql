@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_cart_anonymous_updated;
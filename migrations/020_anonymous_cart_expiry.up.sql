@@ -0,0 +1,3 @@
+-- Anonymous carts nobody touched for CART_ANONYMOUS_TTL are deleted in the
+-- background; this index finds them without scanning user carts.
+CREATE INDEX IF NOT EXISTS idx_cart_anonymous_updated ON carts (updated_at) WHERE token IS NOT NULL;
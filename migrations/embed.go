@@ -0,0 +1,14 @@
+// Package migrations holds the versioned SQL schema migrations. They are the
+// single source of truth for the database schema and are embedded into the
+// binary so the server can apply them without access to the source tree.
+//
+// Files are named NNN_description.up.sql and NNN_description.down.sql; an
+// applied migration must never be edited, add a new version instead.
+package migrations
+
+import "embed"
+
+// FS contains every migration file
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,257 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Stock warnings reported for cart lines
+const (
+	CartWarningUnavailable       = "product_unavailable"
+	CartWarningOutOfStock        = "out_of_stock"
+	CartWarningInsufficientStock = "insufficient_stock"
+)
+
+// CartLine is a cart item priced with the current product price
+type CartLine struct {
+	ProductID uint            `json:"product_id"`
+	Name      string          `json:"name"`
+	SKU       string          `json:"sku"`
+	Quantity  int             `json:"quantity"`
+	Price     models.Money    `json:"price"`
+	LineTotal models.Money    `json:"line_total"`
+	Currency  models.Currency `json:"currency"`
+	Available int             `json:"available"`
+	Warning   string          `json:"warning,omitempty"`
+}
+
+// CartView is a cart as shown to the client: current prices converted to
+// Currency and a warning on every line that cannot be ordered as it stands
+type CartView struct {
+	ID       uint            `json:"id,omitempty"`
+	Token    *uuid.UUID      `json:"token,omitempty"`
+	Currency models.Currency `json:"currency"`
+	Items    []CartLine      `json:"items"`
+	Total    models.Money    `json:"total"`
+	Warnings int             `json:"warnings"`
+}
+
+// FindUserCart returns the user's cart with its items
+func FindUserCart(db *gorm.DB, userID uuid.UUID) (*models.Cart, error) {
+	var cart models.Cart
+	if err := db.Preload("Items", orderCartItems).Where("user_id = ?", userID).First(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// FindAnonymousCart returns the anonymous cart holding token with its items
+func FindAnonymousCart(db *gorm.DB, token uuid.UUID) (*models.Cart, error) {
+	var cart models.Cart
+	if err := db.Preload("Items", orderCartItems).Where("token = ?", token).First(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// LockUserCart returns the user's cart with its items and locks the cart
+// until the end of the transaction
+func LockUserCart(tx *gorm.DB, userID uuid.UUID) (*models.Cart, error) {
+	var cart models.Cart
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&cart).Error; err != nil {
+		return nil, err
+	}
+	if err := orderCartItems(tx).Where("cart_id = ?", cart.ID).Find(&cart.Items).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// UserCart returns the user's cart, creating an empty one on first use
+func UserCart(db *gorm.DB, userID uuid.UUID) (*models.Cart, error) {
+	cart := models.Cart{UserID: &userID}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&cart).Error; err != nil {
+		return nil, err
+	}
+	return FindUserCart(db, userID)
+}
+
+// NewAnonymousCart creates an empty cart for a visitor who is not logged in
+func NewAnonymousCart(db *gorm.DB) (*models.Cart, error) {
+	token := uuid.New()
+	cart := models.Cart{Token: &token}
+	if err := db.Create(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// AddCartItem adds quantity of a product to the cart, on top of what the
+// cart already holds
+func AddCartItem(db *gorm.DB, cartID, productID uint, quantity int) error {
+	item := models.CartItem{CartID: cartID, ProductID: productID, Quantity: quantity}
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "cart_id"}, {Name: "product_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"quantity":   gorm.Expr("cart_items.quantity + EXCLUDED.quantity"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&item).Error
+	if err != nil {
+		return err
+	}
+	return touchCart(db, cartID)
+}
+
+// SetCartItemQuantity replaces the quantity of a product already in the
+// cart. It returns gorm.ErrRecordNotFound when the product is not in it.
+func SetCartItemQuantity(db *gorm.DB, cartID, productID uint, quantity int) error {
+	result := db.Model(&models.CartItem{}).
+		Where("cart_id = ? AND product_id = ?", cartID, productID).
+		Update("quantity", quantity)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return touchCart(db, cartID)
+}
+
+// RemoveCartItem takes a product out of the cart. It returns
+// gorm.ErrRecordNotFound when the product is not in it.
+func RemoveCartItem(db *gorm.DB, cartID, productID uint) error {
+	result := db.Where("cart_id = ? AND product_id = ?", cartID, productID).Delete(&models.CartItem{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return touchCart(db, cartID)
+}
+
+// ClearCart removes every item from the cart
+func ClearCart(db *gorm.DB, cartID uint) error {
+	if err := db.Where("cart_id = ?", cartID).Delete(&models.CartItem{}).Error; err != nil {
+		return err
+	}
+	return touchCart(db, cartID)
+}
+
+// MergeAnonymousCart moves the items of the anonymous cart holding token into
+// the user's cart, adding up quantities of products in both, and deletes the
+// anonymous cart. Unknown tokens are ignored.
+func MergeAnonymousCart(tx *gorm.DB, token, userID uuid.UUID) error {
+	var anonymous models.Cart
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token = ?", token).First(&anonymous).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cart, err := UserCart(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	var items []models.CartItem
+	if err := tx.Where("cart_id = ?", anonymous.ID).Order("product_id").Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := AddCartItem(tx, cart.ID, item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	return tx.Delete(&anonymous).Error
+}
+
+// DeleteExpiredAnonymousCarts deletes the anonymous carts nobody touched
+// since before and returns how many were deleted. User carts are kept.
+func DeleteExpiredAnonymousCarts(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("token IS NOT NULL AND updated_at < ?", before).Delete(&models.Cart{})
+	return result.RowsAffected, result.Error
+}
+
+// BuildCartView prices the cart with the current product prices converted to
+// currency and flags lines that cannot be ordered. Products that were deleted
+// are listed at a zero price.
+func BuildCartView(db *gorm.DB, cart *models.Cart, currency models.Currency) (*CartView, error) {
+	view := &CartView{Currency: currency, Items: []CartLine{}}
+	if cart == nil {
+		return view, nil
+	}
+	view.ID = cart.ID
+	view.Token = cart.Token
+
+	productIDs := make([]uint, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	var products []models.Product
+	if len(productIDs) > 0 {
+		if err := db.Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	rates, err := LatestExchangeRates(db, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range cart.Items {
+		line := CartLine{ProductID: item.ProductID, Quantity: item.Quantity, Currency: currency}
+
+		product, ok := byID[item.ProductID]
+		if !ok {
+			line.Warning = CartWarningUnavailable
+			view.Items = append(view.Items, line)
+			view.Warnings++
+			continue
+		}
+
+		line.Name = product.Name
+		line.SKU = product.SKU
+		if line.Price, err = rates.Convert(product.Price, product.Currency, currency); err != nil {
+			return nil, err
+		}
+		line.LineTotal = line.Price.Mul(item.Quantity)
+		line.Available = product.Stock - product.Reserved
+		switch {
+		case line.Available <= 0:
+			line.Available = 0
+			line.Warning = CartWarningOutOfStock
+		case line.Available < item.Quantity:
+			line.Warning = CartWarningInsufficientStock
+		}
+		if line.Warning != "" {
+			view.Warnings++
+		}
+
+		view.Total = view.Total.Add(line.LineTotal)
+		view.Items = append(view.Items, line)
+	}
+	return view, nil
+}
+
+func orderCartItems(db *gorm.DB) *gorm.DB {
+	return db.Order("cart_items.created_at, cart_items.id")
+}
+
+func touchCart(db *gorm.DB, cartID uint) error {
+	return db.Model(&models.Cart{}).Where("id = ?", cartID).Update("updated_at", time.Now()).Error
+}
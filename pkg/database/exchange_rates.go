@@ -0,0 +1,53 @@
+package database
+
+import (
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// LatestExchangeRates returns the rate in effect at the given time for every
+// currency that has one. It takes the connection explicitly so checkout can
+// read the rates inside its own transaction.
+func LatestExchangeRates(db *gorm.DB, at time.Time) (models.Rates, error) {
+	var rows []models.ExchangeRate
+	err := db.Raw(`
+		SELECT DISTINCT ON (currency) *
+		FROM exchange_rates
+		WHERE effective_at <= ?
+		ORDER BY currency, effective_at DESC, id DESC
+	`, at).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make(models.Rates, len(rows))
+	for _, row := range rows {
+		rates[row.Currency] = row.Rate
+	}
+	return rates, nil
+}
+
+// GetExchangeRateHistory returns a paginated rate history, newest first,
+// optionally for a single currency
+func GetExchangeRateHistory(page, limit int, currency models.Currency) ([]models.ExchangeRate, int64, error) {
+	var total int64
+	var results []models.ExchangeRate
+
+	query := DB.Model(&models.ExchangeRate{})
+	if currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("effective_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&results).Error
+
+	return results, total, err
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrOrderStatusChanged is returned when the order's status was changed by
+// someone else between reading and updating it
+var ErrOrderStatusChanged = errors.New("order status was changed concurrently")
+
+// RecordOrderCreated writes the initial history entry of a new order. It must
+// run in the transaction that creates the order.
+func RecordOrderCreated(tx *gorm.DB, order *models.Order, actor models.Actor) error {
+	return tx.Create(&models.OrderStatusHistory{
+		OrderID:   order.ID,
+		ToStatus:  order.Status,
+		ActorType: actor.Type,
+		ActorID:   actor.ID,
+		Reason:    "order placed",
+		CreatedAt: time.Now().UTC(),
+	}).Error
+}
+
+// SetOrderStatus moves the order to a new status and records the transition
+// in order_status_history. It must run inside the caller's transaction so the
+// change and its audit entry are committed together. The update is
+// conditional on the status the caller read, so concurrent changes fail with
+// ErrOrderStatusChanged instead of being silently overwritten. Changes 1C
+// did not make itself flag the order for the next sync.
+func SetOrderStatus(tx *gorm.DB, order *models.Order, to models.OrderStatus, actor models.Actor, reason string) error {
+	from := order.Status
+	updates := map[string]interface{}{"status": to}
+	if actor.Type != models.ActorOneC {
+		updates["synced"] = false
+	}
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ?", order.ID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderStatusChanged
+	}
+
+	if err := tx.Create(&models.OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: &from,
+		ToStatus:   to,
+		ActorType:  actor.Type,
+		ActorID:    actor.ID,
+		Reason:     reason,
+		CreatedAt:  time.Now().UTC(),
+	}).Error; err != nil {
+		return err
+	}
+
+	order.Status = to
+	if actor.Type != models.ActorOneC {
+		order.Synced = false
+	}
+	return nil
+}
+
+// GetOrderStatusHistory returns the status transitions of an order, oldest first
+func GetOrderStatusHistory(orderID uint) ([]models.OrderStatusHistory, error) {
+	var history []models.OrderStatusHistory
+	err := DB.Where("order_id = ?", orderID).
+		Order("created_at, id").
+		Find(&history).Error
+	return history, err
+}
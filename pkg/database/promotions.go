@@ -0,0 +1,49 @@
+package database
+
+import (
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// GetPromotions returns a paginated list of promotions with their targets,
+// newest first
+func GetPromotions(page, limit int, activeOnly bool) ([]models.Promotion, int64, error) {
+	var total int64
+	var results []models.Promotion
+
+	query := DB.Model(&models.Promotion{})
+	if activeOnly {
+		query = query.Where("active")
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Targets").
+		Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&results).Error
+
+	return results, total, err
+}
+
+// SavePromotion updates a promotion and replaces its targets. The usage
+// counter is left alone since checkouts maintain it.
+func SavePromotion(tx *gorm.DB, promotion *models.Promotion) error {
+	if err := tx.Omit("usage_count", "Targets").Save(promotion).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("promotion_id = ?", promotion.ID).Delete(&models.PromotionTarget{}).Error; err != nil {
+		return err
+	}
+	for i := range promotion.Targets {
+		promotion.Targets[i].ID = 0
+		promotion.Targets[i].PromotionID = promotion.ID
+	}
+	if len(promotion.Targets) == 0 {
+		return nil
+	}
+	return tx.Create(&promotion.Targets).Error
+}
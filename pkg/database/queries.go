@@ -1,17 +1,20 @@
 package database
 
 import (
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/google/uuid"
 )
 
 type UserWithLastOrder struct {
-	UserID        uint    `json:"user_id"`
-	UserName      string  `json:"user_name"`
-	UserEmail     string  `json:"user_email"`
-	LastOrderID   *uint   `json:"last_order_id"`
-	LastOrderDate *string `json:"last_order_date"`
-	OrderStatus   *string `json:"order_status"`
-	OrderTotal    *float64 `json:"order_total"`
+	UserID        uuid.UUID     `json:"user_id"`
+	UserName      string        `json:"user_name"`
+	UserEmail     string        `json:"user_email"`
+	LastOrderID   *uint         `json:"last_order_id"`
+	LastOrderDate *string       `json:"last_order_date"`
+	OrderStatus   *string       `json:"order_status"`
+	OrderTotal    *models.Money `json:"order_total"`
+	OrderCurrency *string       `json:"order_currency"`
 }
 
 // GetUsersWithLastOrders returns a paginated list of users with their last order
@@ -27,7 +30,7 @@ func GetUsersWithLastOrders(page, limit int, nameFilter string) ([]UserWithLastO
 	// Count total users with filter
 	query := DB.Model(&models.User{})
 	if nameFilter != "" {
-		query = query.Where("users.name ILIKE ?", "%"+nameFilter+"%")
+		query = query.Where("CONCAT_WS(' ', users.first_name, users.last_name) ILIKE ?", "%"+nameFilter+"%")
 	}
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -38,13 +41,13 @@ func GetUsersWithLastOrders(page, limit int, nameFilter string) ([]UserWithLastO
 		WITH RankedUsers AS (
 			SELECT 
 				u.id,
-				u.name,
+				CONCAT_WS(' ', u.first_name, u.last_name) as name,
 				u.email,
 				ROW_NUMBER() OVER (ORDER BY u.created_at DESC) as row_num
 			FROM users u
 			WHERE 
 				u.deleted_at IS NULL
-				AND CASE WHEN ? != '' THEN u.name ILIKE ? ELSE TRUE END
+				AND CASE WHEN ? != '' THEN CONCAT_WS(' ', u.first_name, u.last_name) ILIKE ? ELSE TRUE END
 		)
 		SELECT 
 			ru.id as user_id,
@@ -53,7 +56,8 @@ func GetUsersWithLastOrders(page, limit int, nameFilter string) ([]UserWithLastO
 			o.id as last_order_id,
 			o.created_at::text as last_order_date,
 			o.status::text as order_status,
-			o.total as order_total
+			o.total as order_total,
+			o.currency as order_currency
 		FROM RankedUsers ru
 		LEFT JOIN LATERAL (
 			SELECT o.*
@@ -71,25 +75,35 @@ func GetUsersWithLastOrders(page, limit int, nameFilter string) ([]UserWithLastO
 	return results, total, err
 }
 
-// GetUserOrderSummary returns a summary of user's orders with basic statistics
-func GetUserOrderSummary(userID uint) (struct {
-	TotalOrders     int     `json:"total_orders"`
-	TotalSpent      float64 `json:"total_spent"`
-	AverageOrderValue float64 `json:"average_order_value"`
-	LastOrderDate    *string `json:"last_order_date"`
+// OrderOwnedBy reports whether the order belongs to the given user
+func OrderOwnedBy(orderID uint, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := DB.Model(&models.Order{}).
+		Where("id = ? AND user_id = ?", orderID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetUserOrderSummary returns a summary of user's orders with basic statistics.
+// Amounts are in the base currency, converted with each order's snapshotted rate.
+func GetUserOrderSummary(userID uuid.UUID) (struct {
+	TotalOrders       int          `json:"total_orders"`
+	TotalSpent        models.Money `json:"total_spent"`
+	AverageOrderValue models.Money `json:"average_order_value"`
+	LastOrderDate     *string      `json:"last_order_date"`
 }, error) {
 	var summary struct {
-		TotalOrders      int     `json:"total_orders"`
-		TotalSpent       float64 `json:"total_spent"`
-		AverageOrderValue float64 `json:"average_order_value"`
-		LastOrderDate    *string `json:"last_order_date"`
+		TotalOrders       int          `json:"total_orders"`
+		TotalSpent        models.Money `json:"total_spent"`
+		AverageOrderValue models.Money `json:"average_order_value"`
+		LastOrderDate     *string      `json:"last_order_date"`
 	}
 
 	err := DB.Raw(`
 		SELECT 
 			COUNT(*) as total_orders,
-			COALESCE(SUM(total), 0) as total_spent,
-			COALESCE(AVG(total), 0) as average_order_value,
+			COALESCE(ROUND(SUM(total * exchange_rate), 2), 0) as total_spent,
+			COALESCE(ROUND(AVG(total * exchange_rate), 2), 0) as average_order_value,
 			MAX(created_at)::text as last_order_date
 		FROM orders
 		WHERE user_id = ?
@@ -99,21 +113,25 @@ func GetUserOrderSummary(userID uint) (struct {
 	return summary, err
 }
 
-// ProductWithStats represents a product with additional statistics
+// ProductWithStats represents a product with additional statistics.
+// Price is in Currency, Revenue in the base currency.
 type ProductWithStats struct {
-	ID          uint    `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	SKU         string  `json:"sku"`
-	Stock       int     `json:"stock"`
-	TotalOrders int     `json:"total_orders"`
-	TotalSold   int     `json:"total_sold"`
-	Revenue     float64 `json:"revenue"`
+	ID          uint            `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Price       models.Money    `json:"price"`
+	Currency    models.Currency `json:"currency"`
+	SKU         string          `json:"sku"`
+	Category    string          `json:"category"`
+	Stock       int             `json:"stock"`
+	Reserved    int             `json:"reserved"`
+	TotalOrders int             `json:"total_orders"`
+	TotalSold   int             `json:"total_sold"`
+	Revenue     models.Money    `json:"revenue"`
 }
 
 // GetProductsWithStats returns products with their sales statistics
-func GetProductsWithStats(page, limit int, nameFilter string, minPrice, maxPrice float64, inStock bool) ([]ProductWithStats, int64, error) {
+func GetProductsWithStats(page, limit int, nameFilter string, minPrice, maxPrice models.Money, inStock bool) ([]ProductWithStats, int64, error) {
 	var total int64
 	var results []ProductWithStats
 
@@ -124,7 +142,7 @@ func GetProductsWithStats(page, limit int, nameFilter string, minPrice, maxPrice
 	}
 	countQuery = countQuery.Where("price BETWEEN ? AND ?", minPrice, maxPrice)
 	if inStock {
-		countQuery = countQuery.Where("stock > 0")
+		countQuery = countQuery.Where("stock - reserved > 0")
 	}
 	if err := countQuery.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -137,7 +155,7 @@ func GetProductsWithStats(page, limit int, nameFilter string, minPrice, maxPrice
 				p.id,
 				COUNT(DISTINCT oi.order_id) as total_orders,
 				COALESCE(SUM(oi.quantity), 0) as total_sold,
-				COALESCE(SUM(oi.quantity * oi.price), 0) as revenue
+				COALESCE(SUM(oi.quantity * oi.base_price), 0) as revenue
 			FROM products p
 			LEFT JOIN order_items oi ON p.id = oi.product_id
 			LEFT JOIN orders o ON oi.order_id = o.id AND o.status != 'cancelled'
@@ -149,8 +167,11 @@ func GetProductsWithStats(page, limit int, nameFilter string, minPrice, maxPrice
 			p.name,
 			p.description,
 			p.price,
+			p.currency,
 			p.sku,
+			p.category,
 			p.stock,
+			p.reserved,
 			COALESCE(ps.total_orders, 0) as total_orders,
 			COALESCE(ps.total_sold, 0) as total_sold,
 			COALESCE(ps.revenue, 0) as revenue
@@ -160,7 +181,7 @@ func GetProductsWithStats(page, limit int, nameFilter string, minPrice, maxPrice
 			p.deleted_at IS NULL
 			AND CASE WHEN ? != '' THEN p.name ILIKE ? ELSE TRUE END
 			AND p.price BETWEEN ? AND ?
-			AND CASE WHEN ? THEN p.stock > 0 ELSE TRUE END
+			AND CASE WHEN ? THEN p.stock - p.reserved > 0 ELSE TRUE END
 		ORDER BY p.created_at DESC
 		OFFSET ? LIMIT ?
 	`, nameFilter, "%"+nameFilter+"%", minPrice, maxPrice, inStock, (page-1)*limit, limit).
@@ -171,30 +192,37 @@ func GetProductsWithStats(page, limit int, nameFilter string, minPrice, maxPrice
 
 // OrderWithDetails represents an order with detailed information
 type OrderWithDetails struct {
-	OrderID     uint           `json:"order_id"`
-	UserID      uint           `json:"user_id"`
-	UserName    string         `json:"user_name"`
-	UserEmail   string         `json:"user_email"`
-	Status      models.OrderStatus `json:"status"`
-	Total       float64        `json:"total"`
-	ItemCount   int            `json:"item_count"`
-	CreatedAt   string         `json:"created_at"`
-	UpdatedAt   string         `json:"updated_at"`
+	OrderID   uint               `json:"order_id"`
+	Number    string             `json:"number"`
+	UserID    uuid.UUID          `json:"user_id"`
+	UserName  string             `json:"user_name"`
+	UserEmail string             `json:"user_email"`
+	Status    models.OrderStatus `json:"status"`
+	Total     models.Money       `json:"total"`
+	Currency  models.Currency    `json:"currency"`
+	ItemCount int                `json:"item_count"`
+	CreatedAt string             `json:"created_at"`
+	UpdatedAt string             `json:"updated_at"`
 }
 
-// GetOrdersWithDetails returns orders with user and item details
-func GetOrdersWithDetails(page, limit int, userID string, status string) ([]OrderWithDetails, int64, error) {
+// GetOrdersWithDetails returns orders with user and item details.
+// A nil userID returns orders of all users; a number matches orders whose
+// number contains it, ignoring case.
+func GetOrdersWithDetails(page, limit int, userID *uuid.UUID, status, number string) ([]OrderWithDetails, int64, error) {
 	var total int64
 	var results []OrderWithDetails
 
 	// Base query for counting
 	countQuery := DB.Model(&models.Order{}).Where("orders.deleted_at IS NULL")
-	if userID != "" {
-		countQuery = countQuery.Where("orders.user_id = ?", userID)
+	if userID != nil {
+		countQuery = countQuery.Where("orders.user_id = ?", *userID)
 	}
 	if status != "" {
 		countQuery = countQuery.Where("orders.status = ?", status)
 	}
+	if number != "" {
+		countQuery = countQuery.Where("orders.number ILIKE ?", "%"+number+"%")
+	}
 	if err := countQuery.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -210,11 +238,13 @@ func GetOrdersWithDetails(page, limit int, userID string, status string) ([]Orde
 		)
 		SELECT 
 			o.id as order_id,
+			o.number,
 			o.user_id,
-			u.name as user_name,
+			CONCAT_WS(' ', u.first_name, u.last_name) as user_name,
 			u.email as user_email,
 			o.status,
 			o.total,
+			o.currency,
 			COALESCE(oic.item_count, 0) as item_count,
 			o.created_at::text as created_at,
 			o.updated_at::text as updated_at
@@ -223,52 +253,51 @@ func GetOrdersWithDetails(page, limit int, userID string, status string) ([]Orde
 		LEFT JOIN OrderItemCounts oic ON o.id = oic.order_id
 		WHERE 
 			o.deleted_at IS NULL
-			AND CASE WHEN ? != '' THEN o.user_id::text = ? ELSE TRUE END
+			AND (CAST(? AS uuid) IS NULL OR o.user_id = ?)
 			AND CASE WHEN ? != '' THEN o.status = ? ELSE TRUE END
+			AND CASE WHEN ? != '' THEN o.number ILIKE ? ELSE TRUE END
 		ORDER BY o.created_at DESC
 		OFFSET ? LIMIT ?
-	`, userID, userID, status, status, (page-1)*limit, limit).
+	`, userID, userID, status, status, number, "%"+number+"%", (page-1)*limit, limit).
 		Scan(&results).Error
 
 	return results, total, err
 }
 
-// GetOrderDetails returns detailed information about a specific order
-func GetOrderDetails(orderID uint) (*struct {
-	Order    models.Order     `json:"order"`
-	Items    []struct {
-		ID          uint    `json:"id"`
-		ProductName string  `json:"product_name"`
-		SKU         string  `json:"sku"`
-		Quantity    int     `json:"quantity"`
-		Price       float64 `json:"price"`
-		Subtotal    float64 `json:"subtotal"`
-	} `json:"items"`
-	User struct {
-		ID    uint   `json:"id"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
+// OrderDetails is an order with its items priced and described, its VAT
+// breakdown and its customer, as shown to users and printed on invoices
+type OrderDetails struct {
+	Order models.Order       `json:"order"`
+	Items []OrderDetailsItem `json:"items"`
+	Taxes []models.TaxLine   `json:"taxes"`
+	User  struct {
+		ID    uuid.UUID `json:"id"`
+		Name  string    `json:"name"`
+		Email string    `json:"email"`
 	} `json:"user"`
-}, error) {
-	var result struct {
-		Order    models.Order     `json:"order"`
-		Items    []struct {
-			ID          uint    `json:"id"`
-			ProductName string  `json:"product_name"`
-			SKU         string  `json:"sku"`
-			Quantity    int     `json:"quantity"`
-			Price       float64 `json:"price"`
-			Subtotal    float64 `json:"subtotal"`
-		} `json:"items"`
-		User struct {
-			ID    uint   `json:"id"`
-			Name  string `json:"name"`
-			Email string `json:"email"`
-		} `json:"user"`
-	}
+}
+
+// OrderDetailsItem is an order line with its product's name and SKU
+type OrderDetailsItem struct {
+	ID          uint            `json:"id"`
+	ProductName string          `json:"product_name"`
+	SKU         string          `json:"sku"`
+	Quantity    int             `json:"quantity"`
+	Price       models.Money    `json:"price"`
+	Subtotal    models.Money    `json:"subtotal"`
+	TaxClass    models.TaxClass `json:"tax_class"`
+	TaxPercent  int             `json:"tax_percent"`
+	Net         models.Money    `json:"net"`
+	Tax         models.Money    `json:"tax"`
+	Gross       models.Money    `json:"gross"`
+}
+
+// GetOrderDetails returns detailed information about a specific order
+func GetOrderDetails(orderID uint) (*OrderDetails, error) {
+	var result OrderDetails
 
 	// Get order with basic information
-	if err := DB.First(&result.Order, orderID).Error; err != nil {
+	if err := DB.Preload("Adjustments").Preload("Shipments.Items").First(&result.Order, orderID).Error; err != nil {
 		return nil, err
 	}
 
@@ -280,7 +309,12 @@ func GetOrderDetails(orderID uint) (*struct {
 			p.sku,
 			oi.quantity,
 			oi.price,
-			(oi.quantity * oi.price) as subtotal
+			(oi.quantity * oi.price) as subtotal,
+			oi.tax_class,
+			oi.tax_percent,
+			oi.net,
+			oi.tax,
+			oi.gross
 		FROM order_items oi
 		JOIN products p ON oi.product_id = p.id
 		WHERE oi.order_id = ?
@@ -290,11 +324,27 @@ func GetOrderDetails(orderID uint) (*struct {
 		return nil, err
 	}
 
+	// Sum the items per VAT rate, as printed on invoices
+	err = DB.Raw(`
+		SELECT
+			tax_percent as percent,
+			SUM(net) as net,
+			SUM(tax) as tax,
+			SUM(gross) as gross
+		FROM order_items
+		WHERE order_id = ?
+		GROUP BY tax_percent
+		ORDER BY tax_percent DESC
+	`, orderID).Scan(&result.Taxes).Error
+	if err != nil {
+		return nil, err
+	}
+
 	// Get user details
 	err = DB.Raw(`
 		SELECT 
 			u.id,
-			u.name,
+			CONCAT_WS(' ', u.first_name, u.last_name) as name,
 			u.email
 		FROM users u
 		JOIN orders o ON u.id = o.user_id
@@ -302,4 +352,4 @@ func GetOrderDetails(orderID uint) (*struct {
 	`, orderID).Scan(&result.User).Error
 
 	return &result, err
-} 
\ No newline at end of file
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+)
+
+// GetReturns returns a paginated list of returns with their items and
+// refunds, newest first, optionally filtered by status
+func GetReturns(page, limit int, status models.ReturnStatus) ([]models.Return, int64, error) {
+	var total int64
+	var results []models.Return
+
+	query := DB.Model(&models.Return{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Items").Preload("Refund").
+		Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&results).Error
+
+	return results, total, err
+}
+
+// GetOrderReturns returns the returns of an order, oldest first
+func GetOrderReturns(orderID uint) ([]models.Return, error) {
+	var results []models.Return
+	err := DB.Preload("Items").Preload("Refund").
+		Where("order_id = ?", orderID).
+		Order("id").
+		Find(&results).Error
+	return results, err
+}
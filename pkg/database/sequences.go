@@ -0,0 +1,23 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// NextSequenceValue takes the next number of the named sequence, starting at
+// 1. Unlike a database sequence it leaves no gaps: the counter row stays
+// locked until tx ends, and rolling tx back returns the number.
+func NextSequenceValue(tx *gorm.DB, name string) (int64, error) {
+	var value int64
+	err := tx.Raw(`
+		INSERT INTO number_sequences (name, last_value) VALUES (?, 1)
+		ON CONFLICT (name) DO UPDATE SET last_value = number_sequences.last_value + 1
+		RETURNING last_value
+	`, name).Scan(&value).Error
+	if err != nil {
+		return 0, fmt.Errorf("taking next %s number: %w", name, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// GetTaxRates returns the tax rates ordered by region and class, optionally
+// for a single region
+func GetTaxRates(region string) ([]models.TaxRate, error) {
+	var results []models.TaxRate
+
+	query := DB.Model(&models.TaxRate{})
+	if region != "" {
+		query = query.Where("region = ?", region)
+	}
+	err := query.Order("region, tax_class").Find(&results).Error
+
+	return results, err
+}
+
+// SaveTaxRate creates the rate of a tax class in a region or replaces its
+// percentage. Orders keep the percentage they were placed with.
+func SaveTaxRate(rate *models.TaxRate) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "region"}, {Name: "tax_class"}},
+		DoUpdates: clause.AssignmentColumns([]string{"percent", "updated_at"}),
+	}).Create(rate).Error
+}
+
+// TaxClassExists reports whether any region has a rate for the tax class
+func TaxClassExists(class models.TaxClass) (bool, error) {
+	var count int64
+	err := DB.Model(&models.TaxRate{}).Where("tax_class = ?", class).Count(&count).Error
+	return count > 0, err
+}
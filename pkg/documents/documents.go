@@ -0,0 +1,142 @@
+// Package documents renders the printed documents of an order: the invoice
+// for accounting and the packing slip for the warehouse. Invoices are
+// numbered without gaps and stored when first issued.
+package documents
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// invoiceSequence names the counter invoice numbers are taken from
+const invoiceSequence = "invoice"
+
+// ErrNotInvoiceable is returned for orders that were never paid
+var ErrNotInvoiceable = errors.New("order cannot be invoiced")
+
+// Invoice returns the invoice of an order, issuing it on first request:
+// it takes the next invoice number and stores the rendered PDF. The order
+// must be locked by the caller so it is invoiced only once. Pending and
+// cancelled orders get no new invoice, but one issued before a cancellation
+// is still returned.
+func Invoice(tx *gorm.DB, details *database.OrderDetails) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := tx.Where("order_id = ?", details.Order.ID).First(&invoice).Error
+	if err == nil {
+		return &invoice, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("loading invoice: %w", err)
+	}
+
+	switch details.Order.Status {
+	case models.OrderStatusPending, models.OrderStatusCancelled:
+		return nil, ErrNotInvoiceable
+	}
+
+	number, err := database.NextSequenceValue(tx, invoiceSequence)
+	if err != nil {
+		return nil, err
+	}
+	invoice = models.Invoice{
+		OrderID:   details.Order.ID,
+		Number:    number,
+		CreatedAt: time.Now(),
+	}
+	invoice.Document = RenderInvoice(&invoice, details)
+	if err := tx.Create(&invoice).Error; err != nil {
+		return nil, fmt.Errorf("recording invoice: %w", err)
+	}
+	return &invoice, nil
+}
+
+// InvoiceNumber formats an invoice number as printed
+func InvoiceNumber(number int64) string {
+	return fmt.Sprintf("%06d", number)
+}
+
+// RenderInvoice renders the invoice of an order: its customer, the items
+// with SKU, quantity, price and VAT, and the totals with the VAT breakdown
+func RenderInvoice(invoice *models.Invoice, details *database.OrderDetails) []byte {
+	order := &details.Order
+	d := newPDF()
+
+	d.text(fontBold, 18, "Invoice No. "+InvoiceNumber(invoice.Number))
+	d.text(fontRegular, 10, "Date: "+invoice.CreatedAt.Format("2006-01-02"))
+	d.text(fontRegular, 10, fmt.Sprintf("Order: %d of %s", order.ID, order.CreatedAt.Format("2006-01-02")))
+	d.space(10)
+	d.text(fontBold, 11, "Bill to")
+	d.text(fontRegular, 10, details.User.Name)
+	d.text(fontRegular, 10, details.User.Email)
+	d.space(10)
+
+	d.text(fontMono, 8, fmt.Sprintf("%-12s %-30s %4s %10s %10s %4s %10s %10s",
+		"SKU", "Item", "Qty", "Price", "Net", "VAT", "VAT amt", "Total"))
+	d.rule()
+	for _, item := range details.Items {
+		d.text(fontMono, 8, fmt.Sprintf("%-12s %-30s %4d %10s %10s %3d%% %10s %10s",
+			column(item.SKU, 12), column(item.ProductName, 30), item.Quantity,
+			item.Price, item.Net, item.TaxPercent, item.Tax, item.Gross))
+	}
+	d.rule()
+
+	totals := func(label string, amount models.Money) {
+		d.text(fontMono, 9, fmt.Sprintf("%68s %12s", label, amount))
+	}
+	totals("Subtotal", order.Subtotal)
+	if discount := order.Total.Sub(order.Subtotal); !discount.IsZero() {
+		totals("Discounts", discount)
+	}
+	totals("Net total", order.NetTotal)
+	for _, line := range details.Taxes {
+		totals(fmt.Sprintf("VAT %d%% on %s", line.Percent, line.Net), line.Tax)
+	}
+	d.text(fontBold, 11, fmt.Sprintf("Total due: %s %s", order.Total, order.Currency))
+
+	return d.bytes()
+}
+
+// RenderPackingSlip renders the packing slip of an order: who it goes to
+// and the items to pick, without prices
+func RenderPackingSlip(details *database.OrderDetails) []byte {
+	order := &details.Order
+	d := newPDF()
+
+	d.text(fontBold, 18, fmt.Sprintf("Packing slip for order %d", order.ID))
+	d.text(fontRegular, 10, "Ordered: "+order.CreatedAt.Format("2006-01-02"))
+	d.space(10)
+	d.text(fontBold, 11, "Ship to")
+	d.text(fontRegular, 10, details.User.Name)
+	d.text(fontRegular, 10, details.User.Email)
+	d.space(10)
+
+	d.text(fontMono, 9, fmt.Sprintf("%-16s %-50s %6s", "SKU", "Item", "Qty"))
+	d.rule()
+	units := 0
+	for _, item := range details.Items {
+		d.text(fontMono, 9, fmt.Sprintf("%-16s %-50s %6d",
+			column(item.SKU, 16), column(item.ProductName, 50), item.Quantity))
+		units += item.Quantity
+	}
+	d.rule()
+	d.text(fontMono, 9, fmt.Sprintf("%67s %6d", "Units", units))
+	d.space(30)
+	d.text(fontRegular, 10, "Packed by: ______________________")
+
+	return d.bytes()
+}
+
+// column fits s into a table column of width characters
+func column(s string, width int) string {
+	r := []rune(latin(s))
+	if len(r) > width {
+		return string(r[:width-1]) + "~"
+	}
+	return string(r)
+}
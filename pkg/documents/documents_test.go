@@ -0,0 +1,92 @@
+package documents
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDetails() *database.OrderDetails {
+	details := &database.OrderDetails{
+		Order: models.Order{
+			ID:        7,
+			Status:    models.OrderStatusPaid,
+			Subtotal:  models.MustParseMoney("130.00"),
+			Total:     models.MustParseMoney("122.00"),
+			NetTotal:  models.MustParseMoney("100.00"),
+			TaxTotal:  models.MustParseMoney("22.00"),
+			Currency:  models.BaseCurrency,
+			CreatedAt: time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC),
+		},
+		Items: []database.OrderDetailsItem{{
+			ID:          1,
+			ProductName: "Чайник (steel)",
+			SKU:         "KETTLE-1",
+			Quantity:    2,
+			Price:       models.MustParseMoney("65.00"),
+			Subtotal:    models.MustParseMoney("130.00"),
+			TaxPercent:  22,
+			Net:         models.MustParseMoney("100.00"),
+			Tax:         models.MustParseMoney("22.00"),
+			Gross:       models.MustParseMoney("122.00"),
+		}},
+		Taxes: []models.TaxLine{{Percent: 22, Net: models.MustParseMoney("100.00"),
+			Tax: models.MustParseMoney("22.00"), Gross: models.MustParseMoney("122.00")}},
+	}
+	details.User.Name = "Anna Petrova"
+	details.User.Email = "anna@example.com"
+	return details
+}
+
+func TestRenderInvoice(t *testing.T) {
+	invoice := &models.Invoice{Number: 42, CreatedAt: time.Date(2026, 5, 5, 9, 0, 0, 0, time.UTC)}
+	doc := RenderInvoice(invoice, testDetails())
+
+	assert.True(t, bytes.HasPrefix(doc, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(doc, []byte("%%EOF\n")))
+	assert.Contains(t, string(doc), "(Invoice No. 000042)")
+	assert.Contains(t, string(doc), "KETTLE-1")
+	assert.Contains(t, string(doc), `Chaynik \(steel\)`)
+	assert.Contains(t, string(doc), "Anna Petrova")
+	assert.Contains(t, string(doc), "Total due: 122.00 RUB")
+
+	// The same invoice renders to the same bytes
+	assert.Equal(t, doc, RenderInvoice(invoice, testDetails()))
+}
+
+func TestRenderedOffsetsMatchXref(t *testing.T) {
+	details := testDetails()
+	// Enough items to spill onto further pages
+	for i := 0; i < 150; i++ {
+		details.Items = append(details.Items, details.Items[0])
+	}
+	doc := RenderPackingSlip(details)
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(doc)
+	require.NotNil(t, startxref)
+	xref, err := strconv.Atoi(string(startxref[1]))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(doc[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc[xref:], -1)
+	require.Greater(t, len(entries), 7)
+	for i, entry := range entries {
+		offset, err := strconv.Atoi(string(entry[1]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(doc[offset:], []byte(strconv.Itoa(i+1)+" 0 obj\n")), "object %d", i+1)
+	}
+}
+
+func TestLatin(t *testing.T) {
+	assert.Equal(t, "Shchetka Yozh", latin("Щетка Yozh"))
+	assert.Equal(t, "Café ?", latin("Café ☕"))
+	assert.Equal(t, "Chaynik dlya ~", column("Чайник для кухни", 14))
+}
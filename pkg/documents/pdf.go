@@ -0,0 +1,162 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size and margins in PDF points
+const (
+	pageWidth    = 595.28
+	pageHeight   = 841.89
+	marginLeft   = 50.0
+	marginTop    = 60.0
+	marginBottom = 60.0
+)
+
+// Fonts of the standard 14 PDF fonts every viewer has, so documents need no
+// embedded font files. Courier is used for tables: its fixed width lets
+// columns be aligned by padding.
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+	fontMono    = "F3"
+)
+
+// pdf lays out lines of text top to bottom on A4 pages, starting a new page
+// when one is full. It writes a minimal PDF 1.4 file; the output depends on
+// nothing but the text, so the same document always renders to the same
+// bytes.
+type pdf struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func newPDF() *pdf {
+	d := &pdf{}
+	d.addPage()
+	return d
+}
+
+func (d *pdf) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - marginTop
+}
+
+// text writes a line in font at size and moves below it
+func (d *pdf) text(font string, size float64, s string) {
+	leading := size * 1.4
+	if d.y-leading < marginBottom {
+		d.addPage()
+	}
+	d.y -= leading
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		font, size, marginLeft, d.y, escapeText(s))
+}
+
+// rule draws a horizontal line across the page below the current line
+func (d *pdf) rule() {
+	d.y -= 4
+	fmt.Fprintf(d.pages[len(d.pages)-1], "0.5 w %.2f %.2f m %.2f %.2f l S\n",
+		marginLeft, d.y, pageWidth-marginLeft, d.y)
+}
+
+// space leaves an empty gap of height points
+func (d *pdf) space(height float64) {
+	d.y -= height
+}
+
+// bytes assembles the PDF file
+func (d *pdf) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1 to 5 are the catalog, the page tree and the fonts; every
+	// page then takes a page object and its content stream
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	for _, name := range []string{"Helvetica", "Helvetica-Bold", "Courier"} {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /%s 3 0 R /%s 4 0 R /%s 5 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontRegular, fontBold, fontMono, 7+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// escapeText encodes s for a PDF string in WinAnsiEncoding
+func escapeText(s string) string {
+	var b strings.Builder
+	for _, r := range latin(s) {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x80:
+			b.WriteRune(r)
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+// latin reduces s to the Latin-1 characters the standard fonts have. Russian
+// text is transliterated, other characters are replaced with '?'.
+func latin(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if t, ok := cyrillic[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		if (r >= 0x20 && r < 0x7f) || (r >= 0xa0 && r <= 0xff) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// cyrillic transliterates Russian letters to Latin ones
+var cyrillic = func() map[rune]string {
+	lower := map[rune]string{
+		'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+		'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+		'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+		'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+		'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	}
+	m := make(map[rune]string, 2*len(lower))
+	for r, t := range lower {
+		m[r] = t
+		upper := []rune(strings.ToUpper(string(r)))[0]
+		if t != "" {
+			t = strings.ToUpper(t[:1]) + t[1:]
+		}
+		m[upper] = t
+	}
+	return m
+}()
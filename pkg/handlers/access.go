@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// canAccessUser reports whether the caller may act on the given user's
+// record: either it is their own record or their role can manage users
+func canAccessUser(c *gin.Context, userID uuid.UUID) bool {
+	if middleware.CurrentRole(c).Can(models.PermissionUsersManage) {
+		return true
+	}
+	current, ok := middleware.CurrentUserID(c)
+	return ok && current == userID
+}
+
+// ownOrdersOnly reports whether the caller is restricted to their own orders
+// and, if so, returns their user ID
+func ownOrdersOnly(c *gin.Context) (uuid.UUID, bool) {
+	if middleware.CurrentRole(c).Can(models.PermissionOrdersReadAll) {
+		return uuid.Nil, false
+	}
+	current, _ := middleware.CurrentUserID(c)
+	return current, true
+}
+
+// canAccessOrder reports whether the caller may see or act on the order.
+// Orders of other users are reported as missing rather than forbidden so
+// that order IDs cannot be probed.
+func canAccessOrder(c *gin.Context, orderID uint) bool {
+	ownerID, restricted := ownOrdersOnly(c)
+	if !restricted {
+		return true
+	}
+	owned, err := database.OrderOwnedBy(orderID, ownerID)
+	return err == nil && owned
+}
+
+// currentActor describes the caller for audit records. Staff who can manage
+// orders are recorded as admins, everyone else as users.
+func currentActor(c *gin.Context) models.Actor {
+	actor := models.Actor{Type: models.ActorUser}
+	if middleware.CurrentRole(c).Can(models.PermissionOrdersManage) {
+		actor.Type = models.ActorAdmin
+	}
+	if id, ok := middleware.CurrentUserID(c); ok {
+		actor.ID = &id
+	}
+	return actor
+}
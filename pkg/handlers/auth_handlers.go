@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// AuthHandler issues and revokes access/refresh token pairs
+type AuthHandler struct {
+	secretKey  string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewAuthHandler creates a new AuthHandler instance
+func NewAuthHandler(secretKey string, accessTTL, refreshTTL time.Duration) *AuthHandler {
+	return &AuthHandler{
+		secretKey:  secretKey,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// LoginInput represents the credentials submitted to the login endpoint
+type LoginInput struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshInput carries a refresh token for the refresh and logout endpoints
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse is returned on successful login or refresh
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Login godoc
+// @Summary Log in
+// @Description Exchange email and password for an access/refresh token pair.
+// @Description An anonymous cart named by X-Cart-Token is merged into the user's cart.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body LoginInput true "User credentials"
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Success 200 {object} TokenResponse
+// @Router /auth/login [post]
+func (h *AuthHandler) Login(c *gin.Context) {
+	var input LoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var user models.User
+	err := database.DB.Where("LOWER(email) = ?", strings.ToLower(input.Email)).First(&user).Error
+	if err != nil || !user.CheckPassword(input.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	// A visitor's anonymous cart follows them into their account
+	var cartToken *uuid.UUID
+	if raw := c.GetHeader(CartTokenHeader); raw != "" {
+		if token, err := uuid.Parse(raw); err == nil {
+			cartToken = &token
+		}
+	}
+
+	var response *TokenResponse
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if cartToken != nil {
+			if err := database.MergeAnonymousCart(tx, *cartToken, user.ID); err != nil {
+				return err
+			}
+		}
+
+		refreshToken, err := h.createRefreshToken(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		response, err = h.tokenResponse(&user, refreshToken)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Rotate a refresh token and issue a new access token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body RefreshInput true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var response *TokenResponse
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashRefreshToken(input.RefreshToken)).
+			First(&current).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+
+		now := time.Now()
+		if current.RevokedAt != nil {
+			// A rotated token is being replayed, so the token family may have
+			// leaked. Revoke every session of the user to force a new login;
+			// returning nil commits the revocation and leaves response empty.
+			return revokeUserRefreshTokens(tx, current.UserID, now)
+		}
+		if !current.IsActive(now) {
+			return errInvalidRefreshToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, "id = ?", current.UserID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+
+		refreshToken, err := h.createRefreshToken(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":  now,
+			"replaced_by": refreshToken.record.ID,
+		}).Error; err != nil {
+			return err
+		}
+
+		response, err = h.tokenResponse(&user, refreshToken)
+		return err
+	})
+	if errors.Is(err, errInvalidRefreshToken) || (err == nil && response == nil) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidRefreshToken.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body RefreshInput true "Refresh token"
+// @Success 204 "No Content"
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := database.DB.Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hashRefreshToken(input.RefreshToken)).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// issuedRefreshToken pairs the stored record with the plaintext token that is
+// handed to the client exactly once
+type issuedRefreshToken struct {
+	record models.RefreshToken
+	token  string
+}
+
+func (h *AuthHandler) createRefreshToken(tx *gorm.DB, userID uuid.UUID) (*issuedRefreshToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	issued := &issuedRefreshToken{
+		record: models.RefreshToken{
+			UserID:    userID,
+			TokenHash: hashRefreshToken(token),
+			ExpiresAt: time.Now().Add(h.refreshTTL),
+		},
+		token: token,
+	}
+	if err := tx.Create(&issued.record).Error; err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+func (h *AuthHandler) tokenResponse(user *models.User, refreshToken *issuedRefreshToken) (*TokenResponse, error) {
+	accessToken, err := middleware.GenerateToken(user.ID, string(user.Role), h.secretKey, h.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken.token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(h.accessTTL.Seconds()),
+	}, nil
+}
+
+func revokeUserRefreshTokens(tx *gorm.DB, userID uuid.UUID, now time.Time) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
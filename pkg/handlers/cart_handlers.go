@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CartTokenHeader identifies the anonymous cart of a visitor who is not
+// logged in. It is returned whenever an anonymous cart is created.
+const CartTokenHeader = "X-Cart-Token"
+
+var errInvalidCartToken = errors.New("invalid cart token")
+
+// GetCart returns the caller's cart with current prices and stock warnings
+func GetCart(c *gin.Context) {
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
+
+	cart, ok := findCart(c, false)
+	if !ok {
+		return
+	}
+	respondCart(c, cart, currency)
+}
+
+// AddCartItem adds a product to the caller's cart, creating the cart on
+// first use
+func AddCartItem(c *gin.Context) {
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
+
+	var input models.CartItemInput
+	if err := c.ShouldBindJSON(&input); err != nil || input.ProductID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, input.ProductID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found", "product_id": input.ProductID})
+		return
+	}
+
+	cart, ok := findCart(c, true)
+	if !ok {
+		return
+	}
+	if err := database.AddCartItem(database.DB, cart.ID, product.ID, input.Quantity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+		return
+	}
+	reloadAndRespondCart(c, cart, currency)
+}
+
+// UpdateCartItem changes the quantity of a product in the caller's cart
+func UpdateCartItem(c *gin.Context) {
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
+
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var input struct {
+		Quantity int `json:"quantity" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	cart, ok := findCart(c, false)
+	if !ok {
+		return
+	}
+	if cart == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not in cart"})
+		return
+	}
+
+	err = database.SetCartItemQuantity(database.DB, cart.ID, uint(productID), input.Quantity)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not in cart"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+		return
+	}
+	reloadAndRespondCart(c, cart, currency)
+}
+
+// RemoveCartItem takes a product out of the caller's cart
+func RemoveCartItem(c *gin.Context) {
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
+
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	cart, ok := findCart(c, false)
+	if !ok {
+		return
+	}
+	if cart == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not in cart"})
+		return
+	}
+
+	err = database.RemoveCartItem(database.DB, cart.ID, uint(productID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not in cart"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+		return
+	}
+	reloadAndRespondCart(c, cart, currency)
+}
+
+// CheckoutCart turns the user's cart into an order at current prices and
+// empties the cart. It fails like CreateOrder when a product is out of stock,
+// leaving the cart untouched.
+func CheckoutCart(c *gin.Context) {
+	// The currency, coupon and tax region are optional, so an empty body is
+	// accepted
+	var input struct {
+		Currency   models.Currency `json:"currency"`
+		CouponCode string          `json:"coupon_code"`
+		TaxRegion  string          `json:"tax_region"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	order := models.Order{
+		UserID:     userID,
+		Currency:   input.Currency,
+		CouponCode: input.CouponCode,
+		TaxRegion:  input.TaxRegion,
+	}
+
+	// The cart is locked for the whole checkout, so a concurrent checkout
+	// waits and then finds it empty instead of ordering it a second time
+	if !placeOrder(c, &order, func(tx *gorm.DB) error {
+		cart, err := database.LockUserCart(tx, userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && len(cart.Items) == 0) {
+			return &orderRejection{status: http.StatusBadRequest, message: "Cart is empty"}
+		}
+		if err != nil {
+			return err
+		}
+		for _, item := range cart.Items {
+			order.Items = append(order.Items, models.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity})
+		}
+		return database.ClearCart(tx, cart.ID)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// findCart returns the cart of the logged in user or of the anonymous visitor
+// named by the X-Cart-Token header. Without a cart it returns nil, or creates
+// one when create is set. It writes an error response and returns false on
+// failure.
+func findCart(c *gin.Context, create bool) (*models.Cart, bool) {
+	var cart *models.Cart
+	var err error
+
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		if create {
+			cart, err = database.UserCart(database.DB, userID)
+		} else {
+			cart, err = database.FindUserCart(database.DB, userID)
+		}
+	} else {
+		cart, err = findAnonymousCart(c, create)
+	}
+
+	if errors.Is(err, errInvalidCartToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart token"})
+		return nil, false
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
+		return nil, false
+	}
+	return cart, true
+}
+
+// findAnonymousCart looks the cart up by its token. An unknown or missing
+// token gets a new cart when create is set, so a stale token never blocks a
+// visitor from shopping.
+func findAnonymousCart(c *gin.Context, create bool) (*models.Cart, error) {
+	if raw := c.GetHeader(CartTokenHeader); raw != "" {
+		token, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, errInvalidCartToken
+		}
+		cart, err := database.FindAnonymousCart(database.DB, token)
+		if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) || !create {
+			return cart, err
+		}
+	} else if !create {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return database.NewAnonymousCart(database.DB)
+}
+
+// reloadAndRespondCart answers a cart change with the updated cart
+func reloadAndRespondCart(c *gin.Context, cart *models.Cart, currency models.Currency) {
+	var err error
+	if cart.UserID != nil {
+		cart, err = database.FindUserCart(database.DB, *cart.UserID)
+	} else {
+		cart, err = database.FindAnonymousCart(database.DB, *cart.Token)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
+		return
+	}
+	respondCart(c, cart, currency)
+}
+
+// respondCart writes the priced cart, in the base currency unless another
+// was requested
+func respondCart(c *gin.Context, cart *models.Cart, currency models.Currency) {
+	if currency == "" {
+		currency = models.BaseCurrency
+	}
+
+	view, err := database.BuildCartView(database.DB, cart, currency)
+	if errors.Is(err, models.ErrNoExchangeRate) {
+		respondNoExchangeRate(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price cart"})
+		return
+	}
+
+	if view.Token != nil {
+		c.Header(CartTokenHeader, view.Token.String())
+	}
+	c.JSON(http.StatusOK, view)
+}
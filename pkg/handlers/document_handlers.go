@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/documents"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetInvoice returns the invoice PDF of an order, issuing it on the first
+// download
+func GetInvoice(c *gin.Context) {
+	details, ok := orderDetailsForDocument(c)
+	if !ok {
+		return
+	}
+
+	var invoice *models.Invoice
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the order so concurrent first downloads issue one invoice
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, details.Order.ID).Error; err != nil {
+			return err
+		}
+		details.Order.Status = order.Status
+
+		var err error
+		invoice, err = documents.Invoice(tx, details)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, documents.ErrNotInvoiceable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only paid orders can be invoiced"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue invoice"})
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("invoice-%s.pdf", documents.InvoiceNumber(invoice.Number))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/pdf", invoice.Document)
+}
+
+// GetPackingSlip returns the packing slip PDF of an order
+func GetPackingSlip(c *gin.Context) {
+	details, ok := orderDetailsForDocument(c)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("packing-slip-%d.pdf", details.Order.ID)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/pdf", documents.RenderPackingSlip(details))
+}
+
+// orderDetailsForDocument loads the details of the order named in the path
+// if the caller may see it. It writes an error response and returns false
+// otherwise.
+func orderDetailsForDocument(c *gin.Context) (*database.OrderDetails, bool) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return nil, false
+	}
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return nil, false
+	}
+
+	details, err := database.GetOrderDetails(uint(orderID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		}
+		return nil, false
+	}
+	return details, true
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetExchangeRates returns the exchange rate history, optionally filtered by currency
+func GetExchangeRates(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	rates, total, err := database.GetExchangeRateHistory(page, limit, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base_currency":  models.BaseCurrency,
+		"exchange_rates": rates,
+		"pagination": gin.H{
+			"current_page":   page,
+			"total_items":    total,
+			"items_per_page": limit,
+			"total_pages":    (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// CreateExchangeRate records a new rate for a currency. Rates are appended,
+// never updated, so orders keep pointing at the rate they were placed with.
+func CreateExchangeRate(c *gin.Context) {
+	var input models.ExchangeRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	currency, err := models.ParseCurrency(string(input.Currency))
+	if err != nil || currency == models.BaseCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+	if !input.Rate.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rate must be positive"})
+		return
+	}
+
+	rate := models.ExchangeRate{
+		Currency:    currency,
+		Rate:        input.Rate,
+		EffectiveAt: time.Now().UTC(),
+	}
+	if input.EffectiveAt != nil {
+		rate.EffectiveAt = input.EffectiveAt.UTC()
+	}
+
+	if err := database.DB.Create(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rate)
+}
+
+// requestedCurrency reads the optional ?currency= query parameter. It writes
+// a 400 response and returns false when the currency is not supported.
+func requestedCurrency(c *gin.Context) (models.Currency, bool) {
+	raw := c.Query("currency")
+	if raw == "" {
+		return "", true
+	}
+	currency, err := models.ParseCurrency(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return "", false
+	}
+	return currency, true
+}
+
+// respondNoExchangeRate reports a conversion that failed for lack of a rate
+func respondNoExchangeRate(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Exchange rate not available", "details": err.Error()})
+}
@@ -2,23 +2,101 @@ package handlers
 
 import (
 	"errors"
-	"fullstacktest/pkg/database"
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/inventory"
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/numbering"
+	"github.com/alzarasatken/FullStackTest/pkg/promotions"
+	"github.com/alzarasatken/FullStackTest/pkg/tax"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// reservationTTL is how long a new order holds its stock before it expires
+var reservationTTL = inventory.DefaultTTL
+
+// SetReservationTTL configures how long new orders hold their stock
+func SetReservationTTL(ttl time.Duration) {
+	if ttl > 0 {
+		reservationTTL = ttl
+	}
+}
+
+// taxRegion is the region orders are taxed in unless they name another
+var taxRegion = models.DefaultTaxRegion
+
+// SetTaxRegion configures the default tax region of new orders
+func SetTaxRegion(region string) {
+	if region = tax.NormalizeRegion(region); region != "" {
+		taxRegion = region
+	}
+}
+
+// orderNumbers numbers new orders
+var orderNumbers = numbering.NewGenerator(numbering.DefaultConfig)
+
+// SetOrderNumbering configures the format of new order numbers
+func SetOrderNumbering(config numbering.Config) {
+	orderNumbers = numbering.NewGenerator(config)
+}
+
 // CreateOrder creates a new order with items
 func CreateOrder(c *gin.Context) {
-	var order models.Order
-	if err := c.ShouldBindJSON(&order); err != nil {
+	var input models.OrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
+	// Customers always order for themselves; staff may place an order on a
+	// customer's behalf by passing user_id
+	userID := input.UserID
+	if _, restricted := ownOrdersOnly(c); restricted || userID == uuid.Nil {
+		userID, _ = middleware.CurrentUserID(c)
+	}
+
+	order := input.ToOrder(userID)
+	if !placeOrder(c, order, nil) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// orderRejection is returned by a placeOrder hook to refuse the order with a
+// client error rather than a server one
+type orderRejection struct {
+	status  int
+	message string
+}
+
+func (e *orderRejection) Error() string {
+	return e.message
+}
+
+// placeOrder prices, stores and reserves stock for a new order, writing an
+// error response and returning false when it cannot. prepare, when set, runs
+// first in the same transaction and may fill in the order's items. On success
+// the order is reloaded with its items and user.
+func placeOrder(c *gin.Context, order *models.Order, prepare func(tx *gorm.DB) error) bool {
+	if order.Currency == "" {
+		order.Currency = models.BaseCurrency
+	}
+	if !order.Currency.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return false
+	}
+	if order.TaxRegion = tax.NormalizeRegion(order.TaxRegion); order.TaxRegion == "" {
+		order.TaxRegion = taxRegion
+	}
+
 	// Start a transaction
 	tx := database.DB.Begin()
 	defer func() {
@@ -27,68 +105,164 @@ func CreateOrder(c *gin.Context) {
 		}
 	}()
 
-	// Calculate total and validate stock
-	var total float64
+	if prepare != nil {
+		if err := prepare(tx); err != nil {
+			tx.Rollback()
+			var rejection *orderRejection
+			if errors.As(err, &rejection) {
+				c.JSON(rejection.status, gin.H{"error": rejection.message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+			}
+			return false
+		}
+	}
+
+	// Snapshot the exchange rate so the total can be reproduced later
+	rates, err := database.LatestExchangeRates(tx, time.Now())
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exchange rates"})
+		return false
+	}
+	if order.ExchangeRate, err = rates.Rate(order.Currency); err != nil {
+		tx.Rollback()
+		respondNoExchangeRate(c, err)
+		return false
+	}
+
+	// Price the items; stock is reserved once the order exists
+	basket := promotions.Basket{Rate: order.ExchangeRate}
 	for i, item := range order.Items {
-		var product models.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
+		if item.Quantity < 1 {
 			tx.Rollback()
-			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found", "product_id": item.ProductID})
-			return
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quantity", "product_id": item.ProductID})
+			return false
 		}
 
-		if product.Stock < item.Quantity {
+		var product models.Product
+		if err := tx.First(&product, item.ProductID).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Insufficient stock",
-				"product_id": item.ProductID,
-				"available": product.Stock,
-				"requested": item.Quantity,
-			})
-			return
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found", "product_id": item.ProductID})
+			return false
 		}
 
-		// Update stock
-		if err := tx.Model(&product).Update("stock", product.Stock-item.Quantity).Error; err != nil {
+		// Set item price from current product price, converted through the
+		// base currency with the snapshotted rate
+		basePrice, err := rates.Convert(product.Price, product.Currency, models.BaseCurrency)
+		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
-			return
+			respondNoExchangeRate(c, err)
+			return false
 		}
+		order.Items[i].BasePrice = basePrice
+		order.Items[i].Price = basePrice.Convert(models.RateOne, order.ExchangeRate)
+		order.Items[i].TaxClass = product.TaxClass
+		order.Items[i].ProductExternalID = product.ExternalID
+		basket.Lines = append(basket.Lines, promotions.Line{
+			ProductID: product.ID,
+			SKU:       product.SKU,
+			Category:  product.Category,
+			Quantity:  item.Quantity,
+			Price:     order.Items[i].Price,
+			BasePrice: basePrice,
+		})
+	}
 
-		// Set item price from current product price
-		order.Items[i].Price = product.Price
-		total += product.Price * float64(item.Quantity)
+	// Discounts become adjustment lines; Total is the subtotal plus them
+	if err := promotions.Apply(tx, order, basket, time.Now()); err != nil {
+		tx.Rollback()
+		respondPromotionError(c, err)
+		return false
 	}
 
-	order.Total = total
+	// Split the VAT out of every line at the rates of the order's region
+	taxRates, err := tax.LoadRates(tx, order.TaxRegion)
+	if err == nil {
+		err = tax.Apply(order, taxRates)
+	}
+	if err != nil {
+		tx.Rollback()
+		respondTaxError(c, err)
+		return false
+	}
 	order.Status = models.OrderStatusPending
+	// 1C assigns its own references once it receives the order
+	order.ExternalID, order.UserExternalID, order.Synced = "", "", false
+
+	// Numbering serialises order placement until the commit, so it comes last
+	if order.Number, err = orderNumbers.Next(tx); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to number order"})
+		return false
+	}
 
-	if err := tx.Create(&order).Error; err != nil {
+	if err := tx.Create(order).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
-		return
+		return false
+	}
+
+	if err := database.RecordOrderCreated(tx, order, currentActor(c)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record order history"})
+		return false
+	}
+
+	if err := promotions.Redeem(tx, order); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem promotions"})
+		return false
+	}
+
+	// Hold the stock until the order is paid or the reservation expires
+	if err := inventory.Reserve(tx, order.ID, order.Items, reservationTTL); err != nil {
+		tx.Rollback()
+		var insufficient *inventory.InsufficientStockError
+		if errors.As(err, &insufficient) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":      "Insufficient stock",
+				"product_id": insufficient.ProductID,
+				"available":  insufficient.Available,
+				"requested":  insufficient.Requested,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve stock"})
+		}
+		return false
 	}
 
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
+		return false
 	}
 
 	// Reload order with all relationships
-	if err := database.DB.Preload("Items.Product").Preload("User").First(&order, order.ID).Error; err != nil {
+	if err := database.DB.Preload("Items.Product").Preload("Adjustments").Preload("User").First(order, order.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order details"})
-		return
+		return false
 	}
 
-	c.JSON(http.StatusCreated, order)
+	return true
 }
 
-// GetOrders returns a paginated list of orders with optional filters
+// GetOrders returns a paginated list of orders with optional filters;
+// ?number= matches part of the order number
 func GetOrders(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	userID := c.Query("user_id")
 	status := c.Query("status")
+	number := c.Query("number")
+
+	var userID *uuid.UUID
+	if raw := c.Query("user_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		userID = &parsed
+	}
 
 	if page < 1 {
 		page = 1
@@ -97,7 +271,12 @@ func GetOrders(c *gin.Context) {
 		limit = 10
 	}
 
-	orders, total, err := database.GetOrdersWithDetails(page, limit, userID, status)
+	// Customers only ever see their own orders, whatever filter they pass
+	if ownerID, restricted := ownOrdersOnly(c); restricted {
+		userID = &ownerID
+	}
+
+	orders, total, err := database.GetOrdersWithDetails(page, limit, userID, status, number)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
@@ -123,6 +302,11 @@ func GetOrder(c *gin.Context) {
 		return
 	}
 
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
 	orderDetails, err := database.GetOrderDetails(uint(orderID))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -136,11 +320,28 @@ func GetOrder(c *gin.Context) {
 	c.JSON(http.StatusOK, orderDetails)
 }
 
+// OrderHandler changes the status of orders
+type OrderHandler struct {
+	machine *fsm.Machine
+}
+
+// NewOrderHandler creates a new OrderHandler instance; machine enforces the
+// order lifecycle for every status change
+func NewOrderHandler(machine *fsm.Machine) *OrderHandler {
+	return &OrderHandler{machine: machine}
+}
+
 // UpdateOrderStatus updates the status of an order
-func UpdateOrderStatus(c *gin.Context) {
-	id := c.Param("id")
+func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
 	var statusUpdate struct {
 		Status models.OrderStatus `json:"status" binding:"required"`
+		Reason string             `json:"reason"`
 	}
 
 	if err := c.ShouldBindJSON(&statusUpdate); err != nil {
@@ -148,31 +349,58 @@ func UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	// Validate status transition
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
 	var order models.Order
-	if err := database.DB.First(&order, id).Error; err != nil {
+	if err := tx.First(&order, uint(orderID)).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
 
-	// Validate status transition
-	if !isValidStatusTransition(order.Status, statusUpdate.Status) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status transition"})
+	if err := h.machine.Fire(tx, &order, statusUpdate.Status, currentActor(c), statusUpdate.Reason); err != nil {
+		tx.Rollback()
+		respondStatusChangeError(c, err, "Failed to update order status")
 		return
 	}
 
-	if err := database.DB.Model(&order).Update("status", statusUpdate.Status).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
-// CancelOrder cancels an order and restores product stock
-func CancelOrder(c *gin.Context) {
-	id := c.Param("id")
-	
+// CancelOrder cancels an order; the state machine releases its stock
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	// The reason is optional, so an empty body is accepted
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
 	tx := database.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -181,7 +409,7 @@ func CancelOrder(c *gin.Context) {
 	}()
 
 	var order models.Order
-	if err := tx.Preload("Items").First(&order, id).Error; err != nil {
+	if err := tx.First(&order, uint(orderID)).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
@@ -193,61 +421,91 @@ func CancelOrder(c *gin.Context) {
 		return
 	}
 
-	if order.Status == models.OrderStatusDelivered {
+	// The state machine rejects delivered orders and releases the stock
+	if err := h.machine.Fire(tx, &order, models.OrderStatusCancelled, currentActor(c), input.Reason); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot cancel delivered order"})
+		respondStatusChangeError(c, err, "Failed to cancel order")
 		return
 	}
 
-	// Restore stock for each item
-	for _, item := range order.Items {
-		if err := tx.Model(&models.Product{}).
-			Where("id = ?", item.ProductID).
-			Update("stock", gorm.Expr("stock + ?", item.Quantity)).
-			Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore stock"})
-			return
-		}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
 	}
 
-	if err := tx.Model(&order).Update("status", models.OrderStatusCancelled).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+	c.Status(http.StatusOK)
+}
+
+// GetOrderHistory returns the audited status transitions of an order
+func GetOrderHistory(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
 		return
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
 
-	c.Status(http.StatusOK)
+	history, err := database.GetOrderStatusHistory(uint(orderID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order history"})
+		return
+	}
+	if len(history) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "history": history})
 }
 
-// Helper function to validate order status transitions
-func isValidStatusTransition(current, new models.OrderStatus) bool {
-	validTransitions := map[models.OrderStatus][]models.OrderStatus{
-		models.OrderStatusPending: {
-			models.OrderStatusPaid,
-			models.OrderStatusCancelled,
-		},
-		models.OrderStatusPaid: {
-			models.OrderStatusShipped,
-			models.OrderStatusCancelled,
-		},
-		models.OrderStatusShipped: {
-			models.OrderStatusDelivered,
-			models.OrderStatusCancelled,
-		},
-		models.OrderStatusDelivered: {},
-		models.OrderStatusCancelled: {},
+// respondPromotionError maps a coupon that cannot be used to a response
+func respondPromotionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, promotions.ErrCouponNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon code"})
+	case errors.Is(err, promotions.ErrInactive):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon is not active"})
+	case errors.Is(err, promotions.ErrNotApplicable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon does not apply to this order", "details": err.Error()})
+	case errors.Is(err, promotions.ErrLimitReached):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon usage limit reached", "details": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply promotions"})
 	}
+}
 
-	for _, validStatus := range validTransitions[current] {
-		if validStatus == new {
-			return true
-		}
+// respondTaxError reports why an order's taxes could not be computed
+func respondTaxError(c *gin.Context, err error) {
+	var missing *tax.MissingRateError
+	switch {
+	case errors.Is(err, tax.ErrUnknownRegion):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported tax region", "details": err.Error()})
+	case errors.As(err, &missing):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "No tax rate for product",
+			"product_id": missing.ProductID,
+			"tax_class":  missing.TaxClass,
+			"region":     missing.Region,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate taxes"})
 	}
-	return false
-} 
\ No newline at end of file
+}
+
+// respondStatusChangeError maps a failed state machine transition to a response
+func respondStatusChangeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, fsm.ErrInvalidTransition):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status transition", "details": err.Error()})
+	case errors.Is(err, fsm.ErrTransitionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Status transition not allowed", "details": err.Error()})
+	case errors.Is(err, database.ErrOrderStatusChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order status was changed by another request"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
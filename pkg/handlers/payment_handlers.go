@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
+	"github.com/alzarasatken/FullStackTest/pkg/payments"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PaymentHandler takes payments for orders through a payment provider
+type PaymentHandler struct {
+	provider payments.Provider
+	machine  *fsm.Machine
+}
+
+// NewPaymentHandler creates a new PaymentHandler instance
+func NewPaymentHandler(provider payments.Provider, machine *fsm.Machine) *PaymentHandler {
+	return &PaymentHandler{provider: provider, machine: machine}
+}
+
+// CreatePayment starts paying a pending order. The response carries the
+// client_secret the client completes the payment with at the provider; the
+// order becomes paid once the provider's webhook confirms it.
+func (h *PaymentHandler) CreatePayment(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var order models.Order
+	if err := database.DB.First(&order, orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	payment, err := payments.Start(c.Request.Context(), database.DB, h.provider, &order)
+	if errors.Is(err, payments.ErrOrderNotPayable) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order cannot be paid", "status": order.Status})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create payment", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, payment)
+}
+
+// GetOrderPayments lists the payments of an order, newest first
+func (h *PaymentHandler) GetOrderPayments(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var results []models.Payment
+	if err := database.DB.Where("order_id = ?", orderID).Order("id DESC").Find(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "payments": results})
+}
+
+// Webhook receives payment events from the provider. Captured payments move
+// their order to paid through the order state machine. Errors other than a
+// bad signature make the provider deliver the event again.
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	event, err := h.provider.VerifyWebhook(payload, c.Request.Header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook", "details": err.Error()})
+		return
+	}
+
+	var payment *models.Payment
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		payment, err = payments.HandleEvent(c.Request.Context(), tx, h.provider, event, func(tx *gorm.DB, order *models.Order) error {
+			return h.machine.Fire(tx, order, models.OrderStatusPaid, models.PaymentActor, "payment "+event.IntentID+" captured")
+		})
+		return err
+	})
+	if errors.Is(err, payments.ErrPaymentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment event", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment_id": payment.ID, "status": payment.Status})
+}
+
+// RefundPayment refunds part of a captured payment, or all that is left of
+// it when no amount is given, and returns the refund record. The order's
+// status is not changed; returns are refunded through the returns workflow.
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	paymentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	// The amount is optional, so an empty body is accepted
+	var input struct {
+		Amount *models.Money `json:"amount"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	var refund *models.Refund
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		refund, err = payments.Refund(c.Request.Context(), tx, h.provider, uint(paymentID), input.Amount)
+		return err
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, refund)
+	case errors.Is(err, payments.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+	case errors.Is(err, payments.ErrNotRefundable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment cannot be refunded"})
+	case errors.Is(err, payments.ErrRefundTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund amount", "details": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund payment", "details": err.Error()})
+	}
+}
@@ -1,23 +1,34 @@
 package handlers
 
 import (
-	"fullstacktest/pkg/database"
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // CreateProduct creates a new product
 func CreateProduct(c *gin.Context) {
-	var product models.Product
-	if err := c.ShouldBindJSON(&product); err != nil {
+	var input models.NewProductInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	product := input.ToProduct()
+	if !validProductCurrency(product) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+	if !validProductTaxClass(c, product) {
+		return
+	}
+	now := time.Now()
+	product.ModifiedBy, product.ModifiedAt = models.ProductSourceShop, &now
 
-	if err := database.DB.Create(&product).Error; err != nil {
+	if err := database.DB.Create(product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 		return
 	}
@@ -30,9 +41,21 @@ func GetProducts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	nameFilter := c.DefaultQuery("name", "")
-	minPrice, _ := strconv.ParseFloat(c.DefaultQuery("min_price", "0"), 64)
-	maxPrice, _ := strconv.ParseFloat(c.DefaultQuery("max_price", "999999"), 64)
+	minPrice, err := models.ParseMoney(c.DefaultQuery("min_price", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_price"})
+		return
+	}
+	maxPrice, err := models.ParseMoney(c.DefaultQuery("max_price", "999999"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_price"})
+		return
+	}
 	inStock := c.DefaultQuery("in_stock", "") == "true"
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
 
 	if page < 1 {
 		page = 1
@@ -47,6 +70,25 @@ func GetProducts(c *gin.Context) {
 		return
 	}
 
+	if currency != "" {
+		rates, err := database.LatestExchangeRates(database.DB, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exchange rates"})
+			return
+		}
+		for i := range products {
+			p := &products[i]
+			if p.Price, err = rates.Convert(p.Price, p.Currency, currency); err == nil {
+				p.Revenue, err = rates.Convert(p.Revenue, models.BaseCurrency, currency)
+			}
+			if err != nil {
+				respondNoExchangeRate(c, err)
+				return
+			}
+			p.Currency = currency
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"products": products,
 		"pagination": gin.H{
@@ -58,22 +100,50 @@ func GetProducts(c *gin.Context) {
 	})
 }
 
-// GetProduct returns a single product by ID
+// GetProduct returns a single product by ID, optionally priced in ?currency=
 func GetProduct(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
 	var product models.Product
 
+	currency, ok := requestedCurrency(c)
+	if !ok {
+		return
+	}
+
 	if err := database.DB.First(&product, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
 
+	if currency != "" && currency != product.Currency {
+		rates, err := database.LatestExchangeRates(database.DB, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exchange rates"})
+			return
+		}
+		if product.Price, err = rates.Convert(product.Price, product.Currency, currency); err != nil {
+			respondNoExchangeRate(c, err)
+			return
+		}
+		product.Currency = currency
+	}
+
 	c.JSON(http.StatusOK, product)
 }
 
 // UpdateProduct updates a product
 func UpdateProduct(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
 	var product models.Product
 
 	if err := database.DB.First(&product, id).Error; err != nil {
@@ -81,12 +151,27 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&product); err != nil {
+	var input models.ProductInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	columns := input.Apply(&product)
+	if !validProductCurrency(&product) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+	if !validProductTaxClass(c, &product) {
+		return
+	}
+
+	now := time.Now()
+	product.ModifiedBy, product.ModifiedAt = models.ProductSourceShop, &now
 
-	if err := database.DB.Save(&product).Error; err != nil {
+	// Only the edited columns are written, so a concurrent reservation, stock
+	// change or sync with 1C is never undone
+	columns = append(columns, "modified_by", "modified_at")
+	if err := database.DB.Model(&product).Select(columns).Updates(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
 		return
 	}
@@ -96,7 +181,12 @@ func UpdateProduct(c *gin.Context) {
 
 // DeleteProduct soft deletes a product
 func DeleteProduct(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
 	var product models.Product
 
 	if err := database.DB.First(&product, id).Error; err != nil {
@@ -114,7 +204,12 @@ func DeleteProduct(c *gin.Context) {
 
 // UpdateStock updates the stock quantity of a product
 func UpdateStock(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
 	var stockUpdate struct {
 		Quantity int `json:"quantity" binding:"required"`
 	}
@@ -124,18 +219,60 @@ func UpdateStock(c *gin.Context) {
 		return
 	}
 
+	// Stock held by pending orders cannot be taken away; the condition keeps
+	// the check atomic with concurrent reservations
 	result := database.DB.Model(&models.Product{}).
-		Where("id = ?", id).
-		Update("stock", stockUpdate.Quantity)
+		Where("id = ? AND reserved <= ?", id, stockUpdate.Quantity).
+		Updates(map[string]interface{}{
+			"stock":       stockUpdate.Quantity,
+			"modified_by": models.ProductSourceShop,
+			"modified_at": time.Now(),
+		})
 
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		var product models.Product
+		if err := database.DB.First(&product, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    "Stock cannot be set below the reserved quantity",
+			"reserved": product.Reserved,
+		})
 		return
 	}
 
 	c.Status(http.StatusOK)
-} 
\ No newline at end of file
+}
+
+// validProductTaxClass defaults the product's tax class to the standard one
+// and checks that some region has a rate for it. It writes an error response
+// and returns false otherwise.
+func validProductTaxClass(c *gin.Context, product *models.Product) bool {
+	if product.TaxClass == "" {
+		product.TaxClass = models.TaxClassStandard
+	}
+	exists, err := database.TaxClassExists(product.TaxClass)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check tax class"})
+		return false
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown tax class", "tax_class": product.TaxClass})
+		return false
+	}
+	return true
+}
+
+// validProductCurrency defaults the product's currency to the base currency
+// and reports whether it is supported
+func validProductCurrency(product *models.Product) bool {
+	if product.Currency == "" {
+		product.Currency = models.BaseCurrency
+	}
+	return product.Currency.IsValid()
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/promotions"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetPromotions returns a paginated list of promotions and coupons
+func GetPromotions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	activeOnly := c.Query("active") == "true"
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	results, total, err := database.GetPromotions(page, limit, activeOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promotions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"promotions": results,
+		"pagination": gin.H{
+			"current_page":   page,
+			"total_items":    total,
+			"items_per_page": limit,
+			"total_pages":    (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetPromotion returns a single promotion by ID
+func GetPromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	var promotion models.Promotion
+	if err := database.DB.Preload("Targets").First(&promotion, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+// CreatePromotion creates an automatic promotion, or a coupon when a code is
+// given
+func CreatePromotion(c *gin.Context) {
+	var promotion models.Promotion
+	if err := c.ShouldBindJSON(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	promotion.ID = 0
+	promotion.UsageCount = 0
+
+	if !preparePromotion(c, &promotion) {
+		return
+	}
+
+	if err := database.DB.Create(&promotion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save promotion"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, promotion)
+}
+
+// UpdatePromotion replaces a promotion's rules and targets. Orders that
+// already used it keep their discounts.
+func UpdatePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	var promotion models.Promotion
+	if err := database.DB.First(&promotion, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return
+	}
+
+	promotion.Targets = nil
+	if err := c.ShouldBindJSON(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	promotion.ID = uint(id)
+
+	if !preparePromotion(c, &promotion) {
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return database.SavePromotion(tx, &promotion)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save promotion"})
+		return
+	}
+
+	if err := database.DB.Preload("Targets").First(&promotion, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load promotion"})
+		return
+	}
+	c.JSON(http.StatusOK, promotion)
+}
+
+// DeletePromotion switches a promotion off. Promotions are kept so that the
+// discounts of past orders can still be traced to them.
+func DeletePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	result := database.DB.Model(&models.Promotion{}).
+		Where("id = ?", id).
+		Update("active", false)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete promotion"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// preparePromotion normalises and validates a promotion from a request,
+// writing an error response and returning false when it cannot be saved
+func preparePromotion(c *gin.Context, promotion *models.Promotion) bool {
+	if promotion.Code != nil {
+		code := promotions.NormalizeCode(*promotion.Code)
+		promotion.Code = &code
+	}
+	for i := range promotion.Targets {
+		promotion.Targets[i].Value = strings.TrimSpace(promotion.Targets[i].Value)
+	}
+
+	if err := promotions.Validate(promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion", "details": err.Error()})
+		return false
+	}
+
+	if promotion.Code != nil {
+		var taken int64
+		if err := database.DB.Model(&models.Promotion{}).
+			Where("code = ? AND id <> ?", *promotion.Code, promotion.ID).
+			Count(&taken).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save promotion"})
+			return false
+		}
+		if taken > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
+	"github.com/alzarasatken/FullStackTest/pkg/payments"
+	"github.com/alzarasatken/FullStackTest/pkg/returns"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReturnHandler runs the returns workflow of delivered orders. Completed
+// returns are refunded through the payment provider.
+type ReturnHandler struct {
+	provider payments.Provider
+	machine  *fsm.Machine
+}
+
+// NewReturnHandler creates a new ReturnHandler instance
+func NewReturnHandler(provider payments.Provider, machine *fsm.Machine) *ReturnHandler {
+	return &ReturnHandler{provider: provider, machine: machine}
+}
+
+// CreateReturn lets a customer request the return of items of a delivered
+// order
+func (h *ReturnHandler) CreateReturn(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var input models.ReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	items := make([]returns.ItemRequest, len(input.Items))
+	for i, item := range input.Items {
+		items[i] = returns.ItemRequest{OrderItemID: item.OrderItemID, Quantity: item.Quantity}
+	}
+
+	var ret *models.Return
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+		ret, err = returns.Request(tx, &order, order.UserID, input.Reason, items)
+		return err
+	})
+	if err != nil {
+		respondReturnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ret)
+}
+
+// GetOrderReturns lists the returns of an order
+func (h *ReturnHandler) GetOrderReturns(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	results, err := database.GetOrderReturns(uint(orderID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch returns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "returns": results})
+}
+
+// GetReturns returns a paginated list of all returns, optionally filtered by
+// ?status=
+func (h *ReturnHandler) GetReturns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	status := models.ReturnStatus(c.Query("status"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	results, total, err := database.GetReturns(page, limit, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch returns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"returns": results,
+		"pagination": gin.H{
+			"current_page":   page,
+			"total_items":    total,
+			"items_per_page": limit,
+			"total_pages":    (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetReturn returns a single return; customers only see their own
+func (h *ReturnHandler) GetReturn(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return
+	}
+
+	ret, err := returns.Load(database.DB, uint(id), false)
+	if err != nil || !canAccessOrder(c, ret.OrderID) {
+		respondReturnError(c, returns.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// ApproveReturn accepts a requested return
+func (h *ReturnHandler) ApproveReturn(c *gin.Context) {
+	h.resolve(c, func(tx *gorm.DB, ret *models.Return, input *resolutionInput) error {
+		return returns.Approve(tx, ret, input.Resolution)
+	})
+}
+
+// RejectReturn turns down a requested or approved return
+func (h *ReturnHandler) RejectReturn(c *gin.Context) {
+	h.resolve(c, func(tx *gorm.DB, ret *models.Return, input *resolutionInput) error {
+		return returns.Reject(tx, ret, input.Resolution)
+	})
+}
+
+// ReceiveReturn completes an approved return once its goods have arrived:
+// with restock they go back into stock, the items are refunded and the order
+// moves to partially_refunded or refunded
+func (h *ReturnHandler) ReceiveReturn(c *gin.Context) {
+	actor := currentActor(c)
+	h.resolve(c, func(tx *gorm.DB, ret *models.Return, input *resolutionInput) error {
+		return returns.Complete(c.Request.Context(), tx, h.provider, ret, input.Restock,
+			func(tx *gorm.DB, order *models.Order, status models.OrderStatus) error {
+				reason := "return " + strconv.FormatUint(uint64(ret.ID), 10) + " refunded"
+				return h.machine.Fire(tx, order, status, actor, reason)
+			})
+	})
+}
+
+// resolutionInput is the optional body of the return review endpoints
+type resolutionInput struct {
+	Resolution string `json:"resolution"`
+	Restock    bool   `json:"restock"`
+}
+
+// resolve loads and locks the return named in the path, runs action on it in
+// a transaction and responds with the updated return
+func (h *ReturnHandler) resolve(c *gin.Context, action func(tx *gorm.DB, ret *models.Return, input *resolutionInput) error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return
+	}
+
+	var input resolutionInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	var ret *models.Return
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if ret, err = returns.Load(tx, uint(id), true); err != nil {
+			return err
+		}
+		return action(tx, ret, &input)
+	})
+	if err != nil {
+		respondReturnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// respondReturnError maps a failed returns action to a response
+func respondReturnError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, returns.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, returns.ErrOrderNotReturnable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only delivered orders can be returned"})
+	case errors.Is(err, returns.ErrInvalidItem):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return item", "details": err.Error()})
+	case errors.Is(err, returns.ErrInvalidStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return status", "details": err.Error()})
+	case errors.Is(err, payments.ErrRefundTooLarge), errors.Is(err, payments.ErrNotRefundable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment cannot be refunded", "details": err.Error()})
+	default:
+		respondStatusChangeError(c, err, "Failed to process return")
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
+	"github.com/alzarasatken/FullStackTest/pkg/shipping"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShipmentHandler ships paid orders through a carrier
+type ShipmentHandler struct {
+	carrier shipping.Carrier
+	machine *fsm.Machine
+}
+
+// NewShipmentHandler creates a new ShipmentHandler instance
+func NewShipmentHandler(carrier shipping.Carrier, machine *fsm.Machine) *ShipmentHandler {
+	return &ShipmentHandler{carrier: carrier, machine: machine}
+}
+
+// CreateShipment books a shipment of some or all of the items of a paid
+// order. The shipment that leaves nothing to ship moves the order to shipped.
+func (h *ShipmentHandler) CreateShipment(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var input models.ShipmentInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+	items := make([]shipping.ItemRequest, len(input.Items))
+	for i, item := range input.Items {
+		items[i] = shipping.ItemRequest{OrderItemID: item.OrderItemID, Quantity: item.Quantity}
+	}
+
+	actor := currentActor(c)
+	var shipment *models.Shipment
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+		shipment, err = shipping.Create(c.Request.Context(), tx, h.carrier, &order, items,
+			func(tx *gorm.DB, order *models.Order, status models.OrderStatus) error {
+				return h.machine.Fire(tx, order, status, actor, "all items shipped")
+			})
+		return err
+	})
+	if err != nil {
+		respondShipmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, shipment)
+}
+
+// GetOrderShipments lists the shipments of an order with their tracking
+// codes
+func (h *ShipmentHandler) GetOrderShipments(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if !canAccessOrder(c, uint(orderID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var shipments []models.Shipment
+	if err := database.DB.Preload("Items").
+		Where("order_id = ?", orderID).
+		Order("id").
+		Find(&shipments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shipments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "shipments": shipments})
+}
+
+// respondShipmentError maps a failed shipment to a response
+func respondShipmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, shipping.ErrOrderNotShippable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only paid orders can be shipped"})
+	case errors.Is(err, shipping.ErrNothingToShip):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Every item has been shipped already"})
+	case errors.Is(err, shipping.ErrInvalidItem):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment item", "details": err.Error()})
+	default:
+		respondStatusChangeError(c, err, "Failed to create shipment")
+	}
+}
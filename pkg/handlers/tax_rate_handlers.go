@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/tax"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTaxRates returns the VAT rates, optionally filtered by ?region=
+func GetTaxRates(c *gin.Context) {
+	rates, err := database.GetTaxRates(tax.NormalizeRegion(c.Query("region")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tax rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"default_region": taxRegion, "tax_rates": rates})
+}
+
+// SetTaxRate sets the rate of a tax class in a region, creating it when
+// missing. Orders already placed keep the rate they were taxed at.
+func SetTaxRate(c *gin.Context) {
+	var input models.TaxRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	rate := models.TaxRate{
+		Region:   tax.NormalizeRegion(input.Region),
+		TaxClass: models.TaxClass(strings.ToLower(strings.TrimSpace(string(input.TaxClass)))),
+		Percent:  input.Percent,
+	}
+	if rate.Region == "" || rate.TaxClass == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Region and tax class are required"})
+		return
+	}
+
+	if err := database.SaveTaxRate(&rate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tax rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// DeleteTaxRate removes the rate of a tax class in a region
+func DeleteTaxRate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax rate ID"})
+		return
+	}
+
+	result := database.DB.Delete(&models.TaxRate{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tax rate"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tax rate not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -3,24 +3,19 @@ package handlers
 import (
 	"net/http"
 
-	"fullstacktest/pkg/database"
-	"fullstacktest/pkg/models"
-	"fullstacktest/pkg/utils"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // UserHandler handles HTTP requests for users
-type UserHandler struct {
-	db *database.DB
-}
+type UserHandler struct{}
 
 // NewUserHandler creates a new UserHandler instance
 func NewUserHandler() *UserHandler {
-	return &UserHandler{
-		db: database.DB,
-	}
+	return &UserHandler{}
 }
 
 // GetUsers godoc
@@ -58,6 +53,11 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
+	if !canAccessUser(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
 	var user models.User
 	result := database.DB.First(&user, "id = ?", id)
 	if result.Error != nil {
@@ -116,6 +116,11 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if !canAccessUser(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
 	var user models.User
 	if err := database.DB.First(&user, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -164,6 +169,11 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	if !canAccessUser(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
 	result := database.DB.Delete(&models.User{}, "id = ?", id)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting user"})
@@ -176,4 +186,41 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	}
 
 	c.Status(http.StatusNoContent)
-} 
\ No newline at end of file
+}
+
+// UpdateUserRole godoc
+// @Summary Change a user's role
+// @Description Assign a role to a user; requires the users:manage permission
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param role body models.RoleInput true "New role"
+// @Success 200 {object} models.User
+// @Router /users/{id}/role [put]
+func (h *UserHandler) UpdateUserRole(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input models.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil || !input.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := database.DB.Model(&user).Update("role", input.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
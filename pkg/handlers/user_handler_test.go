@@ -7,24 +7,54 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	"fullstacktest/pkg/database"
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/migrations"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
-func setupTestRouter() *gin.Engine {
+const testDSN = "host=localhost user=postgres password=postgres dbname=fullstacktest_test port=5432 sslmode=disable"
+
+func setupTestRouter(t *testing.T) *gin.Engine {
+	db, err := database.Open(testDSN)
+	if err == nil {
+		err = ping(db)
+	}
+	if err != nil {
+		t.Skipf("test database unavailable: %v", err)
+	}
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	database.DB = db
+
+	handler := NewUserHandler()
 	router := gin.Default()
-	router.GET("/api/users", GetUsers)
-	router.POST("/api/users", CreateUser)
-	router.PUT("/api/users/:id", UpdateUser)
-	router.DELETE("/api/users/:id", DeleteUser)
+	router.GET("/api/users", handler.GetUsers)
+	router.POST("/api/users", handler.CreateUser)
+	router.PUT("/api/users/:id", handler.UpdateUser)
+	router.DELETE("/api/users/:id", handler.DeleteUser)
 	return router
 }
 
+// ping checks that the test database accepts connections
+func ping(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
 func TestGetUsers(t *testing.T) {
-	router := setupTestRouter()
+	router := setupTestRouter(t)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/users", nil)
@@ -34,11 +64,14 @@ func TestGetUsers(t *testing.T) {
 }
 
 func TestCreateUser(t *testing.T) {
-	router := setupTestRouter()
+	router := setupTestRouter(t)
 
-	user := models.User{
-		Name:  "Test User",
-		Email: "test@example.com",
+	user := models.UserInput{
+		Email:     "test@example.com",
+		Password:  "secret123",
+		FirstName: "Test",
+		LastName:  "User",
+		Phone:     "+79990000000",
 	}
 	jsonValue, _ := json.Marshal(user)
 
@@ -52,9 +85,10 @@ func TestCreateUser(t *testing.T) {
 	var response models.User
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, user.Name, response.Name)
+	assert.Equal(t, user.FirstName, response.FirstName)
+	assert.Equal(t, user.LastName, response.LastName)
 	assert.Equal(t, user.Email, response.Email)
 
 	// Cleanup
 	database.DB.Unscoped().Delete(&response)
-} 
\ No newline at end of file
+}
@@ -1,12 +1,13 @@
 package handlers
 
 import (
-	"fullstacktest/pkg/database"
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // GetUsersWithOrders returns a paginated list of users with their latest order
@@ -41,20 +42,25 @@ func GetUsersWithOrders(c *gin.Context) {
 
 // GetUserOrderSummary returns order statistics for a specific user
 func GetUserOrderSummary(c *gin.Context) {
-	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
+	if !canAccessUser(c, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
 	// Check if user exists
 	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	summary, err := database.GetUserOrderSummary(uint(userID))
+	summary, err := database.GetUserOrderSummary(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order summary"})
 		return
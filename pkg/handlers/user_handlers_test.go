@@ -2,22 +2,31 @@ package handlers_test
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
-	"fullstacktest/pkg/database"
-	"fullstacktest/pkg/handlers"
-	"fullstacktest/pkg/models"
-	"fullstacktest/pkg/router"
+	"github.com/alzarasatken/FullStackTest/migrations"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
+	"github.com/alzarasatken/FullStackTest/pkg/payments"
+	"github.com/alzarasatken/FullStackTest/pkg/router"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
+const testJWTSecret = "test-secret"
+
 var (
 	testDB     *gorm.DB
 	testRouter http.Handler
@@ -25,13 +34,26 @@ var (
 
 func setupTestDB(t *testing.T) {
 	var err error
-	testDB, err = database.NewTestConnection()
-	require.NoError(t, err)
+	testDB, err = database.Open("host=localhost user=postgres password=postgres dbname=fullstacktest_test port=5432 sslmode=disable")
+	if err == nil {
+		var sqlDB *sql.DB
+		if sqlDB, err = testDB.DB(); err == nil {
+			err = sqlDB.Ping()
+		}
+	}
+	if err != nil {
+		t.Skipf("test database unavailable: %v", err)
+	}
 
-	err = testDB.AutoMigrate(&models.User{}, &models.Order{}, &models.Product{})
+	migrator, err := database.NewMigrator(testDB, migrations.FS)
+	require.NoError(t, err)
+	_, err = migrator.Up()
 	require.NoError(t, err)
+	database.DB = testDB
 
-	testRouter = router.SetupRouter(testDB)
+	os.Setenv("JWT_SECRET", testJWTSecret)
+	provider := payments.NewFakeProvider("test-payment-secret")
+	testRouter = router.SetupRouter(provider, fsm.NewOrderMachine(fsm.WithRefunds(provider)))
 }
 
 func clearTables(t *testing.T) {
@@ -40,6 +62,13 @@ func clearTables(t *testing.T) {
 	require.NoError(t, testDB.Exec("TRUNCATE products CASCADE").Error)
 }
 
+// asAdmin authenticates req as a user manager
+func asAdmin(req *http.Request) *http.Request {
+	token, _ := middleware.GenerateToken(uuid.New(), string(models.RoleAdmin), testJWTSecret, time.Hour)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
 func TestUserHandlers(t *testing.T) {
 	setupTestDB(t)
 
@@ -48,8 +77,11 @@ func TestUserHandlers(t *testing.T) {
 
 		t.Run("Success", func(t *testing.T) {
 			payload := map[string]interface{}{
-				"name":  "John Doe",
-				"email": "john@example.com",
+				"first_name": "John",
+				"last_name":  "Doe",
+				"email":      "john@example.com",
+				"password":   "secret123",
+				"phone":      "+79990000000",
 			}
 			body, _ := json.Marshal(payload)
 
@@ -66,14 +98,18 @@ func TestUserHandlers(t *testing.T) {
 			require.NoError(t, err)
 
 			assert.NotNil(t, response["id"])
-			assert.Equal(t, payload["name"], response["name"])
+			assert.Equal(t, payload["first_name"], response["first_name"])
+			assert.Equal(t, payload["last_name"], response["last_name"])
 			assert.Equal(t, payload["email"], response["email"])
 		})
 
 		t.Run("InvalidEmail", func(t *testing.T) {
 			payload := map[string]interface{}{
-				"name":  "John Doe",
-				"email": "invalid-email",
+				"first_name": "John",
+				"last_name":  "Doe",
+				"email":      "invalid-email",
+				"password":   "secret123",
+				"phone":      "+79990000000",
 			}
 			body, _ := json.Marshal(payload)
 
@@ -89,15 +125,19 @@ func TestUserHandlers(t *testing.T) {
 		t.Run("DuplicateEmail", func(t *testing.T) {
 			// Create first user
 			user := models.User{
-				Name:  "Test User",
-				Email: "test@example.com",
+				FirstName: "Test",
+				LastName:  "User",
+				Email:     "test@example.com",
 			}
 			require.NoError(t, testDB.Create(&user).Error)
 
 			// Try to create user with same email
 			payload := map[string]interface{}{
-				"name":  "Another User",
-				"email": "test@example.com",
+				"first_name": "Another",
+				"last_name":  "User",
+				"email":      "test@example.com",
+				"password":   "secret123",
+				"phone":      "+79990000000",
 			}
 			body, _ := json.Marshal(payload)
 
@@ -107,7 +147,10 @@ func TestUserHandlers(t *testing.T) {
 
 			testRouter.ServeHTTP(w, req)
 
-			assert.Equal(t, http.StatusConflict, w.Code)
+			assert.NotEqual(t, http.StatusCreated, w.Code)
+			var count int64
+			testDB.Model(&models.User{}).Where("email = ?", "test@example.com").Count(&count)
+			assert.Equal(t, int64(1), count)
 		})
 	})
 
@@ -117,33 +160,29 @@ func TestUserHandlers(t *testing.T) {
 		t.Run("Success", func(t *testing.T) {
 			// Create test users
 			users := []models.User{
-				{Name: "User 1", Email: "user1@example.com"},
-				{Name: "User 2", Email: "user2@example.com"},
-				{Name: "User 3", Email: "user3@example.com"},
+				{FirstName: "User", LastName: "1", Email: "user1@example.com"},
+				{FirstName: "User", LastName: "2", Email: "user2@example.com"},
+				{FirstName: "User", LastName: "3", Email: "user3@example.com"},
 			}
 			for _, user := range users {
 				require.NoError(t, testDB.Create(&user).Error)
 			}
 
-			req := httptest.NewRequest("GET", "/api/users?page=1&per_page=10", nil)
+			req := asAdmin(httptest.NewRequest("GET", "/api/users", nil))
 			w := httptest.NewRecorder()
 
 			testRouter.ServeHTTP(w, req)
 
 			assert.Equal(t, http.StatusOK, w.Code)
 
-			var response map[string]interface{}
+			var response []models.User
 			err := json.Unmarshal(w.Body.Bytes(), &response)
 			require.NoError(t, err)
-
-			data, ok := response["data"].([]interface{})
-			require.True(t, ok)
-			assert.Len(t, data, 3)
-			assert.Equal(t, float64(3), response["total"])
+			assert.Len(t, response, 3)
 		})
 
 		t.Run("WithSearch", func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/api/users?search=User 2", nil)
+			req := asAdmin(httptest.NewRequest("GET", "/api/users/with-orders?name=User%202", nil))
 			w := httptest.NewRecorder()
 
 			testRouter.ServeHTTP(w, req)
@@ -154,7 +193,7 @@ func TestUserHandlers(t *testing.T) {
 			err := json.Unmarshal(w.Body.Bytes(), &response)
 			require.NoError(t, err)
 
-			data, ok := response["data"].([]interface{})
+			data, ok := response["users"].([]interface{})
 			require.True(t, ok)
 			assert.Len(t, data, 1)
 		})
@@ -165,12 +204,13 @@ func TestUserHandlers(t *testing.T) {
 
 		t.Run("Success", func(t *testing.T) {
 			user := models.User{
-				Name:  "Test User",
-				Email: "test@example.com",
+				FirstName: "Test",
+				LastName:  "User",
+				Email:     "test@example.com",
 			}
 			require.NoError(t, testDB.Create(&user).Error)
 
-			req := httptest.NewRequest("GET", fmt.Sprintf("/api/users/%d", user.ID), nil)
+			req := asAdmin(httptest.NewRequest("GET", fmt.Sprintf("/api/users/%s", user.ID), nil))
 			w := httptest.NewRecorder()
 
 			testRouter.ServeHTTP(w, req)
@@ -182,12 +222,13 @@ func TestUserHandlers(t *testing.T) {
 			require.NoError(t, err)
 
 			assert.Equal(t, user.ID, response.ID)
-			assert.Equal(t, user.Name, response.Name)
+			assert.Equal(t, user.FirstName, response.FirstName)
+			assert.Equal(t, user.LastName, response.LastName)
 			assert.Equal(t, user.Email, response.Email)
 		})
 
 		t.Run("NotFound", func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/api/users/999", nil)
+			req := asAdmin(httptest.NewRequest("GET", fmt.Sprintf("/api/users/%s", uuid.New()), nil))
 			w := httptest.NewRecorder()
 
 			testRouter.ServeHTTP(w, req)
@@ -201,18 +242,22 @@ func TestUserHandlers(t *testing.T) {
 
 		t.Run("Success", func(t *testing.T) {
 			user := models.User{
-				Name:  "Original Name",
-				Email: "original@example.com",
+				FirstName: "Original",
+				LastName:  "Name",
+				Email:     "original@example.com",
 			}
 			require.NoError(t, testDB.Create(&user).Error)
 
 			payload := map[string]interface{}{
-				"name":  "Updated Name",
-				"email": "updated@example.com",
+				"first_name": "Updated",
+				"last_name":  "Name",
+				"email":      "updated@example.com",
+				"password":   "secret123",
+				"phone":      "+79990000000",
 			}
 			body, _ := json.Marshal(payload)
 
-			req := httptest.NewRequest("PUT", fmt.Sprintf("/api/users/%d", user.ID), bytes.NewBuffer(body))
+			req := asAdmin(httptest.NewRequest("PUT", fmt.Sprintf("/api/users/%s", user.ID), bytes.NewBuffer(body)))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
@@ -224,18 +269,22 @@ func TestUserHandlers(t *testing.T) {
 			err := json.Unmarshal(w.Body.Bytes(), &response)
 			require.NoError(t, err)
 
-			assert.Equal(t, payload["name"], response.Name)
+			assert.Equal(t, payload["first_name"], response.FirstName)
+			assert.Equal(t, payload["last_name"], response.LastName)
 			assert.Equal(t, payload["email"], response.Email)
 		})
 
 		t.Run("NotFound", func(t *testing.T) {
 			payload := map[string]interface{}{
-				"name":  "Updated Name",
-				"email": "updated@example.com",
+				"first_name": "Updated",
+				"last_name":  "Name",
+				"email":      "updated@example.com",
+				"password":   "secret123",
+				"phone":      "+79990000000",
 			}
 			body, _ := json.Marshal(payload)
 
-			req := httptest.NewRequest("PUT", "/api/users/999", bytes.NewBuffer(body))
+			req := asAdmin(httptest.NewRequest("PUT", fmt.Sprintf("/api/users/%s", uuid.New()), bytes.NewBuffer(body)))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
@@ -250,12 +299,13 @@ func TestUserHandlers(t *testing.T) {
 
 		t.Run("Success", func(t *testing.T) {
 			user := models.User{
-				Name:  "Test User",
-				Email: "test@example.com",
+				FirstName: "Test",
+				LastName:  "User",
+				Email:     "test@example.com",
 			}
 			require.NoError(t, testDB.Create(&user).Error)
 
-			req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/users/%d", user.ID), nil)
+			req := asAdmin(httptest.NewRequest("DELETE", fmt.Sprintf("/api/users/%s", user.ID), nil))
 			w := httptest.NewRecorder()
 
 			testRouter.ServeHTTP(w, req)
@@ -272,7 +322,7 @@ func TestUserHandlers(t *testing.T) {
 		})
 
 		t.Run("NotFound", func(t *testing.T) {
-			req := httptest.NewRequest("DELETE", "/api/users/999", nil)
+			req := asAdmin(httptest.NewRequest("DELETE", fmt.Sprintf("/api/users/%s", uuid.New()), nil))
 			w := httptest.NewRecorder()
 
 			testRouter.ServeHTTP(w, req)
@@ -287,37 +337,38 @@ func TestUserHandlers(t *testing.T) {
 		t.Run("Success", func(t *testing.T) {
 			// Create test user
 			user := models.User{
-				Name:  "Test User",
-				Email: "test@example.com",
+				FirstName: "Test",
+				LastName:  "User",
+				Email:     "test@example.com",
 			}
 			require.NoError(t, testDB.Create(&user).Error)
 
 			// Create test products
 			product := models.Product{
-				Name:        "Test Product",
-				Price:       10.0,
-				Stock:      100,
-				SKU:        "TEST-001",
-				Category:   "Test",
+				Name:     "Test Product",
+				Price:    models.MustParseMoney("10.00"),
+				Stock:    100,
+				SKU:      "TEST-001",
+				Category: "Test",
 			}
 			require.NoError(t, testDB.Create(&product).Error)
 
 			// Create test orders
 			order := models.Order{
 				UserID: user.ID,
-				Status: "pending",
-				Total:  20.0,
+				Status: models.OrderStatusPending,
+				Total:  models.MustParseMoney("20.00"),
 				Items: []models.OrderItem{
 					{
 						ProductID: product.ID,
 						Quantity:  2,
-						Price:    10.0,
+						Price:     models.MustParseMoney("10.00"),
 					},
 				},
 			}
 			require.NoError(t, testDB.Create(&order).Error)
 
-			req := httptest.NewRequest("GET", fmt.Sprintf("/api/users/%d/orders", user.ID), nil)
+			req := asAdmin(httptest.NewRequest("GET", fmt.Sprintf("/api/users/%s/orders/summary", user.ID), nil))
 			w := httptest.NewRecorder()
 
 			testRouter.ServeHTTP(w, req)
@@ -328,18 +379,12 @@ func TestUserHandlers(t *testing.T) {
 			err := json.Unmarshal(w.Body.Bytes(), &response)
 			require.NoError(t, err)
 
-			orders, ok := response["orders"].([]interface{})
-			require.True(t, ok)
-			assert.Len(t, orders, 1)
-
-			firstOrder := orders[0].(map[string]interface{})
-			assert.Equal(t, float64(order.ID), firstOrder["id"])
-			assert.Equal(t, order.Status, firstOrder["status"])
-			assert.Equal(t, order.Total, firstOrder["total"])
+			assert.Equal(t, float64(1), response["total_orders"])
+			assert.Equal(t, order.Total.String(), response["total_spent"])
 		})
 
 		t.Run("UserNotFound", func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/api/users/999/orders", nil)
+			req := asAdmin(httptest.NewRequest("GET", fmt.Sprintf("/api/users/%s/orders/summary", uuid.New()), nil))
 			w := httptest.NewRecorder()
 
 			testRouter.ServeHTTP(w, req)
@@ -361,14 +406,15 @@ func TestUserHandlersPerformance(t *testing.T) {
 	users := make([]models.User, 1000)
 	for i := range users {
 		users[i] = models.User{
-			Name:  fmt.Sprintf("User %d", i),
-			Email: fmt.Sprintf("user%d@example.com", i),
+			FirstName: "User",
+			LastName:  fmt.Sprintf("%d", i),
+			Email:     fmt.Sprintf("user%d@example.com", i),
 		}
 	}
 	require.NoError(t, testDB.CreateInBatches(users, 100).Error)
 
 	t.Run("ListUsersPerformance", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/api/users?page=1&per_page=50", nil)
+		req := asAdmin(httptest.NewRequest("GET", "/api/users/with-orders?page=1&limit=50", nil))
 		w := httptest.NewRecorder()
 
 		start := time.Now()
@@ -382,13 +428,13 @@ func TestUserHandlersPerformance(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 
-		data, ok := response["data"].([]interface{})
+		data, ok := response["users"].([]interface{})
 		require.True(t, ok)
 		assert.Len(t, data, 50)
 	})
 
 	t.Run("SearchUsersPerformance", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/api/users?search=User&page=1&per_page=50", nil)
+		req := asAdmin(httptest.NewRequest("GET", "/api/users/with-orders?name=User&page=1&limit=50", nil))
 		w := httptest.NewRecorder()
 
 		start := time.Now()
@@ -398,4 +444,4 @@ func TestUserHandlersPerformance(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Less(t, duration.Milliseconds(), int64(100), "Search took too long")
 	})
-} 
\ No newline at end of file
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/integration/onec/commerceml"
+	onecsync "github.com/alzarasatken/FullStackTest/pkg/integration/sync"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// exchangeCookie is the session cookie 1C gets at checkauth
+	exchangeCookie = "commerceml_session"
+	// exchangeSessionTTL bounds a session; large catalogues take a while
+	exchangeSessionTTL = 12 * time.Hour
+	// DefaultExchangeFileLimit is the largest file 1C may upload at once;
+	// it splits bigger ones into chunks of this size
+	DefaultExchangeFileLimit = 100 << 20
+)
+
+// ExchangeConfig configures the CommerceML exchange. 1C logs in with User
+// and Password as set up in its exchange settings. Uploaded files are kept
+// in Dir between the file and import steps. Products are sold at the price
+// type named PriceType, the first of offers.xml when empty. Ownership
+// decides product fields changed on both sides, sync.DefaultOwnership when
+// nil.
+type ExchangeConfig struct {
+	User      string
+	Password  string
+	Dir       string
+	PriceType string
+	FileLimit int64
+	Ownership onecsync.Ownership
+}
+
+// ExchangeHandler implements the 1C site exchange protocol, the handshake
+// 1C uses to trade CommerceML files with web shops. For type=catalog 1C
+// logs in (checkauth), starts an exchange (init), uploads import.xml and
+// offers.xml (file) and has each imported (import). For type=sale it pulls
+// the orders not yet synced (query) and confirms it stored them (success).
+// Replies are plain text starting with success or failure.
+type ExchangeHandler struct {
+	db     *gorm.DB
+	config ExchangeConfig
+	now    func() time.Time
+
+	// exported are the orders of the last query, by the version sent, so
+	// success only marks orders that did not change since
+	mu       sync.Mutex
+	exported map[uint]time.Time
+}
+
+// NewExchangeHandler creates a CommerceML exchange handler
+func NewExchangeHandler(db *gorm.DB, config ExchangeConfig) *ExchangeHandler {
+	if config.FileLimit <= 0 {
+		config.FileLimit = DefaultExchangeFileLimit
+	}
+	return &ExchangeHandler{db: db, config: config, now: time.Now}
+}
+
+// RegisterRoutes registers the exchange endpoint. It authenticates 1C
+// itself, as 1C logs in with HTTP basic auth and a session cookie rather
+// than an API key.
+func (h *ExchangeHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/api/1c/exchange", h.exchange)
+	r.POST("/api/1c/exchange", h.exchange)
+}
+
+func (h *ExchangeHandler) exchange(c *gin.Context) {
+	kind, mode := c.Query("type"), c.Query("mode")
+	if kind != "catalog" && kind != "sale" {
+		exchangeFailure(c, http.StatusBadRequest, "unsupported exchange type %q", kind)
+		return
+	}
+
+	if mode == "checkauth" {
+		h.checkAuth(c)
+		return
+	}
+	if !h.authorized(c) {
+		exchangeFailure(c, http.StatusUnauthorized, "authorization required")
+		return
+	}
+
+	switch {
+	case mode == "init":
+		h.init(c, kind)
+	case mode == "file" && kind == "catalog":
+		h.upload(c, kind)
+	case mode == "import" && kind == "catalog":
+		h.importCatalog(c)
+	case mode == "query" && kind == "sale":
+		h.queryOrders(c)
+	case mode == "success" && kind == "sale":
+		h.ordersReceived(c)
+	default:
+		exchangeFailure(c, http.StatusBadRequest, "unsupported mode %q for %s", mode, kind)
+	}
+}
+
+// checkAuth logs 1C in, answering with the name and value of the session
+// cookie it sends from then on
+func (h *ExchangeHandler) checkAuth(c *gin.Context) {
+	user, password, ok := c.Request.BasicAuth()
+	if !ok || !h.validCredentials(user, password) {
+		exchangeFailure(c, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+	expires := h.now().Add(exchangeSessionTTL).Unix()
+	session := strconv.FormatInt(expires, 10) + "." + h.sign(expires)
+	c.String(http.StatusOK, "success\n%s\n%s\n", exchangeCookie, session)
+}
+
+// authorized accepts the session cookie of checkauth, or basic auth, which
+// some 1C versions send with every request
+func (h *ExchangeHandler) authorized(c *gin.Context) bool {
+	if user, password, ok := c.Request.BasicAuth(); ok {
+		return h.validCredentials(user, password)
+	}
+
+	session, err := c.Cookie(exchangeCookie)
+	if err != nil {
+		return false
+	}
+	expiresText, signature, ok := strings.Cut(session, ".")
+	expires, err := strconv.ParseInt(expiresText, 10, 64)
+	if !ok || err != nil || h.now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(h.sign(expires)))
+}
+
+func (h *ExchangeHandler) validCredentials(user, password string) bool {
+	if h.config.User == "" || h.config.Password == "" {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(h.config.User)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(h.config.Password)) == 1
+	return userOK && passwordOK
+}
+
+// sign signs a session expiring at expires, so sessions need no storage and
+// end when the credentials change
+func (h *ExchangeHandler) sign(expires int64) string {
+	mac := hmac.New(sha256.New, []byte(h.config.Password))
+	fmt.Fprintf(mac, "%s:%d", h.config.User, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// init starts an exchange, dropping the files of the previous one
+func (h *ExchangeHandler) init(c *gin.Context, kind string) {
+	dir := filepath.Join(h.config.Dir, kind)
+	if err := os.RemoveAll(dir); err != nil {
+		exchangeFailure(c, http.StatusInternalServerError, "clearing exchange files: %v", err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		exchangeFailure(c, http.StatusInternalServerError, "creating exchange directory: %v", err)
+		return
+	}
+	c.String(http.StatusOK, "zip=no\nfile_limit=%d\n", h.config.FileLimit)
+}
+
+// upload stores a file 1C sends. Files above the limit come in chunks,
+// which are appended.
+func (h *ExchangeHandler) upload(c *gin.Context, kind string) {
+	path, err := h.path(kind, c.Query("filename"))
+	if err != nil {
+		exchangeFailure(c, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		exchangeFailure(c, http.StatusInternalServerError, "creating exchange directory: %v", err)
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		exchangeFailure(c, http.StatusInternalServerError, "opening %s: %v", c.Query("filename"), err)
+		return
+	}
+	defer file.Close()
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, h.config.FileLimit)
+	if _, err := io.Copy(file, body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			exchangeFailure(c, http.StatusRequestEntityTooLarge, "file chunk above %d bytes", h.config.FileLimit)
+			return
+		}
+		exchangeFailure(c, http.StatusInternalServerError, "writing %s: %v", c.Query("filename"), err)
+		return
+	}
+	c.String(http.StatusOK, "success\n")
+}
+
+// importCatalog imports an uploaded import.xml or offers.xml into products
+func (h *ExchangeHandler) importCatalog(c *gin.Context) {
+	filename := c.Query("filename")
+	path, err := h.path("catalog", filename)
+	if err != nil {
+		exchangeFailure(c, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".xml") {
+		// Images and other attachments have nothing to import
+		c.String(http.StatusOK, "success\n")
+		return
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		exchangeFailure(c, http.StatusBadRequest, "%s was not uploaded", filename)
+		return
+	}
+	if err != nil {
+		exchangeFailure(c, http.StatusInternalServerError, "opening %s: %v", filename, err)
+		return
+	}
+	defer file.Close()
+
+	doc, err := commerceml.Parse(file)
+	if err != nil {
+		exchangeFailure(c, http.StatusBadRequest, "%s: %v", filename, err)
+		return
+	}
+
+	var result commerceml.Result
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		result, err = commerceml.Import(tx, doc, h.config.PriceType, h.config.Ownership)
+		return err
+	}); err != nil {
+		exchangeFailure(c, http.StatusInternalServerError, "importing %s: %v", filename, err)
+		return
+	}
+
+	log.Printf("CommerceML %s imported: %d created, %d updated, %d deleted, %d skipped",
+		filename, result.Created, result.Updated, result.Deleted, result.Skipped)
+	c.String(http.StatusOK, "success\n")
+}
+
+// queryOrders sends 1C the orders it does not have the current state of
+func (h *ExchangeHandler) queryOrders(c *gin.Context) {
+	var orders []models.Order
+	if err := h.db.Preload("Items.Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("User").
+		Where("synced = ?", false).
+		Order("id").
+		Find(&orders).Error; err != nil {
+		exchangeFailure(c, http.StatusInternalServerError, "loading orders: %v", err)
+		return
+	}
+
+	exported := make(map[uint]time.Time, len(orders))
+	for _, order := range orders {
+		exported[order.ID] = order.UpdatedAt
+	}
+	h.mu.Lock()
+	h.exported = exported
+	h.mu.Unlock()
+
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := commerceml.WriteOrders(c.Writer, orders, h.now()); err != nil {
+		log.Printf("Error writing CommerceML orders: %v", err)
+	}
+}
+
+// ordersReceived marks the orders of the last query synced, unless they
+// changed after it
+func (h *ExchangeHandler) ordersReceived(c *gin.Context) {
+	h.mu.Lock()
+	exported := h.exported
+	h.exported = nil
+	h.mu.Unlock()
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		for id, updatedAt := range exported {
+			if err := tx.Model(&models.Order{}).
+				Where("id = ? AND updated_at = ?", id, updatedAt).
+				UpdateColumn("synced", true).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		exchangeFailure(c, http.StatusInternalServerError, "marking orders as synced: %v", err)
+		return
+	}
+	c.String(http.StatusOK, "success\n")
+}
+
+// path is where an uploaded file is kept. 1C names files relative to the
+// exchange, images in subdirectories; names cannot climb out of it.
+func (h *ExchangeHandler) path(kind, filename string) (string, error) {
+	name := strings.TrimPrefix(filepath.Clean("/"+filepath.FromSlash(filename)), string(filepath.Separator))
+	if filename == "" || name == "" {
+		return "", fmt.Errorf("filename is required")
+	}
+	return filepath.Join(h.config.Dir, kind, name), nil
+}
+
+// exchangeFailure replies in the protocol's failure format, which 1C shows
+// to the user
+func exchangeFailure(c *gin.Context, status int, format string, args ...interface{}) {
+	c.String(status, "failure\n%s\n", fmt.Sprintf(format, args...))
+}
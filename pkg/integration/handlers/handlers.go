@@ -1,11 +1,14 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
-	"fullstacktest/pkg/integration/sync"
+	"github.com/alzarasatken/FullStackTest/pkg/integration/sync"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -22,57 +25,120 @@ func NewHandler(syncService *sync.Service) *Handler {
 	}
 }
 
-// RegisterRoutes registers the integration routes
-func (h *Handler) RegisterRoutes(r *gin.Engine) {
-	group := r.Group("/api/sync")
+// RegisterRoutes registers the integration routes behind the given
+// authentication middleware
+func (h *Handler) RegisterRoutes(r *gin.Engine, auth ...gin.HandlerFunc) {
+	group := r.Group("/api/sync", auth...)
 	{
 		group.POST("/products", h.syncProducts)
 		group.POST("/orders", h.syncOrders)
+		group.POST("/stock", h.syncStock)
 		group.POST("/orders/:id/status", h.updateOrderStatus)
 		group.GET("/status", h.getSyncStatus)
+		group.GET("/runs", h.getSyncRuns)
+		group.GET("/conflicts", h.getSyncConflicts)
 	}
 }
 
-// SyncRequest represents a sync request
+// SyncRequest represents a sync request. Force resyncs everything instead
+// of only what changed since the last run; DryRun reports what a product or
+// order sync would change instead of syncing.
 type SyncRequest struct {
-	Force bool `json:"force"`
+	Force  bool `json:"force"`
+	DryRun bool `json:"dry_run"`
 }
 
-// StatusResponse represents a sync status response
+// bindSyncRequest reads the request body, which may be left out
+func bindSyncRequest(c *gin.Context) (SyncRequest, bool) {
+	var req SyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return req, false
+	}
+	return req, true
+}
+
+// trigger is what the runs the request asks for are recorded as
+func (r SyncRequest) trigger() models.SyncTrigger {
+	if r.Force {
+		return models.SyncTriggerForced
+	}
+	return models.SyncTriggerManual
+}
+
+// StatusResponse represents a sync status response. Status is healthy when
+// the last run of every job succeeded, failing when one failed and unknown
+// before the first run.
 type StatusResponse struct {
-	LastProductSync time.Time `json:"lastProductSync"`
-	LastOrderSync   time.Time `json:"lastOrderSync"`
-	Status         string    `json:"status"`
+	LastProductSync *time.Time                         `json:"lastProductSync"`
+	LastOrderSync   *time.Time                         `json:"lastOrderSync"`
+	Status          string                             `json:"status"`
+	Jobs            map[models.SyncType]sync.JobStatus `json:"jobs"`
 }
 
 func (h *Handler) syncProducts(c *gin.Context) {
-	var req SyncRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	req, ok := bindSyncRequest(c)
+	if !ok {
 		return
 	}
 
-	if err := h.syncService.SyncProducts(c.Request.Context()); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if req.DryRun {
+		diff, err := h.syncService.PreviewProducts(c.Request.Context(), req.Force)
+		respondDiff(c, diff, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	run, err := h.syncService.SyncProducts(c.Request.Context(), req.trigger())
+	respondRun(c, run, err)
 }
 
 func (h *Handler) syncOrders(c *gin.Context) {
-	var req SyncRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	req, ok := bindSyncRequest(c)
+	if !ok {
 		return
 	}
 
-	if err := h.syncService.SyncOrders(c.Request.Context()); err != nil {
+	if req.DryRun {
+		diff, err := h.syncService.PreviewOrders(req.Force)
+		respondDiff(c, diff, err)
+		return
+	}
+
+	run, err := h.syncService.SyncOrders(c.Request.Context(), req.trigger())
+	respondRun(c, run, err)
+}
+
+func (h *Handler) syncStock(c *gin.Context) {
+	req, ok := bindSyncRequest(c)
+	if !ok {
+		return
+	}
+	if req.DryRun {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stock sync has no dry run"})
+		return
+	}
+
+	run, err := h.syncService.SyncStock(c.Request.Context(), req.trigger())
+	respondRun(c, run, err)
+}
+
+// respondDiff reports what a dry run found; nothing is recorded
+func respondDiff(c *gin.Context, diff interface{}, err error) {
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"status": "dry_run", "diff": diff})
+}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+// respondRun reports a sync run asked for through the API; failed runs are
+// recorded too and come with the error
+func respondRun(c *gin.Context, run *models.SyncRun, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "run": run})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "run": run})
 }
 
 // OrderStatusUpdate represents an order status update
@@ -102,12 +168,83 @@ func (h *Handler) updateOrderStatus(c *gin.Context) {
 }
 
 func (h *Handler) getSyncStatus(c *gin.Context) {
-	// In a real implementation, you would get these values from Redis or database
+	jobs, err := h.syncService.Status(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	status := StatusResponse{
-		LastProductSync: time.Now().Add(-5 * time.Minute),
-		LastOrderSync:   time.Now().Add(-3 * time.Minute),
-		Status:         "healthy",
+		LastProductSync: jobs[models.SyncTypeProducts].LastSuccess,
+		LastOrderSync:   jobs[models.SyncTypeOrders].LastSuccess,
+		Status:          "unknown",
+		Jobs:            jobs,
+	}
+	for _, job := range jobs {
+		if job.LastRun == nil {
+			continue
+		}
+		if !job.Healthy() {
+			status.Status = "failing"
+			break
+		}
+		status.Status = "healthy"
 	}
 
 	c.JSON(http.StatusOK, status)
-} 
\ No newline at end of file
+}
+
+func (h *Handler) getSyncRuns(c *gin.Context) {
+	page, limit := pageParams(c)
+	kind := models.SyncType(c.Query("type"))
+
+	runs, total, err := h.syncService.Runs(kind, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":       runs,
+		"pagination": pagination(page, limit, total),
+	})
+}
+
+func (h *Handler) getSyncConflicts(c *gin.Context) {
+	page, limit := pageParams(c)
+	productID, _ := strconv.ParseUint(c.Query("product_id"), 10, 64)
+
+	conflicts, total, err := h.syncService.Conflicts(uint(productID), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conflicts":  conflicts,
+		"pagination": pagination(page, limit, total),
+	})
+}
+
+// pageParams reads the page and page size of a history listing
+func pageParams(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
+
+func pagination(page, limit int, total int64) gin.H {
+	return gin.H{
+		"current_page":   page,
+		"total_items":    total,
+		"items_per_page": limit,
+		"total_pages":    (total + int64(limit) - 1) / int64(limit),
+	}
+}
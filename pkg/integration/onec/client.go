@@ -4,178 +4,320 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 )
 
+// Config tunes how the client copes with failures. Every attempt times out
+// after Timeout. Failed calls are retried up to MaxRetries times, waiting a
+// jittered BaseDelay doubled on each retry and capped at MaxDelay, or longer
+// when 1C asks to with Retry-After. After BreakerThreshold transient
+// failures in a row, calls fail with ErrCircuitOpen without reaching 1C for
+// BreakerCooldown; a threshold of 0 disables the breaker.
+type Config struct {
+	Timeout          time.Duration
+	MaxRetries       int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultConfig retries three times within a few seconds and stops calling
+// 1C for a minute after five failures in a row
+var DefaultConfig = Config{
+	Timeout:          30 * time.Second,
+	MaxRetries:       3,
+	BaseDelay:        500 * time.Millisecond,
+	MaxDelay:         30 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  time.Minute,
+}
+
 // Client represents a 1C API client
 type Client struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
+	config     Config
+	breaker    *breaker
+	sleep      func(ctx context.Context, d time.Duration) error
 }
 
 // NewClient creates a new 1C API client
-func NewClient(baseURL, apiKey string) *Client {
+func NewClient(baseURL, apiKey string, config Config) *Client {
 	return &Client{
 		baseURL: baseURL,
 		apiKey:  apiKey,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: config.Timeout,
 		},
+		config:  config,
+		breaker: newBreaker(config.BreakerThreshold, config.BreakerCooldown),
+		sleep:   sleepContext,
 	}
 }
 
-// Product represents a product in 1C
+// Amount is a money value on the 1C wire. 1C exchanges amounts as JSON
+// numbers, so unlike models.Money it is encoded without quotes; the number is
+// written and read from its decimal text, never through float64.
+type Amount struct {
+	models.Money
+}
+
+// MarshalJSON encodes the amount as a JSON number
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// Product represents a product in 1C. Deleted is set on products marked
+// for deletion there. Price is in Currency, or the base currency when it is
+// empty, as it always is over the REST API; only CommerceML offers name
+// others.
 type Product struct {
-	ID          string  `json:"id"`
-	Code        string  `json:"code"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
-	Category    string  `json:"category"`
+	ID          string          `json:"id"`
+	Code        string          `json:"code"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Price       Amount          `json:"price"`
+	Currency    models.Currency `json:"-"`
+	Stock       int             `json:"stock"`
+	Category    string          `json:"category"`
+	Deleted     bool            `json:"deleted"`
 }
 
-// Order represents an order in 1C
+// Order represents an order in 1C. Total includes VAT, which is broken down
+// into NetTotal and VATTotal.
 type Order struct {
 	ID         string    `json:"id"`
 	Number     string    `json:"number"`
 	Date       time.Time `json:"date"`
 	CustomerID string    `json:"customerId"`
 	Status     string    `json:"status"`
+	Currency   string    `json:"currency"`
 	Items      []Item    `json:"items"`
-	Total      float64   `json:"total"`
+	Total      Amount    `json:"total"`
+	NetTotal   Amount    `json:"netTotal"`
+	VATTotal   Amount    `json:"vatTotal"`
 }
 
-// Item represents an order item in 1C
+// Item represents an order item in 1C. Amount is the line total after
+// discounts, VAT included, and VAT the part of it charged at VATRate per cent.
 type Item struct {
-	ProductID string  `json:"productId"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+	Price     Amount `json:"price"`
+	VATRate   int    `json:"vatRate"`
+	Net       Amount `json:"net"`
+	VAT       Amount `json:"vat"`
+	Amount    Amount `json:"amount"`
+}
+
+// Return represents a completed return of order items in 1C. Refund is the
+// amount paid back, VAT included.
+type Return struct {
+	ID       string       `json:"id"`
+	OrderID  string       `json:"orderId"`
+	Date     time.Time    `json:"date"`
+	Reason   string       `json:"reason"`
+	Restock  bool         `json:"restock"`
+	Items    []ReturnItem `json:"items"`
+	Refund   Amount       `json:"refund"`
+	Currency string       `json:"currency"`
+}
+
+// ReturnItem represents a returned order item in 1C
+type ReturnItem struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+	Amount    Amount `json:"amount"`
 }
 
 // GetProducts fetches products from 1C
 func (c *Client) GetProducts(ctx context.Context, modifiedSince *time.Time) ([]Product, error) {
-	url := fmt.Sprintf("%s/products", c.baseURL)
+	path := "/products"
 	if modifiedSince != nil {
-		url = fmt.Sprintf("%s?modifiedSince=%s", url, modifiedSince.Format(time.RFC3339))
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		path += "?" + url.Values{"modifiedSince": {modifiedSince.Format(time.RFC3339)}}.Encode()
 	}
 
 	var products []Product
-	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	if err := c.do(ctx, "GET", path, nil, &products, true); err != nil {
+		return nil, err
 	}
-
 	return products, nil
 }
 
 // UpdateOrderStatus updates order status in 1C
 func (c *Client) UpdateOrderStatus(ctx context.Context, orderID, status string) error {
-	url := fmt.Sprintf("%s/orders/%s/status", c.baseURL, orderID)
 	payload := map[string]string{"status": status}
+	return c.do(ctx, "PUT", "/orders/"+url.PathEscape(orderID)+"/status", payload, nil, true)
+}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("marshaling payload: %w", err)
-	}
+// SyncOrders sends orders to 1C
+func (c *Client) SyncOrders(ctx context.Context, orders []Order) error {
+	return c.do(ctx, "POST", "/orders/batch", orders, nil, false)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+// GetStockUpdates fetches the stock changes made in 1C since the given time
+// as quantity deltas, keyed by product ID or SKU
+func (c *Client) GetStockUpdates(ctx context.Context, since time.Time) (map[string]int, error) {
+	path := "/products/stock?" + url.Values{"since": {since.Format(time.RFC3339)}}.Encode()
+
+	var updates map[string]int
+	if err := c.do(ctx, "GET", path, nil, &updates, true); err != nil {
+		return nil, err
 	}
+	return updates, nil
+}
 
-	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+// SyncReturns sends completed returns to 1C
+func (c *Client) SyncReturns(ctx context.Context, returns []Return) error {
+	return c.do(ctx, "POST", "/returns/batch", returns, nil, false)
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("executing request: %w", err)
+// do sends a request to 1C, encoding payload as its body when given, and
+// decodes the response into out when given. Idempotent requests are retried
+// after any transient failure; others only when 1C answers 429 or 503,
+// which say it did not process them.
+func (c *Client) do(ctx context.Context, method, path string, payload, out interface{}, idempotent bool) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("marshaling payload: %w", err)
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, method, path, body, out)
+		if err == nil || attempt >= c.config.MaxRetries || ctx.Err() != nil || !retryable(err, idempotent) {
+			return err
+		}
+
+		// A Retry-After beyond MaxDelay is not worth waiting for
+		if retryAfter > c.config.MaxDelay {
+			return err
+		}
+		delay := c.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if err := c.sleep(ctx, delay); err != nil {
+			return err
+		}
 	}
-
-	return nil
 }
 
-// SyncOrders sends orders to 1C
-func (c *Client) SyncOrders(ctx context.Context, orders []Order) error {
-	url := fmt.Sprintf("%s/orders/batch", c.baseURL)
-
-	body, err := json.Marshal(orders)
-	if err != nil {
-		return fmt.Errorf("marshaling orders: %w", err)
+// attempt makes a single request, returning the wait 1C asked for in
+// Retry-After along with a failure
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte, out interface{}) (time.Duration, error) {
+	if !c.breaker.allow() {
+		return 0, ErrCircuitOpen
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		c.breaker.cancel()
+		return 0, fmt.Errorf("creating request: %w", err)
 	}
-
 	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("executing request: %w", err)
+		// Giving up on the call says nothing about 1C
+		if ctx.Err() != nil {
+			c.breaker.cancel()
+		} else {
+			c.breaker.record(true)
+		}
+		return 0, &transportError{err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(text))}
+		c.breaker.record(errors.Is(statusErr, ErrTransient))
+		return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), statusErr
 	}
+	c.breaker.record(false)
 
-	return nil
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return 0, fmt.Errorf("decoding response: %w", err)
+		}
+	}
+	return 0, nil
 }
 
-// GetStockUpdates fetches stock updates from 1C
-func (c *Client) GetStockUpdates(ctx context.Context, since time.Time) (map[string]int, error) {
-	url := fmt.Sprintf("%s/products/stock?since=%s", c.baseURL, since.Format(time.RFC3339))
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+// backoff is the wait before retry attempt+1: BaseDelay doubled attempt
+// times and capped at MaxDelay, of which a random half is taken off so
+// clients failing together do not retry together
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.config.BaseDelay
+	for i := 0; i < attempt && delay < c.config.MaxDelay; i++ {
+		delay *= 2
 	}
-
-	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
+	if delay > c.config.MaxDelay {
+		delay = c.config.MaxDelay
 	}
-	defer resp.Body.Close()
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// retryable reports whether a failed request may be sent again
+func retryable(err error, idempotent bool) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
 	}
+	if idempotent {
+		return errors.Is(err, ErrTransient)
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode == http.StatusServiceUnavailable)
+}
 
-	var updates map[string]int
-	if err := json.NewDecoder(resp.Body).Decode(&updates); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date; it is zero when absent or malformed
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
 	}
+	return 0
+}
 
-	return updates, nil
-} 
\ No newline at end of file
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
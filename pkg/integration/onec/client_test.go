@@ -0,0 +1,206 @@
+package onec
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fake1C answers the requests it receives with the given status codes in
+// turn, then with 200 and body
+func fake1C(t *testing.T, body string, statuses ...int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if r.Header.Get("X-API-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if n <= len(statuses) {
+			if statuses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "2")
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// testClient returns a client of server that records its waits instead of
+// sleeping
+func testClient(server *httptest.Server, config Config) (*Client, *[]time.Duration) {
+	client := NewClient(server.URL, "key", config)
+	var waits []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return client, &waits
+}
+
+var testConfig = Config{
+	Timeout:          time.Second,
+	MaxRetries:       3,
+	BaseDelay:        100 * time.Millisecond,
+	MaxDelay:         5 * time.Second,
+	BreakerThreshold: 0,
+}
+
+func TestRetries(t *testing.T) {
+	t.Run("Reads are retried until 1C recovers", func(t *testing.T) {
+		server, calls := fake1C(t, `{"A1": 5}`, http.StatusBadGateway, http.StatusServiceUnavailable)
+		client, waits := testClient(server, testConfig)
+
+		updates, err := client.GetStockUpdates(context.Background(), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"A1": 5}, updates)
+		assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+
+		// Backoff doubles, with up to half taken off as jitter
+		require.Len(t, *waits, 2)
+		assert.True(t, (*waits)[0] >= 50*time.Millisecond && (*waits)[0] <= 100*time.Millisecond, (*waits)[0])
+		assert.True(t, (*waits)[1] >= 100*time.Millisecond && (*waits)[1] <= 200*time.Millisecond, (*waits)[1])
+	})
+
+	t.Run("Retries stop after MaxRetries", func(t *testing.T) {
+		server, calls := fake1C(t, `[]`, 500, 500, 500, 500, 500)
+		client, _ := testClient(server, testConfig)
+
+		_, err := client.GetProducts(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrTransient)
+		var statusErr *StatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, 500, statusErr.StatusCode)
+		assert.Equal(t, int32(4), atomic.LoadInt32(calls))
+	})
+
+	t.Run("Retry-After is honoured", func(t *testing.T) {
+		server, _ := fake1C(t, `[]`, http.StatusTooManyRequests)
+		client, waits := testClient(server, testConfig)
+
+		_, err := client.GetProducts(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{2 * time.Second}, *waits)
+	})
+
+	t.Run("Batches are only resent when 1C did not take them", func(t *testing.T) {
+		server, calls := fake1C(t, ``, http.StatusInternalServerError)
+		client, _ := testClient(server, testConfig)
+		err := client.SyncOrders(context.Background(), []Order{{ID: "ORD-000001"}})
+		assert.ErrorIs(t, err, ErrTransient)
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+		server, calls = fake1C(t, ``, http.StatusServiceUnavailable)
+		client, _ = testClient(server, testConfig)
+		err = client.SyncOrders(context.Background(), []Order{{ID: "ORD-000001"}})
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+
+	t.Run("Rejections are not retried", func(t *testing.T) {
+		server, calls := fake1C(t, ``, http.StatusUnprocessableEntity)
+		client, _ := testClient(server, testConfig)
+		err := client.UpdateOrderStatus(context.Background(), "ORD-000001", "bogus")
+		assert.ErrorIs(t, err, ErrValidation)
+		assert.False(t, errors.Is(err, ErrTransient))
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+		server, calls = fake1C(t, ``)
+		client = NewClient(server.URL, "wrong", testConfig)
+		_, err = client.GetProducts(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+
+	t.Run("Network errors are transient", func(t *testing.T) {
+		server, _ := fake1C(t, ``)
+		server.Close()
+		client, waits := testClient(server, testConfig)
+
+		_, err := client.GetProducts(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrTransient)
+		assert.Len(t, *waits, 3)
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	server, calls := fake1C(t, `[]`, 500, 500, 500)
+	config := testConfig
+	config.MaxRetries = 0
+	config.BreakerThreshold = 3
+	config.BreakerCooldown = time.Minute
+	client, _ := testClient(server, config)
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetProducts(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrTransient)
+	}
+
+	// The open breaker fails calls without reaching 1C
+	_, err := client.GetProducts(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, err, ErrTransient)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+
+	// After the cooldown a probe goes through and closes it
+	now = now.Add(time.Minute)
+	_, err = client.GetProducts(context.Background(), nil)
+	require.NoError(t, err)
+	_, err = client.GetProducts(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), atomic.LoadInt32(calls))
+}
+
+func TestQueryTimestamps(t *testing.T) {
+	// A "+" left unescaped in a query string reads back as a space, so the
+	// offset must survive the trip to 1C
+	since := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path == "/products/stock" {
+			queries = append(queries, query.Get("since"))
+			w.Write([]byte("{}"))
+			return
+		}
+		queries = append(queries, query.Get("modifiedSince"))
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(server.Close)
+	client, _ := testClient(server, testConfig)
+
+	_, err := client.GetProducts(context.Background(), &since)
+	require.NoError(t, err)
+	_, err = client.GetStockUpdates(context.Background(), since)
+	require.NoError(t, err)
+
+	require.Len(t, queries, 2)
+	for _, query := range queries {
+		parsed, err := time.Parse(time.RFC3339, query)
+		require.NoError(t, err)
+		assert.True(t, since.Equal(parsed), "got %s", query)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, 7*time.Second, parseRetryAfter("7", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter("Sun, 01 Mar 2026 12:01:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Sun, 01 Mar 2026 11:00:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
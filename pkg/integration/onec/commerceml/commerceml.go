@@ -0,0 +1,135 @@
+// Package commerceml reads and writes CommerceML 2, the XML format 1C:Trade
+// and other 1C configurations exchange catalogues and orders with web shops
+// in. 1C pushes its catalogue as import.xml (groups and products) and
+// offers.xml (prices and stock), which are imported into products, and pulls
+// the shop's orders as an orders.xml document.
+package commerceml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Deleted is the status 1C marks products removed from the catalogue with
+const Deleted = "Удален"
+
+// Document is a КоммерческаяИнформация file. An import.xml carries the
+// Classifier and Catalog, an offers.xml the Offers; some configurations put
+// all of them into one file.
+type Document struct {
+	XMLName    xml.Name      `xml:"КоммерческаяИнформация"`
+	Version    string        `xml:"ВерсияСхемы,attr"`
+	Classifier *Classifier   `xml:"Классификатор"`
+	Catalog    *Catalog      `xml:"Каталог"`
+	Offers     *OfferPackage `xml:"ПакетПредложений"`
+}
+
+// Classifier holds the product groups of a catalogue
+type Classifier struct {
+	Groups []Group `xml:"Группы>Группа"`
+}
+
+// Group is a product group; groups nest
+type Group struct {
+	ID     string  `xml:"Ид"`
+	Name   string  `xml:"Наименование"`
+	Groups []Group `xml:"Группы>Группа"`
+}
+
+// Catalog lists products
+type Catalog struct {
+	Products []Product `xml:"Товары>Товар"`
+}
+
+// Product is a catalogue item. Code is its article (Артикул) and Groups the
+// IDs of the groups it belongs to. Older schema versions give the status as
+// an attribute, newer ones as an element.
+type Product struct {
+	ID          string   `xml:"Ид"`
+	Code        string   `xml:"Артикул"`
+	Name        string   `xml:"Наименование"`
+	Description string   `xml:"Описание"`
+	Groups      []string `xml:"Группы>Ид"`
+	Status      string   `xml:"Статус"`
+	StatusAttr  string   `xml:"Статус,attr"`
+}
+
+// IsDeleted reports whether 1C removed the product from the catalogue
+func (p Product) IsDeleted() bool {
+	return p.Status == Deleted || p.StatusAttr == Deleted
+}
+
+// OfferPackage lists the prices and stock of products
+type OfferPackage struct {
+	PriceTypes []PriceType `xml:"ТипыЦен>ТипЦены"`
+	Offers     []Offer     `xml:"Предложения>Предложение"`
+}
+
+// PriceType is a kind of price such as retail or wholesale
+type PriceType struct {
+	ID       string `xml:"Ид"`
+	Name     string `xml:"Наименование"`
+	Currency string `xml:"Валюта"`
+}
+
+// Offer gives the prices and stock of a product. Offers of product variants
+// (characteristics) have IDs of the form product#variant. Stock is given in
+// total as Quantity or per warehouse.
+type Offer struct {
+	ID         string      `xml:"Ид"`
+	Name       string      `xml:"Наименование"`
+	Prices     []Price     `xml:"Цены>Цена"`
+	Quantity   string      `xml:"Количество"`
+	Warehouses []Warehouse `xml:"Склад"`
+}
+
+// Price is the price of an offer of one price type
+type Price struct {
+	PriceTypeID string `xml:"ИдТипаЦены"`
+	Amount      string `xml:"ЦенаЗаЕдиницу"`
+	Currency    string `xml:"Валюта"`
+}
+
+// Warehouse is the stock of an offer in one warehouse
+type Warehouse struct {
+	ID       string `xml:"ИдСклада,attr"`
+	Quantity string `xml:"КоличествоНаСкладе,attr"`
+}
+
+// Parse reads a CommerceML document. Only UTF-8 files are supported, which
+// is what 1C writes for CommerceML 2.
+func Parse(r io.Reader) (*Document, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if !strings.EqualFold(charset, "utf-8") {
+			return nil, fmt.Errorf("unsupported charset %s", charset)
+		}
+		return input, nil
+	}
+
+	var doc Document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing CommerceML: %w", err)
+	}
+	return &doc, nil
+}
+
+// categories maps the IDs of the classifier's groups, nested ones included,
+// to their names
+func (c *Classifier) categories() map[string]string {
+	names := map[string]string{}
+	if c == nil {
+		return names
+	}
+	var walk func(groups []Group)
+	walk = func(groups []Group) {
+		for _, g := range groups {
+			names[g.ID] = g.Name
+			walk(g.Groups)
+		}
+	}
+	walk(c.Groups)
+	return names
+}
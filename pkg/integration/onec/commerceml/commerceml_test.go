@@ -0,0 +1,208 @@
+package commerceml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const importXML = `<?xml version="1.0" encoding="UTF-8"?>
+<КоммерческаяИнформация ВерсияСхемы="2.05" ДатаФормирования="2026-03-01T10:00:00">
+  <Классификатор>
+    <Ид>cls</Ид>
+    <Группы>
+      <Группа>
+        <Ид>kitchen</Ид>
+        <Наименование>Кухня</Наименование>
+        <Группы>
+          <Группа><Ид>pans</Ид><Наименование>Сковороды</Наименование></Группа>
+        </Группы>
+      </Группа>
+    </Группы>
+  </Классификатор>
+  <Каталог СодержитТолькоИзменения="false">
+    <Ид>cat</Ид>
+    <Товары>
+      <Товар>
+        <Ид>a1</Ид>
+        <Артикул>PAN-24</Артикул>
+        <Наименование>Сковорода 24 см</Наименование>
+        <Описание>Чугун</Описание>
+        <Группы><Ид>pans</Ид></Группы>
+      </Товар>
+      <Товар Статус="Удален">
+        <Ид>a2</Ид>
+        <Наименование>Старая кастрюля</Наименование>
+      </Товар>
+      <Товар>
+        <Ид>a3</Ид>
+        <Наименование>Половник</Наименование>
+        <Статус>Удален</Статус>
+      </Товар>
+    </Товары>
+  </Каталог>
+</КоммерческаяИнформация>`
+
+const offersXML = `<?xml version="1.0" encoding="UTF-8"?>
+<КоммерческаяИнформация ВерсияСхемы="2.05">
+  <ПакетПредложений>
+    <ТипыЦен>
+      <ТипЦены><Ид>wholesale</Ид><Наименование>Оптовая</Наименование><Валюта>RUB</Валюта></ТипЦены>
+      <ТипЦены><Ид>retail</Ид><Наименование>Розничная</Наименование><Валюта>руб</Валюта></ТипЦены>
+    </ТипыЦен>
+    <Предложения>
+      <Предложение>
+        <Ид>a1</Ид>
+        <Цены>
+          <Цена><ИдТипаЦены>wholesale</ИдТипаЦены><ЦенаЗаЕдиницу>900</ЦенаЗаЕдиницу></Цена>
+          <Цена><ИдТипаЦены>retail</ИдТипаЦены><ЦенаЗаЕдиницу>1250.50</ЦенаЗаЕдиницу></Цена>
+        </Цены>
+        <Склад ИдСклада="w1" КоличествоНаСкладе="3.000"/>
+        <Склад ИдСклада="w2" КоличествоНаСкладе="4"/>
+      </Предложение>
+      <Предложение>
+        <Ид>a1#red</Ид>
+        <Количество>2</Количество>
+      </Предложение>
+    </Предложения>
+  </ПакетПредложений>
+</КоммерческаяИнформация>`
+
+func TestParseCatalog(t *testing.T) {
+	doc, err := Parse(strings.NewReader(importXML))
+	require.NoError(t, err)
+	assert.Equal(t, "2.05", doc.Version)
+	assert.Nil(t, doc.Offers)
+	require.NotNil(t, doc.Catalog)
+	require.Len(t, doc.Catalog.Products, 3)
+
+	pan := doc.Catalog.Products[0]
+	assert.Equal(t, "a1", pan.ID)
+	assert.Equal(t, "PAN-24", pan.Code)
+	assert.Equal(t, "Сковорода 24 см", pan.Name)
+	assert.Equal(t, []string{"pans"}, pan.Groups)
+	assert.False(t, pan.IsDeleted())
+	assert.True(t, doc.Catalog.Products[1].IsDeleted())
+	assert.True(t, doc.Catalog.Products[2].IsDeleted())
+
+	assert.Equal(t, map[string]string{"kitchen": "Кухня", "pans": "Сковороды"}, doc.Classifier.categories())
+}
+
+func TestParseOffers(t *testing.T) {
+	doc, err := Parse(strings.NewReader(offersXML))
+	require.NoError(t, err)
+	require.NotNil(t, doc.Offers)
+	require.Len(t, doc.Offers.Offers, 2)
+
+	id, err := doc.Offers.priceTypeID("")
+	require.NoError(t, err)
+	assert.Equal(t, "wholesale", id)
+	id, err = doc.Offers.priceTypeID("розничная")
+	require.NoError(t, err)
+	assert.Equal(t, "retail", id)
+	_, err = doc.Offers.priceTypeID("Закупочная")
+	assert.Error(t, err)
+
+	offer := doc.Offers.Offers[0]
+	price, ok := offer.price("retail")
+	require.True(t, ok)
+	assert.Equal(t, "1250.50", price.Amount)
+	stock, err := offer.stock()
+	require.NoError(t, err)
+	assert.Equal(t, 7, stock)
+
+	stock, err = doc.Offers.Offers[1].stock()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stock)
+}
+
+func TestParseRejectsOtherCharsets(t *testing.T) {
+	_, err := Parse(strings.NewReader(`<?xml version="1.0" encoding="windows-1251"?><КоммерческаяИнформация/>`))
+	assert.Error(t, err)
+}
+
+func TestParseQuantity(t *testing.T) {
+	for in, want := range map[string]int{"": 0, "5": 5, "5.000": 5, "2,5": 2, "-3": 0, ".5": 0} {
+		got, err := parseQuantity(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := parseQuantity("many")
+	assert.Error(t, err)
+}
+
+func TestParseCurrency(t *testing.T) {
+	for in, want := range map[string]models.Currency{"": models.BaseCurrency, "руб": models.CurrencyRUB, "руб.": models.CurrencyRUB, "643": models.CurrencyRUB, "eur": models.CurrencyEUR} {
+		got, err := parseCurrency(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := parseCurrency("тугрик")
+	assert.Error(t, err)
+}
+
+func TestWriteOrders(t *testing.T) {
+	created := time.Date(2026, 3, 2, 14, 30, 0, 0, time.UTC)
+	order := models.Order{
+		ID:           7,
+		Number:       "ORD-000007",
+		UserID:       uuid.MustParse("5f0c6a4e-7d1b-4c1e-9a57-3b2b8f1f0b11"),
+		User:         models.User{FirstName: "Иван", LastName: "Петров", Email: "ivan@example.com"},
+		Status:       models.OrderStatusPaid,
+		Currency:     models.CurrencyRUB,
+		ExchangeRate: models.MustParseRate("1"),
+		Total:        models.MustParseMoney("2250.00"),
+		TaxTotal:     models.MustParseMoney("375.00"),
+		CreatedAt:    created,
+		Items: []models.OrderItem{{
+			ProductExternalID: "a1",
+			Product:           models.Product{SKU: "PAN-24", Name: "Сковорода 24 см"},
+			Quantity:          2,
+			Price:             models.MustParseMoney("1250.00"),
+			TaxPercent:        20,
+			Tax:               models.MustParseMoney("375.00"),
+			Gross:             models.MustParseMoney("2250.00"),
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteOrders(&buf, []models.Order{order}, created))
+	assert.True(t, strings.HasPrefix(buf.String(), `<?xml version="1.0" encoding="UTF-8"?>`))
+
+	var doc orderDocument
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, SchemaVersion, doc.Version)
+	assert.Equal(t, "2026-03-02T14:30:00", doc.Generated)
+	require.Len(t, doc.Orders, 1)
+
+	written := doc.Orders[0]
+	assert.Equal(t, "ORD-000007", written.ID)
+	assert.Equal(t, "2026-03-02", written.Date)
+	assert.Equal(t, "14:30:00", written.Time)
+	assert.Equal(t, "RUB", written.Currency)
+	assert.Equal(t, "2250.00", written.Total)
+	require.Len(t, written.Counterparties, 1)
+	assert.Equal(t, "5f0c6a4e-7d1b-4c1e-9a57-3b2b8f1f0b11", written.Counterparties[0].ID)
+	assert.Equal(t, "Иван Петров", written.Counterparties[0].Name)
+	assert.Equal(t, []tax{{Name: "НДС", Included: true, Total: "375.00"}}, written.Taxes)
+	assert.Contains(t, written.Requisites, requisite{Name: "Статус заказа", Value: "paid"})
+
+	require.Len(t, written.Items, 1)
+	item := written.Items[0]
+	assert.Equal(t, "a1", item.ID)
+	assert.Equal(t, "PAN-24", item.Code)
+	assert.Equal(t, 2, item.Quantity)
+	assert.Equal(t, "1250.00", item.Price)
+	assert.Equal(t, "2250.00", item.Total)
+	assert.Equal(t, "шт", item.Unit.Name)
+	assert.Equal(t, []taxRate{{Name: "НДС", Rate: "20"}}, item.TaxRates)
+	assert.Equal(t, []discount{{Name: "Скидка", Total: "250.00", Included: true}}, item.Discounts)
+}
@@ -0,0 +1,265 @@
+package commerceml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/integration/onec"
+	"github.com/alzarasatken/FullStackTest/pkg/integration/sync"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Result counts the products an import touched. Updated are products that
+// changed or were restored; skipped are offers of unknown products and of
+// product variants, which the shop does not have.
+type Result struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+	Skipped int `json:"skipped"`
+}
+
+// catalogFields are the product fields catalogue products carry
+var catalogFields = []string{"code", "sku", "name", "description", "category"}
+
+// Import upserts the products of a document by their 1C ID. Catalogue
+// products set names, descriptions, codes and categories, creating products
+// 1C added and deleting those it removed; offers set prices and stock. Prices
+// are taken from the price type named priceType, or the first one when it
+// is empty. Fields are merged as the REST sync merges them, so changes made
+// in the shop are kept as ownership says; see sync.MergeProduct.
+func Import(tx *gorm.DB, doc *Document, priceType string, ownership sync.Ownership) (Result, error) {
+	var result Result
+	if doc.Catalog != nil {
+		categories := doc.Classifier.categories()
+		for _, p := range doc.Catalog.Products {
+			if err := importProduct(tx, p, categories, ownership, &result); err != nil {
+				return result, err
+			}
+		}
+	}
+	if doc.Offers != nil {
+		priceTypeID, err := doc.Offers.priceTypeID(priceType)
+		if err != nil {
+			return result, err
+		}
+		for _, offer := range doc.Offers.Offers {
+			if err := importOffer(tx, offer, doc.Offers.PriceTypes, priceTypeID, ownership, &result); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+func importProduct(tx *gorm.DB, p Product, categories map[string]string, ownership sync.Ownership, result *Result) error {
+	if p.ID == "" {
+		return fmt.Errorf("product %q has no ID", p.Name)
+	}
+	category := ""
+	if len(p.Groups) > 0 {
+		category = categories[p.Groups[0]]
+	}
+	incoming := onec.Product{
+		ID:          p.ID,
+		Code:        p.Code,
+		Name:        p.Name,
+		Description: p.Description,
+		Category:    category,
+	}
+
+	// Deleted products keep their 1C ID, so they come back if 1C restores
+	// them
+	var product models.Product
+	err := tx.Unscoped().Where("external_id = ?", p.ID).First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if p.IsDeleted() {
+			return nil
+		}
+		now := time.Now()
+		product = models.Product{
+			ExternalID:  p.ID,
+			Code:        p.Code,
+			SKU:         onec.SKU(p.ID, p.Code),
+			Name:        p.Name,
+			Description: p.Description,
+			Category:    category,
+			Currency:    models.BaseCurrency,
+			ModifiedBy:  models.ProductSourceOneC,
+			ModifiedAt:  &now,
+			SyncedAt:    &now,
+		}
+		if err := tx.Create(&product).Error; err != nil {
+			return fmt.Errorf("creating product %s: %w", p.ID, err)
+		}
+		// Nothing differs, but what 1C sent is kept for later merges
+		if _, err := sync.MergeProduct(tx, &product, incoming, ownership, catalogFields...); err != nil {
+			return err
+		}
+		result.Created++
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading product %s: %w", p.ID, err)
+	}
+
+	if p.IsDeleted() {
+		if product.DeletedAt.Valid {
+			return nil
+		}
+		if err := tx.Delete(&product).Error; err != nil {
+			return fmt.Errorf("deleting product %s: %w", p.ID, err)
+		}
+		result.Deleted++
+		return nil
+	}
+
+	restored := product.DeletedAt.Valid
+	if restored {
+		if err := tx.Unscoped().Model(&product).Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("restoring product %s: %w", p.ID, err)
+		}
+		product.DeletedAt = gorm.DeletedAt{}
+	}
+	changed, err := sync.MergeProduct(tx, &product, incoming, ownership, catalogFields...)
+	if err != nil {
+		return err
+	}
+	if changed || restored {
+		result.Updated++
+	}
+	return nil
+}
+
+func importOffer(tx *gorm.DB, offer Offer, priceTypes []PriceType, priceTypeID string, ownership sync.Ownership, result *Result) error {
+	if strings.Contains(offer.ID, "#") {
+		result.Skipped++
+		return nil
+	}
+
+	var product models.Product
+	err := tx.Where("external_id = ?", offer.ID).First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		result.Skipped++
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading product %s: %w", offer.ID, err)
+	}
+
+	stock, err := offer.stock()
+	if err != nil {
+		return fmt.Errorf("offer %s: %w", offer.ID, err)
+	}
+	incoming := onec.Product{ID: offer.ID, Stock: stock}
+	fields := []string{"stock"}
+	if price, ok := offer.price(priceTypeID); ok {
+		amount, err := models.ParseMoney(price.Amount)
+		if err != nil {
+			return fmt.Errorf("offer %s: %w", offer.ID, err)
+		}
+		currencyName := price.Currency
+		for _, t := range priceTypes {
+			if currencyName == "" && t.ID == price.PriceTypeID {
+				currencyName = t.Currency
+			}
+		}
+		currency, err := parseCurrency(currencyName)
+		if err != nil {
+			return fmt.Errorf("offer %s: %w", offer.ID, err)
+		}
+		incoming.Price, incoming.Currency = onec.Amount{Money: amount}, currency
+		fields = append(fields, "price")
+	}
+
+	changed, err := sync.MergeProduct(tx, &product, incoming, ownership, fields...)
+	if err != nil {
+		return err
+	}
+	if changed {
+		result.Updated++
+	}
+	return nil
+}
+
+// priceTypeID finds the price type named name, or the first one
+func (o *OfferPackage) priceTypeID(name string) (string, error) {
+	if name == "" {
+		if len(o.PriceTypes) > 0 {
+			return o.PriceTypes[0].ID, nil
+		}
+		return "", nil
+	}
+	for _, t := range o.PriceTypes {
+		if strings.EqualFold(strings.TrimSpace(t.Name), name) {
+			return t.ID, nil
+		}
+	}
+	return "", fmt.Errorf("price type %q not found in offers", name)
+}
+
+// price returns the offer's price of the given type, or its only price when
+// no type is known
+func (o Offer) price(priceTypeID string) (Price, bool) {
+	for _, p := range o.Prices {
+		if p.PriceTypeID == priceTypeID || priceTypeID == "" {
+			return p, true
+		}
+	}
+	return Price{}, false
+}
+
+// stock is the offer's quantity, summed over its warehouses when no total is
+// given. 1C writes quantities as decimals; fractions of units are dropped and
+// shortfalls count as none in stock.
+func (o Offer) stock() (int, error) {
+	if strings.TrimSpace(o.Quantity) != "" || len(o.Warehouses) == 0 {
+		return parseQuantity(o.Quantity)
+	}
+	total := 0
+	for _, w := range o.Warehouses {
+		n, err := parseQuantity(w.Quantity)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func parseQuantity(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	whole, _, _ := strings.Cut(strings.ReplaceAll(s, ",", "."), ".")
+	if whole == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(whole)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	if n < 0 {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// parseCurrency reads the currencies of prices, which 1C gives as ISO codes
+// or, for roubles, often as "руб" or the numeric code 643. Prices without a
+// currency are in the base currency.
+func parseCurrency(s string) (models.Currency, error) {
+	switch strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".") {
+	case "":
+		return models.BaseCurrency, nil
+	case "руб", "643":
+		return models.CurrencyRUB, nil
+	}
+	return models.ParseCurrency(s)
+}
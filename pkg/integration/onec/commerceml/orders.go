@@ -0,0 +1,190 @@
+package commerceml
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/integration/onec"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+)
+
+// SchemaVersion is the CommerceML version of the documents written
+const SchemaVersion = "2.05"
+
+// vat is the name of VAT in 1C
+const vat = "НДС"
+
+type orderDocument struct {
+	XMLName   xml.Name `xml:"КоммерческаяИнформация"`
+	Version   string   `xml:"ВерсияСхемы,attr"`
+	Generated string   `xml:"ДатаФормирования,attr"`
+	Orders    []order  `xml:"Документ"`
+}
+
+type order struct {
+	ID             string         `xml:"Ид"`
+	Number         string         `xml:"Номер"`
+	Date           string         `xml:"Дата"`
+	Operation      string         `xml:"ХозОперация"`
+	Role           string         `xml:"Роль"`
+	Currency       string         `xml:"Валюта"`
+	Rate           string         `xml:"Курс"`
+	Total          string         `xml:"Сумма"`
+	Counterparties []counterparty `xml:"Контрагенты>Контрагент"`
+	Time           string         `xml:"Время"`
+	Taxes          []tax          `xml:"Налоги>Налог,omitempty"`
+	Items          []orderItem    `xml:"Товары>Товар"`
+	Requisites     []requisite    `xml:"ЗначенияРеквизитов>ЗначениеРеквизита"`
+}
+
+type counterparty struct {
+	ID       string    `xml:"Ид"`
+	Name     string    `xml:"Наименование"`
+	FullName string    `xml:"ПолноеНаименование"`
+	Role     string    `xml:"Роль"`
+	Contacts []contact `xml:"Контакты>Контакт,omitempty"`
+}
+
+type contact struct {
+	Type  string `xml:"Тип"`
+	Value string `xml:"Значение"`
+}
+
+type orderItem struct {
+	ID         string      `xml:"Ид"`
+	Code       string      `xml:"Артикул,omitempty"`
+	Name       string      `xml:"Наименование"`
+	Unit       unit        `xml:"БазоваяЕдиница"`
+	Price      string      `xml:"ЦенаЗаЕдиницу"`
+	Quantity   int         `xml:"Количество"`
+	Total      string      `xml:"Сумма"`
+	Discounts  []discount  `xml:"Скидки>Скидка,omitempty"`
+	TaxRates   []taxRate   `xml:"СтавкиНалогов>СтавкаНалога"`
+	Taxes      []tax       `xml:"Налоги>Налог,omitempty"`
+	Requisites []requisite `xml:"ЗначенияРеквизитов>ЗначениеРеквизита"`
+}
+
+type unit struct {
+	Code     string `xml:"Код,attr"`
+	FullName string `xml:"НаименованиеПолное,attr"`
+	Intl     string `xml:"МеждународноеСокращение,attr"`
+	Name     string `xml:",chardata"`
+}
+
+// piece is the unit items are sold in
+var piece = unit{Code: "796", FullName: "Штука", Intl: "PCE", Name: "шт"}
+
+type discount struct {
+	Name     string `xml:"Наименование"`
+	Total    string `xml:"Сумма"`
+	Included bool   `xml:"УчтеноВСумме"`
+}
+
+type taxRate struct {
+	Name string `xml:"Наименование"`
+	Rate string `xml:"Ставка"`
+}
+
+type tax struct {
+	Name     string `xml:"Наименование"`
+	Included bool   `xml:"УчтеноВСумме"`
+	Total    string `xml:"Сумма"`
+}
+
+type requisite struct {
+	Name  string `xml:"Наименование"`
+	Value string `xml:"Значение"`
+}
+
+// WriteOrders writes orders as a CommerceML document of "Заказ товара"
+// documents, the orders.xml 1C pulls. Orders need their Items with their
+// Product and their User loaded. Amounts are in the order's currency and
+// include VAT, as do the shop's prices.
+func WriteOrders(w io.Writer, orders []models.Order, generated time.Time) error {
+	doc := orderDocument{
+		Version:   SchemaVersion,
+		Generated: generated.Format("2006-01-02T15:04:05"),
+		Orders:    make([]order, len(orders)),
+	}
+	for i, o := range orders {
+		doc.Orders[i] = newOrder(o)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func newOrder(o models.Order) order {
+	name := strings.TrimSpace(o.User.FirstName + " " + o.User.LastName)
+	if name == "" {
+		name = o.User.Email
+	}
+	customer := counterparty{
+		ID:       onec.CustomerID(o),
+		Name:     name,
+		FullName: name,
+		Role:     "Покупатель",
+	}
+	if o.User.Email != "" {
+		customer.Contacts = append(customer.Contacts, contact{Type: "Электронная почта", Value: o.User.Email})
+	}
+	if o.User.Phone != "" {
+		customer.Contacts = append(customer.Contacts, contact{Type: "Телефон рабочий", Value: o.User.Phone})
+	}
+
+	doc := order{
+		ID:             onec.OrderID(o),
+		Number:         o.Number,
+		Date:           o.CreatedAt.Format("2006-01-02"),
+		Operation:      "Заказ товара",
+		Role:           "Продавец",
+		Currency:       string(o.Currency),
+		Rate:           o.ExchangeRate.String(),
+		Total:          o.Total.String(),
+		Counterparties: []counterparty{customer},
+		Time:           o.CreatedAt.Format("15:04:05"),
+		Requisites: []requisite{
+			{Name: "Статус заказа", Value: string(o.Status)},
+			{Name: "Отменен", Value: strconv.FormatBool(o.Status == models.OrderStatusCancelled)},
+		},
+	}
+	if !o.TaxTotal.IsZero() {
+		doc.Taxes = []tax{{Name: vat, Included: true, Total: o.TaxTotal.String()}}
+	}
+
+	for _, item := range o.Items {
+		line := orderItem{
+			ID:       item.ProductExternalID,
+			Code:     item.Product.SKU,
+			Name:     item.Product.Name,
+			Unit:     piece,
+			Price:    item.Price.String(),
+			Quantity: item.Quantity,
+			Total:    item.Gross.String(),
+			TaxRates: []taxRate{{Name: vat, Rate: "Без налога"}},
+			Requisites: []requisite{
+				{Name: "ВидНоменклатуры", Value: "Товар"},
+				{Name: "ТипНоменклатуры", Value: "Товар"},
+			},
+		}
+		if discounted := item.Price.Mul(item.Quantity).Sub(item.Gross); discounted.Cmp(models.Money{}) > 0 {
+			line.Discounts = []discount{{Name: "Скидка", Total: discounted.String(), Included: true}}
+		}
+		if item.TaxPercent > 0 {
+			line.TaxRates[0].Rate = strconv.Itoa(item.TaxPercent)
+			line.Taxes = []tax{{Name: vat, Included: true, Total: item.Tax.String()}}
+		}
+		doc.Items = append(doc.Items, line)
+	}
+	return doc
+}
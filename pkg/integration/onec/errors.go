@@ -0,0 +1,61 @@
+package onec
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUnauthorized is returned when 1C rejects the API key
+	ErrUnauthorized = errors.New("1C rejected the credentials")
+	// ErrValidation is returned when 1C rejects a request as malformed or
+	// unknown; sending it again will not help
+	ErrValidation = errors.New("1C rejected the request")
+	// ErrTransient is returned for failures that may pass: network errors,
+	// timeouts, throttling and server errors
+	ErrTransient = errors.New("1C is temporarily unavailable")
+	// ErrCircuitOpen is returned without calling 1C while the circuit
+	// breaker is open. It matches ErrTransient too.
+	ErrCircuitOpen = fmt.Errorf("%w: circuit breaker open", ErrTransient)
+)
+
+// StatusError is a non-200 response from 1C. It matches ErrUnauthorized,
+// ErrValidation or ErrTransient depending on its status code.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code: %d: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap returns the kind of failure the status code stands for
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500:
+		return ErrTransient
+	default:
+		return ErrValidation
+	}
+}
+
+// transportError is a request that got no response
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return fmt.Sprintf("executing request: %v", e.err)
+}
+
+func (e *transportError) Unwrap() []error {
+	return []error{ErrTransient, e.err}
+}
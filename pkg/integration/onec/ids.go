@@ -0,0 +1,30 @@
+package onec
+
+import "github.com/alzarasatken/FullStackTest/pkg/models"
+
+// OrderID is the ID 1C knows an order by: its own once assigned, the order
+// number before
+func OrderID(order models.Order) string {
+	if order.ExternalID != "" {
+		return order.ExternalID
+	}
+	return order.Number
+}
+
+// CustomerID is the customer's counterparty in 1C, or the user ID for
+// customers 1C does not know yet
+func CustomerID(order models.Order) string {
+	if order.UserExternalID != "" {
+		return order.UserExternalID
+	}
+	return order.UserID.String()
+}
+
+// SKU is the SKU of a product imported from 1C: its item code, or its 1C ID
+// for items without one, since SKUs must be unique
+func SKU(id, code string) string {
+	if code != "" {
+		return code
+	}
+	return "1C-" + id
+}
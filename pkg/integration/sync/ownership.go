@@ -0,0 +1,229 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/integration/onec"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ownership names for each product field synced from 1C the side whose
+// change is kept when the shop and 1C both changed the field since the last
+// sync. A change made by one side alone is always kept. Fields without an
+// owner belong to 1C.
+type Ownership map[string]models.ProductSource
+
+// DefaultOwnership leaves 1C the fields its accounting depends on and the
+// shop the ones customers read
+var DefaultOwnership = Ownership{
+	"code":        models.ProductSourceOneC,
+	"sku":         models.ProductSourceOneC,
+	"price":       models.ProductSourceOneC,
+	"stock":       models.ProductSourceOneC,
+	"name":        models.ProductSourceShop,
+	"description": models.ProductSourceShop,
+	"category":    models.ProductSourceShop,
+}
+
+// owner returns the side owning field
+func (o Ownership) owner(field string) models.ProductSource {
+	if owner, ok := o[field]; ok {
+		return owner
+	}
+	return models.ProductSourceOneC
+}
+
+// productField is a product field synced from 1C, read from either side
+type productField struct {
+	name string
+	shop func(product *models.Product) interface{}
+	onec func(p onec.Product) interface{}
+}
+
+var productFields = []productField{
+	{"code", func(product *models.Product) interface{} { return product.Code }, func(p onec.Product) interface{} { return p.Code }},
+	{"sku", func(product *models.Product) interface{} { return product.SKU }, func(p onec.Product) interface{} { return onec.SKU(p.ID, p.Code) }},
+	{"name", func(product *models.Product) interface{} { return product.Name }, func(p onec.Product) interface{} { return p.Name }},
+	{"description", func(product *models.Product) interface{} { return product.Description }, func(p onec.Product) interface{} { return p.Description }},
+	{"price", func(product *models.Product) interface{} { return price{product.Price, product.Currency} }, func(p onec.Product) interface{} { return price{p.Price.Money, p.Currency} }},
+	{"stock", func(product *models.Product) interface{} { return product.Stock }, func(p onec.Product) interface{} { return p.Stock }},
+	{"category", func(product *models.Product) interface{} { return product.Category }, func(p onec.Product) interface{} { return p.Category }},
+}
+
+// price is a product's price in its currency. It reads as the amount alone
+// in the base currency, so prices 1C sent before currencies were kept still
+// match.
+type price struct {
+	amount   models.Money
+	currency models.Currency
+}
+
+func (p price) String() string {
+	if p.currency == "" || p.currency == models.BaseCurrency {
+		return p.amount.String()
+	}
+	return p.amount.String() + " " + string(p.currency)
+}
+
+// ProductFields lists the product fields synced from 1C
+func ProductFields() []string {
+	names := make([]string, len(productFields))
+	for i, field := range productFields {
+		names[i] = field.name
+	}
+	return names
+}
+
+// productMerge is what a sync does to a product imported before: the
+// fields taking 1C's value, the values 1C sent and the conflicts found
+type productMerge struct {
+	updates   map[string]interface{}
+	values    []models.ProductSyncValue
+	conflicts []models.SyncConflict
+}
+
+// mergeProduct works out which of p's values replace product's, for the
+// named fields only if any are given. synced holds the fields as 1C last sent
+// them; a field missing there, as on the first sync of a product imported
+// before they were kept, goes to its owner.
+func mergeProduct(product *models.Product, p onec.Product, synced map[string]string, ownership Ownership, fields ...string) productMerge {
+	merge := productMerge{updates: map[string]interface{}{}}
+	for _, field := range productFields {
+		if len(fields) > 0 && !slices.Contains(fields, field.name) {
+			continue
+		}
+		value := field.onec(p)
+		shopValue, oneCValue := fmt.Sprint(field.shop(product)), fmt.Sprint(value)
+		merge.values = append(merge.values, models.ProductSyncValue{
+			ProductID: product.ID,
+			Field:     field.name,
+			Value:     oneCValue,
+		})
+		if shopValue == oneCValue {
+			continue
+		}
+
+		owner := ownership.owner(field.name)
+		takeOneC := owner == models.ProductSourceOneC
+		if syncedValue, ok := synced[field.name]; ok {
+			switch {
+			case shopValue == syncedValue:
+				takeOneC = true
+			case oneCValue == syncedValue:
+				takeOneC = false
+			default:
+				merge.conflicts = append(merge.conflicts, models.SyncConflict{
+					ProductID:   product.ID,
+					Field:       field.name,
+					SyncedValue: syncedValue,
+					ShopValue:   shopValue,
+					OneCValue:   oneCValue,
+					Winner:      owner,
+				})
+			}
+		}
+		if takeOneC {
+			merge.updates[field.name] = value
+		}
+	}
+	if value, ok := merge.updates["price"].(price); ok {
+		merge.updates["price"] = value.amount
+		merge.updates["currency"] = value.currency
+		if value.currency == "" {
+			merge.updates["currency"] = models.BaseCurrency
+		}
+	}
+	return merge
+}
+
+// MergeProduct merges the named fields of p into product the way
+// SyncProducts does, for other ways 1C sends products such as the CommerceML
+// exchange: changes made in the shop are kept as Ownership says, conflicts
+// are logged and what 1C sent is kept for the next merge. It reports whether
+// the product changed.
+func MergeProduct(tx *gorm.DB, product *models.Product, p onec.Product, ownership Ownership, fields ...string) (bool, error) {
+	if ownership == nil {
+		ownership = DefaultOwnership
+	}
+	synced, err := loadSyncValues(tx, product.ID)
+	if err != nil {
+		return false, err
+	}
+	return applyMerge(tx, product, mergeProduct(product, p, synced, ownership, fields...), time.Now())
+}
+
+// loadSyncValues returns the fields of a product as 1C last sent them
+func loadSyncValues(tx *gorm.DB, productID uint) (map[string]string, error) {
+	var values []models.ProductSyncValue
+	if err := tx.Where("product_id = ?", productID).Find(&values).Error; err != nil {
+		return nil, fmt.Errorf("loading synced values of product %d: %w", productID, err)
+	}
+
+	synced := make(map[string]string, len(values))
+	for _, value := range values {
+		synced[value.Field] = value.Value
+	}
+	return synced, nil
+}
+
+// applyMerge writes merge to product, recording its conflicts and what 1C
+// sent, and reports whether the product changed
+func applyMerge(tx *gorm.DB, product *models.Product, merge productMerge, now time.Time) (bool, error) {
+	if len(merge.updates) > 0 {
+		merge.updates["modified_by"] = models.ProductSourceOneC
+		merge.updates["modified_at"] = now
+		merge.updates["synced_at"] = now
+		if err := tx.Model(product).Updates(merge.updates).Error; err != nil {
+			return false, fmt.Errorf("updating product %s: %w", product.ExternalID, err)
+		}
+	} else if err := tx.Model(product).UpdateColumn("synced_at", now).Error; err != nil {
+		return false, fmt.Errorf("updating product %s: %w", product.ExternalID, err)
+	}
+
+	if len(merge.conflicts) > 0 {
+		if err := tx.Create(&merge.conflicts).Error; err != nil {
+			return false, fmt.Errorf("recording conflicts of product %s: %w", product.ExternalID, err)
+		}
+	}
+	if err := saveSyncValues(tx, merge.values); err != nil {
+		return false, err
+	}
+	return len(merge.updates) > 0, nil
+}
+
+// saveSyncValues records the fields of a product as 1C sent them
+func saveSyncValues(tx *gorm.DB, values []models.ProductSyncValue) error {
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "field"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value"}),
+	}).Create(&values).Error; err != nil {
+		return fmt.Errorf("saving synced values: %w", err)
+	}
+	return nil
+}
+
+// Conflicts returns a page of the conflict log, newest first, optionally of
+// one product only
+func (s *Service) Conflicts(productID uint, page, limit int) ([]models.SyncConflict, int64, error) {
+	var total int64
+	var conflicts []models.SyncConflict
+
+	query := s.db.Model(&models.SyncConflict{})
+	if productID != 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&conflicts).Error
+	return conflicts, total, err
+}
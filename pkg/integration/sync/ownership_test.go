@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/alzarasatken/FullStackTest/pkg/integration/onec"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeProduct(t *testing.T) {
+	synced := map[string]string{
+		"code":        "00042",
+		"sku":         "00042",
+		"name":        "Pan",
+		"description": "A pan",
+		"price":       "12.00",
+		"stock":       "10",
+		"category":    "Kitchen",
+	}
+	product := func() *models.Product {
+		return &models.Product{
+			ID:          7,
+			ExternalID:  "1c-pan",
+			Code:        "00042",
+			SKU:         "00042",
+			Name:        "Pan",
+			Description: "A pan",
+			Price:       models.MustParseMoney("12.00"),
+			Stock:       10,
+			Category:    "Kitchen",
+		}
+	}
+	incoming := func() onec.Product {
+		return onec.Product{
+			ID:          "1c-pan",
+			Code:        "00042",
+			Name:        "Pan",
+			Description: "A pan",
+			Price:       onec.Amount{Money: models.MustParseMoney("12.00")},
+			Stock:       10,
+			Category:    "Kitchen",
+		}
+	}
+
+	t.Run("Nothing changed", func(t *testing.T) {
+		merge := mergeProduct(product(), incoming(), synced, DefaultOwnership)
+		assert.Empty(t, merge.updates)
+		assert.Empty(t, merge.conflicts)
+		assert.Len(t, merge.values, len(productFields))
+	})
+
+	t.Run("A change made on one side is kept", func(t *testing.T) {
+		local := product()
+		local.Description = "A pan marketing rewrote"
+		local.Price = models.MustParseMoney("11.00")
+		p := incoming()
+		p.Name = "Frying pan"
+		p.Stock = 8
+
+		merge := mergeProduct(local, p, synced, DefaultOwnership)
+		assert.Equal(t, map[string]interface{}{"name": "Frying pan", "stock": 8}, merge.updates)
+		assert.Empty(t, merge.conflicts)
+	})
+
+	t.Run("The owner wins a conflict", func(t *testing.T) {
+		local := product()
+		local.Description = "A pan marketing rewrote"
+		local.Price = models.MustParseMoney("11.00")
+		p := incoming()
+		p.Description = "A pan from 1C"
+		p.Price = onec.Amount{Money: models.MustParseMoney("13.50")}
+
+		merge := mergeProduct(local, p, synced, DefaultOwnership)
+		assert.Equal(t, map[string]interface{}{
+			"price":    models.MustParseMoney("13.50"),
+			"currency": models.BaseCurrency,
+		}, merge.updates)
+		require.Len(t, merge.conflicts, 2)
+		assert.Equal(t, models.SyncConflict{
+			ProductID:   7,
+			Field:       "description",
+			SyncedValue: "A pan",
+			ShopValue:   "A pan marketing rewrote",
+			OneCValue:   "A pan from 1C",
+			Winner:      models.ProductSourceShop,
+		}, merge.conflicts[0])
+		assert.Equal(t, "price", merge.conflicts[1].Field)
+		assert.Equal(t, models.ProductSourceOneC, merge.conflicts[1].Winner)
+
+		merge = mergeProduct(local, p, synced, Ownership{"description": models.ProductSourceOneC})
+		assert.Equal(t, "A pan from 1C", merge.updates["description"])
+	})
+
+	t.Run("Without synced values the owner's value is kept", func(t *testing.T) {
+		local := product()
+		local.Description = "A pan marketing rewrote"
+		local.Stock = 9
+
+		merge := mergeProduct(local, incoming(), nil, DefaultOwnership)
+		assert.Equal(t, map[string]interface{}{"stock": 10}, merge.updates)
+		assert.Empty(t, merge.conflicts)
+	})
+
+	t.Run("Only the named fields are merged", func(t *testing.T) {
+		p := onec.Product{ID: "1c-pan", Stock: 8}
+
+		merge := mergeProduct(product(), p, synced, DefaultOwnership, "stock")
+		assert.Equal(t, map[string]interface{}{"stock": 8}, merge.updates)
+		require.Len(t, merge.values, 1)
+		assert.Equal(t, "stock", merge.values[0].Field)
+	})
+
+	t.Run("Prices in another currency change the currency", func(t *testing.T) {
+		p := incoming()
+		p.Currency = models.Currency("EUR")
+
+		merge := mergeProduct(product(), p, synced, DefaultOwnership, "price")
+		assert.Equal(t, map[string]interface{}{
+			"price":    models.MustParseMoney("12.00"),
+			"currency": models.Currency("EUR"),
+		}, merge.updates)
+		assert.Equal(t, "12.00 EUR", merge.values[0].Value)
+	})
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alzarasatken/FullStackTest/pkg/integration/onec"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ProductDiff is what a product sync would change: the products it would
+// create, the prices and stock levels it would change, the products it
+// would delete because 1C marked them deleted and the conflicts it would
+// record
+type ProductDiff struct {
+	Fetched      int                   `json:"fetched"`
+	Created      []ProductChange       `json:"created"`
+	PriceChanges []ProductChange       `json:"priceChanges"`
+	StockChanges []ProductChange       `json:"stockChanges"`
+	Deactivated  []ProductChange       `json:"deactivated"`
+	Conflicts    []models.SyncConflict `json:"conflicts"`
+}
+
+// ProductChange is a change to one product. Created products have only the
+// new values, deactivated ones only the old.
+type ProductChange struct {
+	ExternalID string        `json:"externalId"`
+	SKU        string        `json:"sku"`
+	Name       string        `json:"name"`
+	OldPrice   *models.Money `json:"oldPrice,omitempty"`
+	NewPrice   *models.Money `json:"newPrice,omitempty"`
+	OldStock   *int          `json:"oldStock,omitempty"`
+	NewStock   *int          `json:"newStock,omitempty"`
+}
+
+// OrderDiff is what an order sync would send 1C
+type OrderDiff struct {
+	Orders []onec.Order `json:"orders"`
+}
+
+// PreviewProducts works out what SyncProducts would change without changing
+// anything; force previews a full resync
+func (s *Service) PreviewProducts(ctx context.Context, force bool) (*ProductDiff, error) {
+	products, _, err := s.fetchProducts(ctx, force)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ProductDiff{
+		Fetched:      len(products),
+		Created:      []ProductChange{},
+		PriceChanges: []ProductChange{},
+		StockChanges: []ProductChange{},
+		Deactivated:  []ProductChange{},
+		Conflicts:    []models.SyncConflict{},
+	}
+	for _, p := range products {
+		var product models.Product
+		err := s.db.Where("external_id = ?", p.ID).First(&product).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if !p.Deleted {
+				price, stock := p.Price.Money, p.Stock
+				diff.Created = append(diff.Created, ProductChange{
+					ExternalID: p.ID,
+					SKU:        onec.SKU(p.ID, p.Code),
+					Name:       p.Name,
+					NewPrice:   &price,
+					NewStock:   &stock,
+				})
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading product %s: %w", p.ID, err)
+		}
+
+		change := ProductChange{ExternalID: p.ID, SKU: product.SKU, Name: product.Name}
+		if p.Deleted {
+			change.OldPrice, change.OldStock = &product.Price, &product.Stock
+			diff.Deactivated = append(diff.Deactivated, change)
+			continue
+		}
+
+		synced, err := loadSyncValues(s.db, product.ID)
+		if err != nil {
+			return nil, err
+		}
+		merge := mergeProduct(&product, p, synced, s.ownership)
+		if price, ok := merge.updates["price"].(models.Money); ok {
+			priceChange := change
+			priceChange.OldPrice, priceChange.NewPrice = &product.Price, &price
+			diff.PriceChanges = append(diff.PriceChanges, priceChange)
+		}
+		if stock, ok := merge.updates["stock"].(int); ok {
+			stockChange := change
+			stockChange.OldStock, stockChange.NewStock = &product.Stock, &stock
+			diff.StockChanges = append(diff.StockChanges, stockChange)
+		}
+		diff.Conflicts = append(diff.Conflicts, merge.conflicts...)
+	}
+	return diff, nil
+}
+
+// PreviewOrders returns what SyncOrders would send 1C without sending it;
+// force previews resending every order
+func (s *Service) PreviewOrders(force bool) (*OrderDiff, error) {
+	orders, err := s.pendingOrders(force)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderDiff{Orders: toOneCOrders(orders)}, nil
+}
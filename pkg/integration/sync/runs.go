@@ -0,0 +1,123 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// JobStatus sums up the history of a sync job. LastSuccess is when its last
+// successful run finished and LagSeconds how long ago that was; LastError is
+// the error of its last failed run, if any.
+type JobStatus struct {
+	LastRun     *models.SyncRun `json:"lastRun"`
+	LastSuccess *time.Time      `json:"lastSuccess"`
+	LagSeconds  *int64          `json:"lagSeconds"`
+	LastError   string          `json:"lastError,omitempty"`
+	LastErrorAt *time.Time      `json:"lastErrorAt,omitempty"`
+}
+
+// Healthy reports whether the job's last finished run succeeded
+func (j JobStatus) Healthy() bool {
+	return j.LastError == "" || j.LastSuccess != nil && !j.LastSuccess.Before(*j.LastErrorAt)
+}
+
+// run records a run of a sync job around fn, which fills in its counts. The
+// run is recorded even when fn fails, with the error.
+func (s *Service) run(kind models.SyncType, trigger models.SyncTrigger, fn func(run *models.SyncRun) error) (*models.SyncRun, error) {
+	run := &models.SyncRun{Type: kind, Trigger: trigger, StartedAt: time.Now()}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("recording sync run: %w", err)
+	}
+
+	err := fn(run)
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if saveErr := s.db.Save(run).Error; saveErr != nil {
+		log.Printf("Error recording end of %s sync run %d: %v", kind, run.ID, saveErr)
+	}
+	return run, err
+}
+
+// Status sums up the history of every sync job
+func (s *Service) Status(now time.Time) (map[models.SyncType]JobStatus, error) {
+	jobs := make(map[models.SyncType]JobStatus, len(models.SyncTypes))
+	for _, kind := range models.SyncTypes {
+		var job JobStatus
+
+		lastRun, err := s.lastRun(kind, "")
+		if err != nil {
+			return nil, err
+		}
+		job.LastRun = lastRun
+
+		success, err := s.lastRun(kind, "finished_at IS NOT NULL AND error = ''")
+		if err != nil {
+			return nil, err
+		}
+		if success != nil {
+			lag := int64(now.Sub(*success.FinishedAt) / time.Second)
+			job.LastSuccess = success.FinishedAt
+			job.LagSeconds = &lag
+		}
+
+		failure, err := s.lastRun(kind, "error <> ''")
+		if err != nil {
+			return nil, err
+		}
+		if failure != nil {
+			job.LastError = failure.Error
+			job.LastErrorAt = failure.FinishedAt
+		}
+
+		jobs[kind] = job
+	}
+	return jobs, nil
+}
+
+// Runs returns a page of the sync history, newest first, optionally of one
+// job only
+func (s *Service) Runs(kind models.SyncType, page, limit int) ([]models.SyncRun, int64, error) {
+	var total int64
+	var runs []models.SyncRun
+
+	query := s.db.Model(&models.SyncRun{})
+	if kind != "" {
+		query = query.Where("type = ?", kind)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("started_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&runs).Error
+	return runs, total, err
+}
+
+// lastRun returns the latest run of a job matching condition, if any
+func (s *Service) lastRun(kind models.SyncType, condition string) (*models.SyncRun, error) {
+	query := s.db.Where("type = ?", kind)
+	if condition != "" {
+		query = query.Where(condition)
+	}
+
+	var run models.SyncRun
+	err := query.Order("started_at DESC, id DESC").First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading last %s sync run: %w", kind, err)
+	}
+	return &run, nil
+}
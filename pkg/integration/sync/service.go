@@ -3,12 +3,14 @@ package sync
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	"fullstacktest/pkg/integration/onec"
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/integration/onec"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/streadway/amqp"
@@ -19,96 +21,237 @@ import (
 type Service struct {
 	db          *gorm.DB
 	onecClient  *onec.Client
+	machine     *fsm.Machine
 	redis       *redis.Client
 	rabbitmq    *amqp.Channel
 	syncQueue   string
 	updateQueue string
+	ownership   Ownership
 }
 
-// NewService creates a new synchronization service
-func NewService(db *gorm.DB, onecClient *onec.Client, redis *redis.Client, rabbitmq *amqp.Channel) *Service {
+// NewService creates a new synchronization service. Status updates from 1C
+// go through machine.
+func NewService(db *gorm.DB, onecClient *onec.Client, redis *redis.Client, rabbitmq *amqp.Channel, machine *fsm.Machine) *Service {
 	return &Service{
 		db:          db,
 		onecClient:  onecClient,
+		machine:     machine,
 		redis:       redis,
 		rabbitmq:    rabbitmq,
 		syncQueue:   "sync_queue",
 		updateQueue: "update_queue",
+		ownership:   DefaultOwnership,
 	}
 }
 
-// SyncProducts synchronizes products with 1C
-func (s *Service) SyncProducts(ctx context.Context) error {
-	// Get last sync time from Redis
-	lastSync, err := s.redis.Get(ctx, "last_product_sync").Time()
-	if err != nil && err != redis.Nil {
-		return fmt.Errorf("getting last sync time: %w", err)
+// SetOwnership sets which side wins each product field both the shop and 1C
+// changed since the last sync
+func (s *Service) SetOwnership(ownership Ownership) {
+	s.ownership = ownership
+}
+
+// lastProductSyncKey is the Redis key holding when the last successful
+// product sync started
+const lastProductSyncKey = "last_product_sync"
+
+// SyncProducts imports the products changed in 1C since the last successful
+// run, or all of them when the run is forced. Changes made in the shop are
+// kept unless 1C changed the same field and owns it; see Ownership. A
+// product that cannot be saved is counted as failed without stopping the
+// others; the run then fails and the next one fetches them again.
+func (s *Service) SyncProducts(ctx context.Context, trigger models.SyncTrigger) (*models.SyncRun, error) {
+	return s.run(models.SyncTypeProducts, trigger, func(run *models.SyncRun) error {
+		return s.syncProducts(ctx, run)
+	})
+}
+
+func (s *Service) syncProducts(ctx context.Context, run *models.SyncRun) error {
+	products, startedAt, err := s.fetchProducts(ctx, run.Trigger == models.SyncTriggerForced)
+	if err != nil {
+		return err
 	}
+	run.Fetched = len(products)
 
-	// Fetch products from 1C
-	products, err := s.onecClient.GetProducts(ctx, &lastSync)
+	// Update products in database, each behind a savepoint so one bad
+	// product does not undo the others
+	var firstErr error
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range products {
+			if err := tx.SavePoint("product").Error; err != nil {
+				return fmt.Errorf("creating savepoint: %w", err)
+			}
+			if err := s.upsertProduct(tx, p, run); err != nil {
+				if err := tx.RollbackTo("product").Error; err != nil {
+					return fmt.Errorf("rolling back product %s: %w", p.ID, err)
+				}
+				run.Failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("fetching products: %w", err)
+		return err
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d products failed: %w", run.Failed, run.Fetched, firstErr)
 	}
 
-	// Begin transaction
-	tx := s.db.Begin()
-	if tx.Error != nil {
-		return fmt.Errorf("beginning transaction: %w", tx.Error)
+	// Update last sync time
+	if err := s.redis.Set(ctx, lastProductSyncKey, startedAt, 0).Err(); err != nil {
+		return fmt.Errorf("updating last sync time: %w", err)
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+
+	return nil
+}
+
+// fetchProducts fetches the products changed in 1C since the last successful
+// sync, or all of them when full is set, and reports when it asked
+func (s *Service) fetchProducts(ctx context.Context, full bool) ([]onec.Product, time.Time, error) {
+	var since *time.Time
+	if !full {
+		lastSync, err := s.redis.Get(ctx, lastProductSyncKey).Time()
+		if err != nil && err != redis.Nil {
+			return nil, time.Time{}, fmt.Errorf("getting last sync time: %w", err)
+		}
+		if err == nil {
+			since = &lastSync
 		}
-	}()
+	}
+
+	startedAt := time.Now()
+	products, err := s.onecClient.GetProducts(ctx, since)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("fetching products: %w", err)
+	}
+	return products, startedAt, nil
+}
+
+// upsertProduct creates the product with p's 1C ID, merges p into it or
+// deletes it when 1C marked it deleted, and counts what it did in run
+func (s *Service) upsertProduct(tx *gorm.DB, p onec.Product, run *models.SyncRun) error {
+	now := time.Now()
 
-	// Update products in database
-	for _, p := range products {
-		product := models.Product{
+	var product models.Product
+	err := tx.Where("external_id = ?", p.ID).First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if p.Deleted {
+			return nil
+		}
+		product = models.Product{
 			ExternalID:  p.ID,
 			Code:        p.Code,
+			SKU:         onec.SKU(p.ID, p.Code),
 			Name:        p.Name,
 			Description: p.Description,
-			Price:       p.Price,
+			Price:       p.Price.Money,
+			Currency:    models.BaseCurrency,
 			Stock:       p.Stock,
 			Category:    p.Category,
+			ModifiedBy:  models.ProductSourceOneC,
+			ModifiedAt:  &now,
+			SyncedAt:    &now,
 		}
-
-		if err := tx.Where("external_id = ?", p.ID).
-			Assign(product).
-			FirstOrCreate(&product).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("upserting product: %w", err)
+		if err := tx.Create(&product).Error; err != nil {
+			return fmt.Errorf("creating product %s: %w", p.ID, err)
+		}
+		if err := saveSyncValues(tx, mergeProduct(&product, p, nil, s.ownership).values); err != nil {
+			return err
 		}
+		run.Created++
+		return nil
 	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("committing transaction: %w", err)
+	if err != nil {
+		return fmt.Errorf("loading product %s: %w", p.ID, err)
 	}
 
-	// Update last sync time
-	if err := s.redis.Set(ctx, "last_product_sync", time.Now(), 0).Err(); err != nil {
-		return fmt.Errorf("updating last sync time: %w", err)
+	if p.Deleted {
+		if err := tx.Delete(&product).Error; err != nil {
+			return fmt.Errorf("deleting product %s: %w", p.ID, err)
+		}
+		run.Updated++
+		return nil
 	}
 
+	synced, err := loadSyncValues(tx, product.ID)
+	if err != nil {
+		return err
+	}
+	changed, err := applyMerge(tx, &product, mergeProduct(&product, p, synced, s.ownership), now)
+	if err != nil {
+		return err
+	}
+	if changed {
+		run.Updated++
+	}
 	return nil
 }
 
-// SyncOrders synchronizes orders with 1C
-func (s *Service) SyncOrders(ctx context.Context) error {
-	var orders []models.Order
-	if err := s.db.Preload("Items").
-		Where("synced = ?", false).
-		Find(&orders).Error; err != nil {
-		return fmt.Errorf("fetching unsynced orders: %w", err)
+// SyncOrders sends 1C the orders it does not have the current state of, or
+// every order when the run is forced
+func (s *Service) SyncOrders(ctx context.Context, trigger models.SyncTrigger) (*models.SyncRun, error) {
+	return s.run(models.SyncTypeOrders, trigger, func(run *models.SyncRun) error {
+		return s.syncOrders(ctx, run)
+	})
+}
+
+func (s *Service) syncOrders(ctx context.Context, run *models.SyncRun) error {
+	orders, err := s.pendingOrders(run.Trigger == models.SyncTriggerForced)
+	if err != nil {
+		return err
 	}
+	run.Fetched = len(orders)
 
 	if len(orders) == 0 {
 		return nil
 	}
 
-	// Convert to 1C format
+	// Send orders to 1C
+	if err := s.onecClient.SyncOrders(ctx, toOneCOrders(orders)); err != nil {
+		run.Failed = len(orders)
+		return fmt.Errorf("sending orders to 1C: %w", err)
+	}
+
+	// Mark orders as synced, unless they changed while 1C was being called
+	updated := 0
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, order := range orders {
+			result := tx.Model(&models.Order{}).
+				Where("id = ? AND updated_at = ?", order.ID, order.UpdatedAt).
+				UpdateColumn("synced", true)
+			if result.Error != nil {
+				return result.Error
+			}
+			updated += int(result.RowsAffected)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("marking orders as synced: %w", err)
+	}
+	run.Updated = updated
+
+	return nil
+}
+
+// pendingOrders loads the orders 1C does not have the current state of, or
+// all of them when all is set
+func (s *Service) pendingOrders(all bool) ([]models.Order, error) {
+	query := s.db.Preload("Items").Order("id")
+	if !all {
+		query = query.Where("synced = ?", false)
+	}
+
+	var orders []models.Order
+	if err := query.Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("fetching orders to sync: %w", err)
+	}
+	return orders, nil
+}
+
+// toOneCOrders converts orders to 1C format
+func toOneCOrders(orders []models.Order) []onec.Order {
 	onecOrders := make([]onec.Order, len(orders))
 	for i, order := range orders {
 		items := make([]onec.Item, len(order.Items))
@@ -116,46 +259,125 @@ func (s *Service) SyncOrders(ctx context.Context) error {
 			items[j] = onec.Item{
 				ProductID: item.ProductExternalID,
 				Quantity:  item.Quantity,
-				Price:     item.Price,
+				Price:     onec.Amount{Money: item.Price},
+				VATRate:   item.TaxPercent,
+				Net:       onec.Amount{Money: item.Net},
+				VAT:       onec.Amount{Money: item.Tax},
+				Amount:    onec.Amount{Money: item.Gross},
 			}
 		}
 
 		onecOrders[i] = onec.Order{
-			ID:         order.ExternalID,
+			ID:         onec.OrderID(order),
 			Number:     order.Number,
 			Date:       order.CreatedAt,
-			CustomerID: order.UserExternalID,
-			Status:     order.Status,
-			Items:     items,
-			Total:     order.Total,
+			CustomerID: onec.CustomerID(order),
+			Status:     string(order.Status),
+			Currency:   string(order.Currency),
+			Items:      items,
+			Total:      onec.Amount{Money: order.Total},
+			NetTotal:   onec.Amount{Money: order.NetTotal},
+			VATTotal:   onec.Amount{Money: order.TaxTotal},
 		}
 	}
+	return onecOrders
+}
 
-	// Send orders to 1C
-	if err := s.onecClient.SyncOrders(ctx, onecOrders); err != nil {
-		return fmt.Errorf("sending orders to 1C: %w", err)
+// SyncReturns sends completed returns with their refunds to 1C
+func (s *Service) SyncReturns(ctx context.Context, trigger models.SyncTrigger) (*models.SyncRun, error) {
+	return s.run(models.SyncTypeReturns, trigger, func(run *models.SyncRun) error {
+		return s.syncReturns(ctx, run)
+	})
+}
+
+func (s *Service) syncReturns(ctx context.Context, run *models.SyncRun) error {
+	var returns []models.Return
+	if err := s.db.Preload("Items.OrderItem").Preload("Refund").
+		Where("status = ? AND synced = ?", models.ReturnCompleted, false).
+		Find(&returns).Error; err != nil {
+		return fmt.Errorf("fetching unsynced returns: %w", err)
+	}
+	run.Fetched = len(returns)
+
+	if len(returns) == 0 {
+		return nil
 	}
 
-	// Mark orders as synced
-	if err := s.db.Model(&models.Order{}).
-		Where("id IN ?", getOrderIDs(orders)).
+	onecReturns := make([]onec.Return, len(returns))
+	ids := make([]uint, len(returns))
+	for i, ret := range returns {
+		var order models.Order
+		if err := s.db.Select("id", "number", "external_id", "currency").First(&order, ret.OrderID).Error; err != nil {
+			return fmt.Errorf("fetching order of return %d: %w", ret.ID, err)
+		}
+
+		items := make([]onec.ReturnItem, len(ret.Items))
+		for j, item := range ret.Items {
+			items[j] = onec.ReturnItem{
+				ProductID: item.OrderItem.ProductExternalID,
+				Quantity:  item.Quantity,
+				Amount:    onec.Amount{Money: item.Amount},
+			}
+		}
+
+		var refund models.Money
+		if ret.Refund != nil {
+			refund = ret.Refund.Amount
+		}
+		onecReturns[i] = onec.Return{
+			ID:       fmt.Sprintf("%d", ret.ID),
+			OrderID:  onec.OrderID(order),
+			Reason:   ret.Reason,
+			Restock:  ret.Restocked,
+			Items:    items,
+			Refund:   onec.Amount{Money: refund},
+			Currency: string(order.Currency),
+		}
+		if ret.ResolvedAt != nil {
+			onecReturns[i].Date = *ret.ResolvedAt
+		}
+		ids[i] = ret.ID
+	}
+
+	if err := s.onecClient.SyncReturns(ctx, onecReturns); err != nil {
+		run.Failed = len(returns)
+		return fmt.Errorf("sending returns to 1C: %w", err)
+	}
+
+	if err := s.db.Model(&models.Return{}).
+		Where("id IN ?", ids).
 		Update("synced", true).Error; err != nil {
-		return fmt.Errorf("marking orders as synced: %w", err)
+		return fmt.Errorf("marking returns as synced: %w", err)
 	}
+	run.Updated = len(returns)
 
 	return nil
 }
 
 // HandleOrderStatusUpdate processes order status updates from 1C
 func (s *Service) HandleOrderStatusUpdate(ctx context.Context, orderID, status string) error {
-	// Update order status in database
-	if err := s.db.Model(&models.Order{}).
-		Where("external_id = ?", orderID).
-		Update("status", status).Error; err != nil {
+	// Update order status and its audit trail in one transaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 1C knows orders it did not create by their number
+		var order models.Order
+		if err := tx.Where("external_id = ? OR (external_id = '' AND number = ?)", orderID, orderID).
+			First(&order).Error; err != nil {
+			return err
+		}
+		// 1C resends statuses it already reported
+		if order.Status == models.OrderStatus(status) {
+			return nil
+		}
+		return s.machine.Fire(tx, &order, models.OrderStatus(status), models.OneCActor, "status update from 1C")
+	})
+	if err != nil {
 		return fmt.Errorf("updating order status: %w", err)
 	}
 
-	// Publish event
+	// Publish event, if there is a queue to publish it to
+	if s.rabbitmq == nil {
+		return nil
+	}
 	event := map[string]interface{}{
 		"type":     "order_status_updated",
 		"orderID":  orderID,
@@ -169,10 +391,10 @@ func (s *Service) HandleOrderStatusUpdate(ctx context.Context, orderID, status s
 	}
 
 	if err := s.rabbitmq.Publish(
-		"",             // exchange
-		s.updateQueue,  // routing key
-		false,          // mandatory
-		false,          // immediate
+		"",            // exchange
+		s.updateQueue, // routing key
+		false,         // mandatory
+		false,         // immediate
 		amqp.Publishing{
 			ContentType: "application/json",
 			Body:        body,
@@ -194,20 +416,15 @@ func (s *Service) StartSyncWorker(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := s.SyncProducts(ctx); err != nil {
+			if _, err := s.SyncProducts(ctx, models.SyncTriggerWorker); err != nil {
 				log.Printf("Error syncing products: %v", err)
 			}
-			if err := s.SyncOrders(ctx); err != nil {
+			if _, err := s.SyncOrders(ctx, models.SyncTriggerWorker); err != nil {
 				log.Printf("Error syncing orders: %v", err)
 			}
+			if _, err := s.SyncReturns(ctx, models.SyncTriggerWorker); err != nil {
+				log.Printf("Error syncing returns: %v", err)
+			}
 		}
 	}
 }
-
-func getOrderIDs(orders []models.Order) []uint {
-	ids := make([]uint, len(orders))
-	for i, order := range orders {
-		ids[i] = order.ID
-	}
-	return ids
-} 
\ No newline at end of file
@@ -0,0 +1,197 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stockCursor names the cursor of SyncStock
+const stockCursor = "stock"
+
+// DefaultStockSyncInterval is how often StartStockWorker pulls stock changes
+const DefaultStockSyncInterval = time.Minute
+
+// SyncStock applies the stock changes 1C made since the last run; the run's
+// Updated counts the products that changed. Changes are deltas added to the
+// quantity on hand of the product with that 1C ID, or else that SKU;
+// reserved quantities are left alone, so pending orders keep their units.
+// 1C is asked outside any transaction. The cursor is then locked and moved
+// in the transaction applying the deltas, which are dropped if another run
+// moved it meanwhile, so a failed run applies nothing and concurrent
+// instances cannot apply the same deltas.
+func (s *Service) SyncStock(ctx context.Context, trigger models.SyncTrigger) (*models.SyncRun, error) {
+	return s.run(models.SyncTypeStock, trigger, func(run *models.SyncRun) error {
+		return s.syncStock(ctx, run)
+	})
+}
+
+func (s *Service) syncStock(ctx context.Context, run *models.SyncRun) error {
+	since, err := readCursor(s.db, stockCursor)
+	if err != nil {
+		return err
+	}
+
+	updates, err := s.onecClient.GetStockUpdates(ctx, since)
+	if err != nil {
+		return fmt.Errorf("fetching stock updates: %w", err)
+	}
+	run.Fetched = len(updates)
+
+	// 1C's answer carries no position of its own, so the cursor moves to when
+	// it arrived, rounded up to the whole seconds 1C is asked in. A change
+	// made meanwhile waits for the next full product sync, which beats
+	// counting it twice and overselling.
+	answeredAt := time.Now().Truncate(time.Second).Add(time.Second)
+
+	// Products are updated in a fixed order so concurrent orders locking
+	// several of them cannot deadlock with the sync
+	keys := make([]string, 0, len(updates))
+	for key := range updates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		locked, err := lockCursor(tx, stockCursor)
+		if err != nil {
+			return err
+		}
+		if !locked.Equal(since) {
+			log.Printf("Stock cursor moved from %s to %s by another run; dropping %d updates", since, locked, len(updates))
+			return nil
+		}
+
+		for _, key := range keys {
+			delta := updates[key]
+			if delta == 0 {
+				continue
+			}
+			n, err := applyStockDelta(tx, key, delta)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				log.Printf("Stock update from 1C for unknown product %s", key)
+			}
+			run.Updated += n
+		}
+
+		return moveCursor(tx, stockCursor, answeredAt)
+	})
+	if err != nil {
+		// Nothing was applied
+		run.Updated = 0
+	}
+	return err
+}
+
+// StartStockWorker runs SyncStock every interval until ctx is done
+func (s *Service) StartStockWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run, err := s.SyncStock(ctx, models.SyncTriggerWorker)
+			if err != nil {
+				log.Printf("Error syncing stock: %v", err)
+				continue
+			}
+			if run.Updated > 0 {
+				log.Printf("Stock of %d products updated from 1C", run.Updated)
+			}
+		}
+	}
+}
+
+// applyStockDelta adds delta to the stock of the product known to 1C by
+// key. Stock never drops below what pending orders reserved; a shortfall
+// beyond that is logged for staff to sort out. The stock 1C last sent moves
+// with the delta, so the next product sync does not take it for a change
+// made in the shop.
+func applyStockDelta(tx *gorm.DB, key string, delta int) (int, error) {
+	for _, column := range []string{"external_id", "sku"} {
+		var products []models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(column+" = ?", key).Order("id").Find(&products).Error; err != nil {
+			return 0, fmt.Errorf("loading product %s: %w", key, err)
+		}
+		if len(products) == 0 {
+			continue
+		}
+
+		for _, product := range products {
+			stock := product.Stock + delta
+			if stock < product.Reserved {
+				log.Printf("Stock update from 1C leaves product %s %d short of its reservations", key, product.Reserved-stock)
+				stock = product.Reserved
+			}
+			if err := tx.Model(&product).Updates(map[string]interface{}{
+				"stock":       stock,
+				"modified_by": models.ProductSourceOneC,
+				"modified_at": time.Now(),
+			}).Error; err != nil {
+				return 0, fmt.Errorf("updating stock of %s: %w", key, err)
+			}
+
+			if err := tx.Exec(`
+				UPDATE product_sync_values SET value = GREATEST(value::int + ?, 0)::text
+				WHERE field = 'stock' AND product_id = ?
+			`, delta, product.ID).Error; err != nil {
+				return 0, fmt.Errorf("updating synced stock of %s: %w", key, err)
+			}
+		}
+		return len(products), nil
+	}
+	return 0, nil
+}
+
+// readCursor returns the position of the named cursor without locking it. A
+// new cursor starts now, since what happened before is already in the full
+// product sync.
+func readCursor(db *gorm.DB, name string) (time.Time, error) {
+	if err := db.Exec(`
+		INSERT INTO sync_cursors (name, since) VALUES (?, NOW())
+		ON CONFLICT (name) DO NOTHING
+	`, name).Error; err != nil {
+		return time.Time{}, fmt.Errorf("creating %s cursor: %w", name, err)
+	}
+
+	var since time.Time
+	if err := db.Raw(`SELECT since FROM sync_cursors WHERE name = ?`, name).
+		Scan(&since).Error; err != nil {
+		return time.Time{}, fmt.Errorf("reading %s cursor: %w", name, err)
+	}
+	return since, nil
+}
+
+// lockCursor returns the position of the named cursor and locks it until tx
+// ends
+func lockCursor(tx *gorm.DB, name string) (time.Time, error) {
+	var since time.Time
+	if err := tx.Raw(`SELECT since FROM sync_cursors WHERE name = ? FOR UPDATE`, name).
+		Scan(&since).Error; err != nil {
+		return time.Time{}, fmt.Errorf("locking %s cursor: %w", name, err)
+	}
+	return since, nil
+}
+
+// moveCursor sets the position of the named cursor
+func moveCursor(tx *gorm.DB, name string, since time.Time) error {
+	if err := tx.Exec(`UPDATE sync_cursors SET since = ?, updated_at = NOW() WHERE name = ?`, since, name).
+		Error; err != nil {
+		return fmt.Errorf("moving %s cursor: %w", name, err)
+	}
+	return nil
+}
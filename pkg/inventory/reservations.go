@@ -0,0 +1,199 @@
+// Package inventory manages stock reservations. Placing an order reserves
+// stock instead of deducting it; paying converts the reservation into a
+// deduction and cancelling or expiring releases it. All stock changes are
+// conditional single-statement updates, so concurrent orders cannot oversell.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultTTL is how long a pending order holds its stock
+const DefaultTTL = 30 * time.Minute
+
+// ErrInsufficientStock is matched by InsufficientStockError
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// InsufficientStockError reports the product that could not be reserved
+type InsufficientStockError struct {
+	ProductID uint
+	Available int
+	Requested int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %d: %d available, %d requested", e.ProductID, e.Available, e.Requested)
+}
+
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// Reserve holds stock for every item of an order until now+ttl. It must run
+// in the transaction that creates the order; on error nothing is reserved
+// once the caller rolls back.
+func Reserve(tx *gorm.DB, orderID uint, items []models.OrderItem, ttl time.Duration) error {
+	// Lock products in a stable order so concurrent checkouts cannot deadlock
+	sorted := append([]models.OrderItem(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ProductID < sorted[j].ProductID })
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	for _, item := range sorted {
+		result := tx.Model(&models.Product{}).
+			Where("id = ? AND stock - reserved >= ?", item.ProductID, item.Quantity).
+			Update("reserved", gorm.Expr("reserved + ?", item.Quantity))
+		if result.Error != nil {
+			return fmt.Errorf("reserving stock: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			var product models.Product
+			if err := tx.Select("stock", "reserved").First(&product, item.ProductID).Error; err != nil {
+				return fmt.Errorf("reading stock: %w", err)
+			}
+			return &InsufficientStockError{
+				ProductID: item.ProductID,
+				Available: product.Stock - product.Reserved,
+				Requested: item.Quantity,
+			}
+		}
+
+		if err := tx.Create(&models.StockReservation{
+			OrderID:   orderID,
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Status:    models.ReservationActive,
+			ExpiresAt: expiresAt,
+		}).Error; err != nil {
+			return fmt.Errorf("recording reservation: %w", err)
+		}
+	}
+	return nil
+}
+
+// Commit converts the order's active reservations into stock deductions.
+// Orders placed before reservations existed have none and already had their
+// stock deducted, so there is nothing to do for them.
+func Commit(tx *gorm.DB, orderID uint) error {
+	reservations, err := lockReservations(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reservations {
+		if r.Status != models.ReservationActive {
+			continue
+		}
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", r.ProductID).
+			Updates(map[string]interface{}{
+				"stock":    gorm.Expr("stock - ?", r.Quantity),
+				"reserved": gorm.Expr("reserved - ?", r.Quantity),
+			}).Error; err != nil {
+			return fmt.Errorf("committing reservation: %w", err)
+		}
+	}
+	return setStatus(tx, orderID, models.ReservationCommitted, models.ReservationActive)
+}
+
+// Release gives back the stock held or deducted for an order: active
+// reservations stop counting as reserved and committed ones are returned to
+// stock. Orders placed before reservations existed get their item quantities
+// returned to stock instead.
+func Release(tx *gorm.DB, orderID uint) error {
+	reservations, err := lockReservations(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return restoreItems(tx, orderID)
+	}
+
+	for _, r := range reservations {
+		var column string
+		var expr clause.Expr
+		switch r.Status {
+		case models.ReservationActive:
+			column, expr = "reserved", gorm.Expr("reserved - ?", r.Quantity)
+		case models.ReservationCommitted:
+			column, expr = "stock", gorm.Expr("stock + ?", r.Quantity)
+		default:
+			continue
+		}
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", r.ProductID).
+			Update(column, expr).Error; err != nil {
+			return fmt.Errorf("releasing reservation: %w", err)
+		}
+	}
+	return setStatus(tx, orderID, models.ReservationReleased, models.ReservationActive, models.ReservationCommitted)
+}
+
+// Restock puts returned goods back into stock
+func Restock(tx *gorm.DB, productID uint, quantity int) error {
+	if err := tx.Model(&models.Product{}).
+		Where("id = ?", productID).
+		Update("stock", gorm.Expr("stock + ?", quantity)).Error; err != nil {
+		return fmt.Errorf("restocking product: %w", err)
+	}
+	return nil
+}
+
+// ExpiredOrderIDs returns up to limit orders holding reservations that
+// expired before now
+func ExpiredOrderIDs(db *gorm.DB, now time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&models.StockReservation{}).
+		Distinct("order_id").
+		Where("status = ? AND expires_at < ?", models.ReservationActive, now).
+		Limit(limit).
+		Pluck("order_id", &ids).Error
+	return ids, err
+}
+
+// lockReservations loads the order's reservations, locking them so a
+// concurrent commit and release of the same order serialise
+func lockReservations(tx *gorm.DB, orderID uint) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ?", orderID).
+		Order("product_id").
+		Find(&reservations).Error
+	if err != nil {
+		return nil, fmt.Errorf("loading reservations: %w", err)
+	}
+	return reservations, nil
+}
+
+func setStatus(tx *gorm.DB, orderID uint, to models.ReservationStatus, from ...models.ReservationStatus) error {
+	if err := tx.Model(&models.StockReservation{}).
+		Where("order_id = ? AND status IN ?", orderID, from).
+		Update("status", to).Error; err != nil {
+		return fmt.Errorf("updating reservations: %w", err)
+	}
+	return nil
+}
+
+// restoreItems returns the item quantities of a legacy order to stock
+func restoreItems(tx *gorm.DB, orderID uint) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", orderID).Order("product_id").Find(&items).Error; err != nil {
+		return fmt.Errorf("loading order items: %w", err)
+	}
+	for _, item := range items {
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", item.ProductID).
+			Update("stock", gorm.Expr("stock + ?", item.Quantity)).
+			Error; err != nil {
+			return fmt.Errorf("restoring stock: %w", err)
+		}
+	}
+	return nil
+}
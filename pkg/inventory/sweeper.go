@@ -0,0 +1,70 @@
+package inventory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sweepBatch bounds how many orders a single sweep expires
+const sweepBatch = 100
+
+// ExpireFunc cancels an order whose reservations expired. It runs in its own
+// transaction and must release the order's reservations.
+type ExpireFunc func(tx *gorm.DB, orderID uint) error
+
+// Sweeper periodically expires orders whose reservations ran out
+type Sweeper struct {
+	db       *gorm.DB
+	interval time.Duration
+	expire   ExpireFunc
+}
+
+// NewSweeper creates a sweeper that checks for expired reservations every
+// interval
+func NewSweeper(db *gorm.DB, interval time.Duration, expire ExpireFunc) *Sweeper {
+	return &Sweeper{db: db, interval: interval, expire: expire}
+}
+
+// Run sweeps until the context is cancelled
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.Sweep(ctx); err != nil {
+				log.Printf("Stock reservation sweep failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Expired stock reservations of %d orders", n)
+			}
+		}
+	}
+}
+
+// Sweep expires one batch of orders and returns how many were expired. A
+// failure on one order is logged and does not stop the others.
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	ids, err := ExpiredOrderIDs(s.db.WithContext(ctx), time.Now().UTC(), sweepBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return s.expire(tx, id)
+		})
+		if err != nil {
+			log.Printf("Failed to expire order %d: %v", id, err)
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
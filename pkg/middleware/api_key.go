@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader carries the key of machine clients such as 1C
+const APIKeyHeader = "X-API-Key"
+
+// APIKey allows the request through only if it carries key in the
+// X-API-Key header. It guards endpoints called by other systems rather than
+// users.
+func APIKey(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given := c.GetHeader(APIKeyHeader)
+		if key == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+		c.Next()
+	}
+}
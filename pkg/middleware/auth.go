@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
@@ -17,8 +18,8 @@ var (
 )
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
+	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
 	jwt.RegisteredClaims
 }
 
@@ -41,7 +42,7 @@ func AuthMiddleware(secretKey string) gin.HandlerFunc {
 		// Parse and validate token
 		token, err := jwt.ParseWithClaims(parts[1], &Claims{}, func(token *jwt.Token) (interface{}, error) {
 			return []byte(secretKey), nil
-		})
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 		if err != nil || !token.Valid {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidToken.Error()})
@@ -58,16 +59,42 @@ func AuthMiddleware(secretKey string) gin.HandlerFunc {
 	}
 }
 
-func GenerateToken(userID uint, role string, secretKey string) (string, error) {
+// OptionalAuthMiddleware authenticates the request when it carries an
+// Authorization header and lets anonymous requests through. A header with an
+// invalid token is still rejected so clients know to refresh it.
+func OptionalAuthMiddleware(secretKey string) gin.HandlerFunc {
+	authRequired := AuthMiddleware(secretKey)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authRequired(c)
+	}
+}
+
+func GenerateToken(userID uuid.UUID, role string, secretKey string, ttl time.Duration) (string, error) {
+	now := time.Now()
 	claims := Claims{
 		UserID: userID,
 		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(secretKey))
-} 
\ No newline at end of file
+}
+
+// CurrentUserID returns the authenticated user's ID set by AuthMiddleware
+func CurrentUserID(c *gin.Context) (uuid.UUID, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	userID, ok := value.(uuid.UUID)
+	return userID, ok
+}
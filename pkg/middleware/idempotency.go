@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client's key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength matches the idempotency_keys.key column
+const maxIdempotencyKeyLength = 255
+
+var (
+	ErrIdempotencyKeyTooLong  = errors.New("idempotency key is too long")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is still being processed")
+)
+
+// Idempotency makes POST and PUT requests carrying an Idempotency-Key header
+// safe to retry. The first request with a key runs normally and its response
+// is stored for ttl; retries with the same key and body get the stored
+// response back without running the handler again, and reusing the key for a
+// different request is rejected with 409. Server errors are not stored, so
+// the request can be retried with the same key. It must run after
+// AuthMiddleware on authenticated routes, since keys are scoped per user.
+func Idempotency(db *gorm.DB, ttl time.Duration) gin.HandlerFunc {
+	go cleanupIdempotencyKeys(db, ttl)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || (c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPut) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrIdempotencyKeyTooLong.Error()})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := models.IdempotencyKey{
+			Scope:       idempotencyScope(c),
+			Key:         key,
+			Fingerprint: requestFingerprint(c.Request, body),
+			Status:      models.IdempotencyProcessing,
+			ExpiresAt:   time.Now().UTC().Add(ttl),
+		}
+		existing, err := claimIdempotencyKey(db, &record)
+		if errors.Is(err, ErrIdempotencyKeyInFlight) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check idempotency key"})
+			return
+		}
+		if existing != nil {
+			replayIdempotentResponse(c, existing, record.Fingerprint)
+			return
+		}
+
+		// Give the key up again if the handler does not produce a storable
+		// response, including when it panics
+		completed := false
+		defer func() {
+			if !completed {
+				db.Delete(&models.IdempotencyKey{}, record.ID)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		err = db.Model(&record).Updates(map[string]interface{}{
+			"status":        models.IdempotencyCompleted,
+			"response_code": status,
+			"response_body": recorder.body.Bytes(),
+			"content_type":  c.Writer.Header().Get("Content-Type"),
+		}).Error
+		if err != nil {
+			log.Printf("Failed to store response for idempotency key %q: %v", key, err)
+			return
+		}
+		completed = true
+	}
+}
+
+// claimIdempotencyKey inserts the record as processing. When the key is
+// already taken by an unexpired request that request's record is returned
+// instead.
+func claimIdempotencyKey(db *gorm.DB, record *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		var existing models.IdempotencyKey
+		err := db.Where("scope = ? AND key = ?", record.Scope, record.Key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if existing.ExpiresAt.After(time.Now()) {
+			return &existing, nil
+		}
+
+		// The stored response is past its window; drop it and claim again
+		if err := db.Where("id = ? AND expires_at <= ?", existing.ID, time.Now().UTC()).
+			Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return nil, err
+		}
+		record.ID = 0
+	}
+	return nil, ErrIdempotencyKeyInFlight
+}
+
+// replayIdempotentResponse answers a retry from the stored record
+func replayIdempotentResponse(c *gin.Context, existing *models.IdempotencyKey, fingerprint string) {
+	switch {
+	case existing.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrIdempotencyKeyReused.Error()})
+	case existing.Status != models.IdempotencyCompleted:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrIdempotencyKeyInFlight.Error()})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		if len(existing.ResponseBody) == 0 {
+			c.AbortWithStatus(existing.ResponseCode)
+			return
+		}
+		c.Data(existing.ResponseCode, existing.ContentType, existing.ResponseBody)
+		c.Abort()
+	}
+}
+
+// idempotencyScope keeps keys of different users apart. Public routes share
+// one anonymous scope.
+func idempotencyScope(c *gin.Context) string {
+	if userID, ok := CurrentUserID(c); ok {
+		return userID.String()
+	}
+	return "anonymous"
+}
+
+// requestFingerprint hashes what makes two requests the same: the method,
+// the path with its query and the body
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.RequestURI())
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cleanupIdempotencyKeys periodically deletes keys past their window
+func cleanupIdempotencyKeys(db *gorm.DB, ttl time.Duration) {
+	interval := ttl
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		if err := db.Where("expires_at < ?", time.Now().UTC()).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			log.Printf("Failed to delete expired idempotency keys: %v", err)
+		}
+	}
+}
+
+// responseRecorder copies the response body while it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -11,6 +11,7 @@ import (
 
 type RateLimiter struct {
 	ips    map[string]*rate.Limiter
+	seen   map[string]time.Time
 	mu     *sync.RWMutex
 	rate   rate.Limit
 	burst  int
@@ -21,6 +22,7 @@ type RateLimiter struct {
 func NewRateLimiter(r rate.Limit, b int, ttl, cleanup time.Duration) *RateLimiter {
 	limiter := &RateLimiter{
 		ips:     make(map[string]*rate.Limiter),
+		seen:    make(map[string]time.Time),
 		mu:      &sync.RWMutex{},
 		rate:    r,
 		burst:   b,
@@ -36,9 +38,10 @@ func (rl *RateLimiter) cleanupTask() {
 	ticker := time.NewTicker(rl.cleanup)
 	for range ticker.C {
 		rl.mu.Lock()
-		for ip, limiter := range rl.ips {
-			if time.Since(limiter.LastTime()) > rl.ttl {
+		for ip := range rl.ips {
+			if time.Since(rl.seen[ip]) > rl.ttl {
 				delete(rl.ips, ip)
+				delete(rl.seen, ip)
 			}
 		}
 		rl.mu.Unlock()
@@ -54,6 +57,7 @@ func (rl *RateLimiter) getLimiter(ip string) *rate.Limiter {
 		limiter = rate.NewLimiter(rl.rate, rl.burst)
 		rl.ips[ip] = limiter
 	}
+	rl.seen[ip] = time.Now()
 
 	return limiter
 }
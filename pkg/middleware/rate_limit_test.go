@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterEvictsIdleLimiters(t *testing.T) {
+	rl := NewRateLimiter(rate.Limit(1), 1, 20*time.Millisecond, 5*time.Millisecond)
+	rl.getLimiter("10.0.0.1")
+
+	assert.Eventually(t, func() bool {
+		rl.mu.RLock()
+		defer rl.mu.RUnlock()
+		return len(rl.ips) == 0 && len(rl.seen) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRateLimiterKeepsActiveLimiters(t *testing.T) {
+	rl := NewRateLimiter(rate.Limit(1), 1, 50*time.Millisecond, 5*time.Millisecond)
+	limiter := rl.getLimiter("10.0.0.1")
+
+	// A client that keeps coming back within the TTL keeps its limiter,
+	// however long ago it was created
+	for i := 0; i < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+		assert.Same(t, limiter, rl.getLimiter("10.0.0.1"))
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrForbidden = errors.New("insufficient permissions")
+
+// RequirePermission allows the request through only if the authenticated
+// user's role grants the permission. It must run after AuthMiddleware.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !CurrentRole(c).Can(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrForbidden.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentRole returns the authenticated user's role set by AuthMiddleware
+func CurrentRole(c *gin.Context) models.Role {
+	return models.Role(c.GetString("role"))
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cart is a server-side shopping cart. A cart belongs either to a user or,
+// before login, to an anonymous visitor holding its Token; the anonymous cart
+// is merged into the user's cart when they log in. Carts store quantities
+// only, prices are always read from the current products.
+type Cart struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_cart_user" json:"user_id,omitempty"`
+	Token     *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_cart_token" json:"token,omitempty"`
+	Items     []CartItem `gorm:"foreignKey:CartID" json:"items"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CartItem is a quantity of one product in a cart
+type CartItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CartID    uint      `gorm:"not null;uniqueIndex:idx_cart_item_product,priority:1" json:"cart_id"`
+	ProductID uint      `gorm:"not null;uniqueIndex:idx_cart_item_product,priority:2" json:"product_id"`
+	Product   Product   `gorm:"foreignKey:ProductID" json:"product"`
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CartItemInput is the payload for adding a product to a cart or changing
+// its quantity
+type CartItemInput struct {
+	ProductID uint `json:"product_id"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
+// TableName specifies the table name for the Cart model
+func (Cart) TableName() string {
+	return "carts"
+}
+
+// TableName specifies the table name for the CartItem model
+func (CartItem) TableName() string {
+	return "cart_items"
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+const (
+	CurrencyRUB Currency = "RUB"
+	CurrencyEUR Currency = "EUR"
+)
+
+// BaseCurrency is the currency exchange rates are quoted against. 1C
+// supplies prices in it.
+const BaseCurrency = CurrencyRUB
+
+var supportedCurrencies = map[Currency]bool{
+	CurrencyRUB: true,
+	CurrencyEUR: true,
+}
+
+// ErrNoExchangeRate is returned when converting to or from a currency that has
+// no exchange rate
+var ErrNoExchangeRate = errors.New("no exchange rate for currency")
+
+// ParseCurrency normalises and validates a currency code
+func ParseCurrency(s string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(s)))
+	if !c.IsValid() {
+		return "", fmt.Errorf("unsupported currency %q", s)
+	}
+	return c, nil
+}
+
+// IsValid reports whether the currency is supported
+func (c Currency) IsValid() bool {
+	return supportedCurrencies[c]
+}
+
+// rateScale is the number of decimal places kept for exchange rates
+const rateScale = 6
+
+// Rate is an exact exchange rate with six decimal places, mapped to
+// numeric(18,6) columns and serialised as a string in JSON
+type Rate struct {
+	scaled int64
+}
+
+// RateOne is the rate of the base currency against itself
+var RateOne = Rate{scaled: 1_000_000}
+
+// ParseRate parses a decimal string such as "98.7654"
+func ParseRate(s string) (Rate, error) {
+	scaled, err := parseDecimal(s, rateScale)
+	if err != nil {
+		return Rate{}, fmt.Errorf("invalid exchange rate %q", s)
+	}
+	return Rate{scaled: scaled}, nil
+}
+
+// MustParseRate is like ParseRate but panics on invalid input
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// IsPositive reports whether the rate is above zero
+func (r Rate) IsPositive() bool {
+	return r.scaled > 0
+}
+
+// String formats the rate with six decimal places
+func (r Rate) String() string {
+	s := strconv.FormatInt(r.scaled, 10)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= rateScale {
+		s = strings.Repeat("0", rateScale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-rateScale] + "." + s[len(s)-rateScale:]
+}
+
+// MarshalJSON encodes the rate as a decimal string
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts both "98.76" and 98.76
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	parsed, err := ParseRate(string(data))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Value implements driver.Valuer
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// Scan implements sql.Scanner for numeric columns
+func (r *Rate) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		*r = Rate{}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("cannot scan %T into Rate", value)
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Convert converts an amount between two currencies given their rates
+// against the base currency; to must be positive. The result is rounded half
+// away from zero to whole cents once, at the end.
+func (m Money) Convert(from, to Rate) Money {
+	if from == to {
+		return m
+	}
+	num := new(big.Int).Mul(big.NewInt(m.cents), big.NewInt(from.scaled))
+	den := big.NewInt(to.scaled)
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Round half away from zero: compare twice the remainder to the divisor
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return Money{cents: quo.Int64()}
+}
+
+// Rates maps currencies to their current rate against the base currency
+type Rates map[Currency]Rate
+
+// Rate returns the rate of the currency against the base currency
+func (r Rates) Rate(c Currency) (Rate, error) {
+	if c == BaseCurrency {
+		return RateOne, nil
+	}
+	rate, ok := r[c]
+	if !ok || !rate.IsPositive() {
+		return Rate{}, fmt.Errorf("%w %s", ErrNoExchangeRate, c)
+	}
+	return rate, nil
+}
+
+// Convert converts an amount from one currency to another
+func (r Rates) Convert(amount Money, from, to Currency) (Money, error) {
+	if from == to {
+		return amount, nil
+	}
+	fromRate, err := r.Rate(from)
+	if err != nil {
+		return Money{}, err
+	}
+	toRate, err := r.Rate(to)
+	if err != nil {
+		return Money{}, err
+	}
+	return amount.Convert(fromRate, toRate), nil
+}
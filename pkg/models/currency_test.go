@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoneyConvert(t *testing.T) {
+	rates := Rates{CurrencyEUR: MustParseRate("100.5")}
+
+	eur, err := rates.Convert(MustParseMoney("1005.00"), CurrencyRUB, CurrencyEUR)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.00", eur.String())
+
+	// 99.99 / 100.5 = 0.99492... rounds to 0.99
+	eur, err = rates.Convert(MustParseMoney("99.99"), CurrencyRUB, CurrencyEUR)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.99", eur.String())
+
+	rub, err := rates.Convert(MustParseMoney("0.99"), CurrencyEUR, CurrencyRUB)
+	assert.NoError(t, err)
+	assert.Equal(t, "99.50", rub.String())
+
+	_, err = Rates{}.Convert(MustParseMoney("1.00"), CurrencyRUB, CurrencyEUR)
+	assert.ErrorIs(t, err, ErrNoExchangeRate)
+}
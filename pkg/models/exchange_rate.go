@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ExchangeRate is the price of one unit of Currency in BaseCurrency from
+// EffectiveAt on. Rows are never updated, so the table is the rate history.
+type ExchangeRate struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Currency    Currency  `gorm:"type:varchar(3);not null;index:idx_exchange_rate_currency_effective,priority:1" json:"currency"`
+	Rate        Rate      `gorm:"type:numeric(18,6);not null" json:"rate"`
+	EffectiveAt time.Time `gorm:"not null;index:idx_exchange_rate_currency_effective,priority:2,sort:desc" json:"effective_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ExchangeRateInput is the payload for recording a new exchange rate
+type ExchangeRateInput struct {
+	Currency    Currency   `json:"currency" binding:"required"`
+	Rate        Rate       `json:"rate"`
+	EffectiveAt *time.Time `json:"effective_at"`
+}
+
+// TableName specifies the table name for the ExchangeRate model
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
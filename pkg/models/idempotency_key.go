@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// IdempotencyStatus tells whether the request behind a key has finished
+type IdempotencyStatus string
+
+const (
+	IdempotencyProcessing IdempotencyStatus = "processing"
+	IdempotencyCompleted  IdempotencyStatus = "completed"
+)
+
+// IdempotencyKey stores the response to a request sent with an
+// Idempotency-Key header. Keys are unique per Scope, the authenticated user
+// or "anonymous", and Fingerprint identifies the request they were first used
+// with.
+type IdempotencyKey struct {
+	ID           uint              `gorm:"primaryKey"`
+	Scope        string            `gorm:"size:64;not null;uniqueIndex:idx_idempotency_key_scope,priority:1"`
+	Key          string            `gorm:"size:255;not null;uniqueIndex:idx_idempotency_key_scope,priority:2"`
+	Fingerprint  string            `gorm:"type:char(64);not null"`
+	Status       IdempotencyStatus `gorm:"type:varchar(20);not null;default:'processing'"`
+	ResponseCode int
+	ResponseBody []byte
+	ContentType  string `gorm:"size:255"`
+	CreatedAt    time.Time
+	ExpiresAt    time.Time `gorm:"not null;index:idx_idempotency_key_expiry"`
+}
+
+// TableName specifies the table name for the IdempotencyKey model
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
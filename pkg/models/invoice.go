@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Invoice is the invoice issued for an order. Numbers are sequential without
+// gaps, and Document keeps the PDF as first rendered so every download of
+// the invoice is identical.
+type Invoice struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	OrderID   uint      `gorm:"not null;uniqueIndex:idx_invoice_order" json:"order_id"`
+	Number    int64     `gorm:"not null;uniqueIndex:idx_invoice_number" json:"number"`
+	Document  []byte    `gorm:"type:bytea;not null" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for the Invoice model
+func (Invoice) TableName() string {
+	return "invoices"
+}
@@ -0,0 +1,215 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount with two decimal places, stored as a whole number
+// of cents. It maps to the decimal(10,2) columns and is serialised as a
+// string in JSON ("12.34") so clients never see binary float artefacts.
+type Money struct {
+	cents int64
+}
+
+// MoneyFromCents returns the amount for a whole number of cents
+func MoneyFromCents(cents int64) Money {
+	return Money{cents: cents}
+}
+
+// ParseMoney parses a decimal string such as "12.34" or "-0.5". Digits beyond
+// the second decimal place are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	cents, err := parseDecimal(s, 2)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid money amount %q", s)
+	}
+	return Money{cents: cents}, nil
+}
+
+// MustParseMoney is like ParseMoney but panics on invalid input. It is meant
+// for constants and tests.
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// parseDecimal parses a decimal string into an integer scaled by 10^places,
+// rounding extra digits half away from zero
+func parseDecimal(s string, places int) (int64, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("invalid decimal")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	// Pad or cut the fraction to exactly places digits, remembering the first
+	// dropped digit for rounding
+	roundUp := len(frac) > places && frac[places] >= '5'
+	frac = (frac + strings.Repeat("0", places))[:places]
+
+	scaled, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if roundUp {
+		scaled++
+	}
+	if negative {
+		scaled = -scaled
+	}
+	return scaled, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Cents returns the amount as a whole number of cents
+func (m Money) Cents() int64 {
+	return m.cents
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) Money {
+	return Money{cents: m.cents + other.cents}
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) Money {
+	return Money{cents: m.cents - other.cents}
+}
+
+// Mul returns m multiplied by a whole quantity
+func (m Money) Mul(quantity int) Money {
+	return Money{cents: m.cents * int64(quantity)}
+}
+
+// Percent returns percent per cent of m, rounded half away from zero to
+// whole cents
+func (m Money) Percent(percent int) Money {
+	scaled := m.cents * int64(percent)
+	cents := scaled / 100
+	if rem := scaled % 100; rem >= 50 {
+		cents++
+	} else if rem <= -50 {
+		cents--
+	}
+	return Money{cents: cents}
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or
+// greater than other
+func (m Money) Cmp(other Money) int {
+	switch {
+	case m.cents < other.cents:
+		return -1
+	case m.cents > other.cents:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.cents == 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.cents < 0
+}
+
+// String formats the amount with exactly two decimal places
+func (m Money) String() string {
+	cents := m.cents
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON encodes the amount as a decimal string
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts both "12.34" and 12.34. Numbers are parsed from
+// their literal text, never through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan implements sql.Scanner for decimal columns
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = Money{cents: v * 100}
+		return nil
+	case float64:
+		return m.scanString(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+}
+
+func (m *Money) scanString(s string) error {
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
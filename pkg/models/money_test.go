@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoney(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		cases := map[string]int64{
+			"0":       0,
+			"12":      1200,
+			"12.3":    1230,
+			"12.34":   1234,
+			"-0.5":    -50,
+			".99":     99,
+			"1.005":   101,
+			"1.004":   100,
+			"-1.005":  -101,
+			"99999.9": 9999990,
+		}
+		for input, cents := range cases {
+			m, err := ParseMoney(input)
+			assert.NoError(t, err, input)
+			assert.Equal(t, cents, m.Cents(), input)
+		}
+
+		for _, input := range []string{"", "-", ".", "1.2.3", "1e3", "abc", "12,34"} {
+			_, err := ParseMoney(input)
+			assert.Error(t, err, input)
+		}
+	})
+
+	t.Run("Arithmetic does not drift", func(t *testing.T) {
+		var total Money
+		for i := 0; i < 1000; i++ {
+			total = total.Add(MustParseMoney("0.10"))
+		}
+		assert.Equal(t, "100.00", total.String())
+		assert.Equal(t, "299.97", MustParseMoney("99.99").Mul(3).String())
+		assert.Equal(t, "-0.01", MustParseMoney("0.10").Sub(MustParseMoney("0.11")).String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(MustParseMoney("199.98"))
+		assert.NoError(t, err)
+		assert.Equal(t, `"199.98"`, string(data))
+
+		var fromString, fromNumber Money
+		assert.NoError(t, json.Unmarshal([]byte(`"0.30"`), &fromString))
+		assert.NoError(t, json.Unmarshal([]byte(`0.3`), &fromNumber))
+		assert.Equal(t, fromString, fromNumber)
+		assert.Error(t, json.Unmarshal([]byte(`"ten"`), &fromString))
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		var m Money
+		assert.NoError(t, m.Scan([]byte("12.50")))
+		assert.Equal(t, int64(1250), m.Cents())
+		assert.NoError(t, m.Scan(int64(3)))
+		assert.Equal(t, int64(300), m.Cents())
+
+		value, err := MustParseMoney("-7.05").Value()
+		assert.NoError(t, err)
+		assert.Equal(t, "-7.05", value)
+	})
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -10,31 +11,101 @@ type OrderStatus string
 
 const (
 	OrderStatusPending   OrderStatus = "pending"
-	OrderStatusPaid     OrderStatus = "paid"
-	OrderStatusShipped  OrderStatus = "shipped"
+	OrderStatusPaid      OrderStatus = "paid"
+	OrderStatusShipped   OrderStatus = "shipped"
 	OrderStatusDelivered OrderStatus = "delivered"
 	OrderStatusCancelled OrderStatus = "cancelled"
+	// OrderStatusPartiallyRefunded and OrderStatusRefunded are delivered
+	// orders that had part or all of their total refunded through returns
+	OrderStatusPartiallyRefunded OrderStatus = "partially_refunded"
+	OrderStatusRefunded          OrderStatus = "refunded"
 )
 
+// Order is a customer order. Number is the human-readable order number
+// customers and 1C know the order by; ExternalID is 1C's own ID for it once
+// 1C assigned one, UserExternalID the customer's counterparty in 1C if
+// known, and Synced whether 1C has the current state of the order.
+// Currency and ExchangeRate are snapshotted at checkout, ExchangeRate being
+// the price of one unit of Currency in BaseCurrency, so that Total can always
+// be reproduced: Subtotal is the sum of the items and Total adds the
+// Adjustments to it. Total includes VAT, which is split out per item at the
+// rates of TaxRegion into NetTotal and TaxTotal. Shipments are the parcels
+// the items were sent in. CouponCode is only read when the order is placed.
 type Order struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	UserID    uint           `gorm:"not null;index:idx_order_user" json:"user_id"`
-	User      User          `gorm:"foreignKey:UserID" json:"user"`
-	Status    OrderStatus    `gorm:"type:varchar(20);not null;default:'pending';index:idx_order_status" json:"status"`
-	Total     float64        `gorm:"not null;type:decimal(10,2)" json:"total"`
-	Items     []OrderItem    `gorm:"foreignKey:OrderID" json:"items"`
-	CreatedAt time.Time      `gorm:"index:idx_order_created" json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint              `gorm:"primaryKey" json:"id"`
+	Number         string            `gorm:"size:50;not null;default:''" json:"number"`
+	ExternalID     string            `gorm:"size:100;not null;default:''" json:"external_id,omitempty"`
+	UserID         uuid.UUID         `gorm:"type:uuid;not null;index:idx_order_user" json:"user_id"`
+	UserExternalID string            `gorm:"size:100;not null;default:''" json:"-"`
+	User           User              `gorm:"foreignKey:UserID" json:"user"`
+	Status         OrderStatus       `gorm:"type:varchar(20);not null;default:'pending';index:idx_order_status" json:"status"`
+	Subtotal       Money             `gorm:"not null;type:decimal(10,2)" json:"subtotal"`
+	Total          Money             `gorm:"not null;type:decimal(10,2)" json:"total"`
+	NetTotal       Money             `gorm:"not null;type:decimal(10,2)" json:"net_total"`
+	TaxTotal       Money             `gorm:"not null;type:decimal(10,2);default:0" json:"tax_total"`
+	TaxRegion      string            `gorm:"size:10;not null;default:''" json:"tax_region"`
+	Currency       Currency          `gorm:"type:varchar(3);not null;default:'RUB'" json:"currency"`
+	ExchangeRate   Rate              `gorm:"type:numeric(18,6);not null;default:1" json:"exchange_rate"`
+	Items          []OrderItem       `gorm:"foreignKey:OrderID" json:"items"`
+	Adjustments    []OrderAdjustment `gorm:"foreignKey:OrderID" json:"adjustments"`
+	Shipments      []Shipment        `gorm:"foreignKey:OrderID" json:"shipments,omitempty"`
+	CouponCode     string            `gorm:"-" json:"coupon_code,omitempty"`
+	Synced         bool              `gorm:"not null;default:false;index:idx_order_synced" json:"-"`
+	CreatedAt      time.Time         `gorm:"index:idx_order_created" json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt    `gorm:"index" json:"-"`
 }
 
+// OrderItem is an order line. BasePrice is the unit price in BaseCurrency and
+// Price the same amount converted with the order's ExchangeRate. Gross is the
+// line total after discounts, split into Net and Tax at TaxPercent.
+// ProductExternalID is the product's 1C ID when the order was placed.
 type OrderItem struct {
-	ID        uint    `gorm:"primaryKey" json:"id"`
-	OrderID   uint    `gorm:"not null;index:idx_order_item_order" json:"order_id"`
-	ProductID uint    `gorm:"not null;index:idx_order_item_product" json:"product_id"`
-	Product   Product `gorm:"foreignKey:ProductID" json:"product"`
-	Quantity  int     `gorm:"not null" json:"quantity"`
-	Price     float64 `gorm:"not null;type:decimal(10,2)" json:"price"`
+	ID                uint     `gorm:"primaryKey" json:"id"`
+	OrderID           uint     `gorm:"not null;index:idx_order_item_order" json:"order_id"`
+	ProductID         uint     `gorm:"not null;index:idx_order_item_product" json:"product_id"`
+	Product           Product  `gorm:"foreignKey:ProductID" json:"product"`
+	ProductExternalID string   `gorm:"size:100;not null;default:''" json:"-"`
+	Quantity          int      `gorm:"not null" json:"quantity"`
+	Price             Money    `gorm:"not null;type:decimal(10,2)" json:"price"`
+	BasePrice         Money    `gorm:"not null;type:decimal(10,2)" json:"base_price"`
+	TaxClass          TaxClass `gorm:"size:50;not null;default:'standard'" json:"tax_class"`
+	TaxPercent        int      `gorm:"not null;default:0" json:"tax_percent"`
+	Net               Money    `gorm:"not null;type:decimal(10,2)" json:"net"`
+	Tax               Money    `gorm:"not null;type:decimal(10,2);default:0" json:"tax"`
+	Gross             Money    `gorm:"not null;type:decimal(10,2)" json:"gross"`
+}
+
+// OrderInput is the payload of a new order. Prices, totals and status are
+// always worked out by the shop; UserID is only honoured when staff place an
+// order on a customer's behalf. Shipments are booked through the shipment
+// endpoints only, so none can be sent with the order.
+type OrderInput struct {
+	UserID     uuid.UUID        `json:"user_id"`
+	Items      []OrderItemInput `json:"items" binding:"required,min=1,dive"`
+	Currency   Currency         `json:"currency"`
+	CouponCode string           `json:"coupon_code"`
+	TaxRegion  string           `json:"tax_region"`
+}
+
+// OrderItemInput is a line of an OrderInput
+type OrderItemInput struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
+// ToOrder converts OrderInput to an Order for the given user
+func (input *OrderInput) ToOrder(userID uuid.UUID) *Order {
+	order := &Order{
+		UserID:     userID,
+		Currency:   input.Currency,
+		CouponCode: input.CouponCode,
+		TaxRegion:  input.TaxRegion,
+	}
+	for _, item := range input.Items {
+		order.Items = append(order.Items, OrderItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return order
 }
 
 // TableName specifies the table name for the Order model
@@ -45,4 +116,4 @@ func (Order) TableName() string {
 // TableName specifies the table name for the OrderItem model
 func (OrderItem) TableName() string {
 	return "order_items"
-} 
\ No newline at end of file
+}
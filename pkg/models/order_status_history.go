@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ActorType identifies who caused an order status change
+type ActorType string
+
+const (
+	ActorUser    ActorType = "user"
+	ActorAdmin   ActorType = "admin"
+	ActorOneC    ActorType = "1c"
+	ActorSystem  ActorType = "system"
+	ActorPayment ActorType = "payment"
+)
+
+// Actor is the originator of a change. ID is set for user and admin actors.
+type Actor struct {
+	Type ActorType
+	ID   *uuid.UUID
+}
+
+// SystemActor is used for changes made by background jobs
+var SystemActor = Actor{Type: ActorSystem}
+
+// OneCActor is used for changes received from 1C
+var OneCActor = Actor{Type: ActorOneC}
+
+// PaymentActor is used for changes caused by payment provider webhooks
+var PaymentActor = Actor{Type: ActorPayment}
+
+// OrderStatusHistory is a single audited order status transition.
+// FromStatus is nil for the entry recorded when the order is created.
+type OrderStatusHistory struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	OrderID    uint         `gorm:"not null;index:idx_order_status_history_order,priority:1" json:"order_id"`
+	FromStatus *OrderStatus `gorm:"type:varchar(20)" json:"from_status"`
+	ToStatus   OrderStatus  `gorm:"type:varchar(20);not null" json:"to_status"`
+	ActorType  ActorType    `gorm:"type:varchar(10);not null" json:"actor_type"`
+	ActorID    *uuid.UUID   `gorm:"type:uuid" json:"actor_id"`
+	Reason     string       `gorm:"type:text" json:"reason"`
+	CreatedAt  time.Time    `gorm:"not null;index:idx_order_status_history_order,priority:2" json:"created_at"`
+}
+
+// TableName specifies the table name for the OrderStatusHistory model
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// PaymentStatus is the lifecycle state of a payment
+type PaymentStatus string
+
+const (
+	// PaymentPending waits for the customer to authorise the payment with
+	// the provider
+	PaymentPending PaymentStatus = "pending"
+	// PaymentSucceeded has been captured and paid the order
+	PaymentSucceeded PaymentStatus = "succeeded"
+	// PaymentFailed was declined, or authorised for an order that could no
+	// longer be paid and therefore never captured
+	PaymentFailed PaymentStatus = "failed"
+	// PaymentPartiallyRefunded has had part of its amount refunded
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
+	// PaymentRefunded has had its whole amount refunded
+	PaymentRefunded PaymentStatus = "refunded"
+)
+
+// Payment is an attempt to pay an order through a payment provider.
+// IntentID is the provider's reference; Amount and Currency are the order's
+// total when the payment was started.
+type Payment struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	OrderID        uint          `gorm:"not null;index:idx_payment_order" json:"order_id"`
+	Provider       string        `gorm:"size:20;not null" json:"provider"`
+	IntentID       string        `gorm:"size:100;not null;uniqueIndex:idx_payment_intent" json:"intent_id"`
+	ClientSecret   string        `gorm:"size:255" json:"client_secret,omitempty"`
+	Status         PaymentStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Amount         Money         `gorm:"not null;type:decimal(10,2)" json:"amount"`
+	Currency       Currency      `gorm:"type:varchar(3);not null" json:"currency"`
+	RefundedAmount Money         `gorm:"not null;type:decimal(10,2);default:0" json:"refunded_amount"`
+	FailureReason  string        `gorm:"type:text" json:"failure_reason,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// TableName specifies the table name for the Payment model
+func (Payment) TableName() string {
+	return "payments"
+}
@@ -6,19 +6,109 @@ import (
 	"gorm.io/gorm"
 )
 
+// Product is a catalogue item. Stock is the quantity on hand and Reserved the
+// part of it held by pending orders, so Stock - Reserved can still be ordered.
+// Products imported from 1C carry its ID as ExternalID and its item code as
+// Code. ModifiedBy and ModifiedAt record who changed the product last and
+// when, and SyncedAt when the sync with 1C last went over it.
 type Product struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
+	ExternalID  string         `gorm:"size:100;not null;default:''" json:"external_id,omitempty"`
+	Code        string         `gorm:"size:50;not null;default:''" json:"code,omitempty"`
 	Name        string         `gorm:"size:255;not null;index:idx_product_name" json:"name"`
 	Description string         `gorm:"type:text" json:"description"`
-	Price       float64        `gorm:"not null;type:decimal(10,2)" json:"price"`
+	Price       Money          `gorm:"not null;type:decimal(10,2)" json:"price"`
+	Currency    Currency       `gorm:"type:varchar(3);not null;default:'RUB'" json:"currency"`
 	SKU         string         `gorm:"size:50;not null;uniqueIndex:idx_product_sku" json:"sku"`
+	Category    string         `gorm:"size:100;not null;default:'';index:idx_product_category" json:"category"`
+	TaxClass    TaxClass       `gorm:"size:50;not null;default:'standard'" json:"tax_class"`
 	Stock       int            `gorm:"not null;default:0" json:"stock"`
+	Reserved    int            `gorm:"not null;default:0" json:"reserved"`
+	ModifiedBy  ProductSource  `gorm:"size:10;not null;default:'shop'" json:"modified_by"`
+	ModifiedAt  *time.Time     `json:"modified_at"`
+	SyncedAt    *time.Time     `json:"synced_at"`
 	CreatedAt   time.Time      `gorm:"index:idx_product_created" json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// ProductSource is a side that changes products: the shop or 1C
+type ProductSource string
+
+const (
+	ProductSourceShop ProductSource = "shop"
+	ProductSourceOneC ProductSource = "1c"
+)
+
+// NewProductInput is the payload for creating a product in the shop. Stock
+// is the opening quantity on hand; reservations, the 1C references and
+// provenance are maintained by the shop itself.
+type NewProductInput struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Price       *Money   `json:"price" binding:"required"`
+	Currency    Currency `json:"currency"`
+	SKU         string   `json:"sku" binding:"required"`
+	Category    string   `json:"category"`
+	TaxClass    TaxClass `json:"tax_class"`
+	Stock       int      `json:"stock" binding:"min=0"`
+}
+
+// ToProduct converts NewProductInput to a Product
+func (input *NewProductInput) ToProduct() *Product {
+	return &Product{
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       *input.Price,
+		Currency:    input.Currency,
+		SKU:         input.SKU,
+		Category:    input.Category,
+		TaxClass:    input.TaxClass,
+		Stock:       input.Stock,
+	}
+}
+
+// ProductInput is the payload for editing a product in the shop; fields left
+// out keep their value. Stock is set through its own endpoint, the 1C
+// references and provenance are maintained by the shop itself.
+type ProductInput struct {
+	Name        *string   `json:"name" binding:"omitempty,min=1"`
+	Description *string   `json:"description"`
+	Price       *Money    `json:"price"`
+	Currency    *Currency `json:"currency"`
+	SKU         *string   `json:"sku" binding:"omitempty,min=1"`
+	Category    *string   `json:"category"`
+	TaxClass    *TaxClass `json:"tax_class"`
+}
+
+// Apply copies the given fields onto the product and returns their columns
+func (input *ProductInput) Apply(product *Product) []string {
+	var columns []string
+	if input.Name != nil {
+		product.Name, columns = *input.Name, append(columns, "name")
+	}
+	if input.Description != nil {
+		product.Description, columns = *input.Description, append(columns, "description")
+	}
+	if input.Price != nil {
+		product.Price, columns = *input.Price, append(columns, "price")
+	}
+	if input.Currency != nil {
+		product.Currency, columns = *input.Currency, append(columns, "currency")
+	}
+	if input.SKU != nil {
+		product.SKU, columns = *input.SKU, append(columns, "sku")
+	}
+	if input.Category != nil {
+		product.Category, columns = *input.Category, append(columns, "category")
+	}
+	if input.TaxClass != nil {
+		product.TaxClass, columns = *input.TaxClass, append(columns, "tax_class")
+	}
+	return columns
+}
+
 // TableName specifies the table name for the Product model
 func (Product) TableName() string {
 	return "products"
-} 
\ No newline at end of file
+}
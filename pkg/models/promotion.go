@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PromotionType is the kind of discount a promotion gives
+type PromotionType string
+
+const (
+	// PromotionPercentage takes Percent off the eligible lines
+	PromotionPercentage PromotionType = "percentage"
+	// PromotionFixedAmount takes Amount off the eligible lines, spread in
+	// proportion to their totals
+	PromotionFixedAmount PromotionType = "fixed_amount"
+	// PromotionBuyXGetY makes GetQuantity of every BuyQuantity+GetQuantity
+	// units of an eligible product free
+	PromotionBuyXGetY PromotionType = "buy_x_get_y"
+)
+
+// IsValid reports whether the promotion type is known
+func (t PromotionType) IsValid() bool {
+	switch t {
+	case PromotionPercentage, PromotionFixedAmount, PromotionBuyXGetY:
+		return true
+	}
+	return false
+}
+
+// PromotionTargetKind names what a promotion target matches
+type PromotionTargetKind string
+
+const (
+	PromotionTargetSKU      PromotionTargetKind = "sku"
+	PromotionTargetCategory PromotionTargetKind = "category"
+)
+
+// Promotion is a discount rule. Promotions with a Code are coupons applied
+// when the customer enters the code; the others apply automatically to every
+// qualifying order. Without Targets a promotion covers every product.
+// Amount and MinBasket are in BaseCurrency.
+type Promotion struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	Name         string            `gorm:"size:255;not null" json:"name"`
+	Code         *string           `gorm:"size:64;uniqueIndex:idx_promotion_code" json:"code"`
+	Type         PromotionType     `gorm:"type:varchar(20);not null" json:"type"`
+	Percent      int               `gorm:"not null;default:0" json:"percent"`
+	Amount       Money             `gorm:"not null;type:decimal(10,2);default:0" json:"amount"`
+	BuyQuantity  int               `gorm:"not null;default:0" json:"buy_quantity"`
+	GetQuantity  int               `gorm:"not null;default:0" json:"get_quantity"`
+	MinBasket    Money             `gorm:"not null;type:decimal(10,2);default:0" json:"min_basket"`
+	UsageLimit   *int              `json:"usage_limit"`
+	PerUserLimit *int              `json:"per_user_limit"`
+	UsageCount   int               `gorm:"not null;default:0" json:"usage_count"`
+	StartsAt     *time.Time        `json:"starts_at"`
+	EndsAt       *time.Time        `json:"ends_at"`
+	Active       bool              `gorm:"not null;default:true" json:"active"`
+	Targets      []PromotionTarget `gorm:"foreignKey:PromotionID" json:"targets"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// PromotionTarget limits a promotion to a SKU or a product category
+type PromotionTarget struct {
+	ID          uint                `gorm:"primaryKey" json:"-"`
+	PromotionID uint                `gorm:"not null;index:idx_promotion_target_promotion" json:"-"`
+	Kind        PromotionTargetKind `gorm:"type:varchar(20);not null" json:"kind"`
+	Value       string              `gorm:"size:255;not null" json:"value"`
+}
+
+// PromotionRedemption records that an order used a promotion. It backs the
+// per-user limits and is removed again when the order is cancelled.
+type PromotionRedemption struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PromotionID uint      `gorm:"not null;index:idx_promotion_redemption_user,priority:1" json:"promotion_id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index:idx_promotion_redemption_user,priority:2" json:"user_id"`
+	OrderID     uint      `gorm:"not null;index:idx_promotion_redemption_order" json:"order_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AdjustmentKind is the kind of an order adjustment line
+type AdjustmentKind string
+
+const (
+	AdjustmentDiscount AdjustmentKind = "discount"
+)
+
+// OrderAdjustment is a line that changes an order's total on top of its
+// items, such as a discount. Amount is in the order currency and negative for
+// discounts. Adjustments that concern a single product carry its ProductID so
+// refunds of that item can take them into account.
+type OrderAdjustment struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	OrderID     uint           `gorm:"not null;index:idx_order_adjustment_order" json:"order_id"`
+	Kind        AdjustmentKind `gorm:"type:varchar(20);not null" json:"kind"`
+	PromotionID *uint          `json:"promotion_id,omitempty"`
+	ProductID   *uint          `json:"product_id,omitempty"`
+	Code        string         `gorm:"size:64" json:"code,omitempty"`
+	Description string         `gorm:"size:255" json:"description"`
+	Amount      Money          `gorm:"not null;type:decimal(10,2)" json:"amount"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// TableName specifies the table name for the Promotion model
+func (Promotion) TableName() string {
+	return "promotions"
+}
+
+// TableName specifies the table name for the PromotionTarget model
+func (PromotionTarget) TableName() string {
+	return "promotion_targets"
+}
+
+// TableName specifies the table name for the PromotionRedemption model
+func (PromotionRedemption) TableName() string {
+	return "promotion_redemptions"
+}
+
+// TableName specifies the table name for the OrderAdjustment model
+func (OrderAdjustment) TableName() string {
+	return "order_adjustments"
+}
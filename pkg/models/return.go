@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReturnStatus is the lifecycle state of a return request
+type ReturnStatus string
+
+const (
+	// ReturnRequested waits for staff to review the request
+	ReturnRequested ReturnStatus = "requested"
+	// ReturnApproved waits for the goods to come back
+	ReturnApproved ReturnStatus = "approved"
+	// ReturnRejected was turned down by staff
+	ReturnRejected ReturnStatus = "rejected"
+	// ReturnCompleted has been received and refunded
+	ReturnCompleted ReturnStatus = "completed"
+)
+
+// Return is a customer's request to send back items of a delivered order
+// (an RMA). Restocked records whether the received goods went back into
+// stock; Synced whether the completed return was reported to 1C.
+type Return struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	OrderID    uint         `gorm:"not null;index:idx_return_order" json:"order_id"`
+	UserID     uuid.UUID    `gorm:"type:uuid;not null" json:"user_id"`
+	Status     ReturnStatus `gorm:"type:varchar(20);not null;default:'requested';index:idx_return_status" json:"status"`
+	Reason     string       `gorm:"type:text;not null" json:"reason"`
+	Resolution string       `gorm:"type:text" json:"resolution,omitempty"`
+	Restocked  bool         `gorm:"not null;default:false" json:"restocked"`
+	Items      []ReturnItem `gorm:"foreignKey:ReturnID" json:"items"`
+	Refund     *Refund      `gorm:"foreignKey:ReturnID" json:"refund,omitempty"`
+	Synced     bool         `gorm:"not null;default:false" json:"-"`
+	ResolvedAt *time.Time   `json:"resolved_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// ReturnItem is a quantity of one order line being returned. Amount is the
+// part of the line's gross total that is refunded for it.
+type ReturnItem struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ReturnID    uint      `gorm:"not null;index:idx_return_item_return" json:"-"`
+	OrderItemID uint      `gorm:"not null;index:idx_return_item_order_item" json:"order_item_id"`
+	OrderItem   OrderItem `gorm:"foreignKey:OrderItemID" json:"-"`
+	ProductID   uint      `gorm:"not null" json:"product_id"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+	Amount      Money     `gorm:"not null;type:decimal(10,2)" json:"amount"`
+}
+
+// ReturnInput is the payload of a return request
+type ReturnInput struct {
+	Reason string `json:"reason" binding:"required"`
+	Items  []struct {
+		OrderItemID uint `json:"order_item_id" binding:"required"`
+		Quantity    int  `json:"quantity" binding:"required,min=1"`
+	} `json:"items" binding:"required,min=1,dive"`
+}
+
+// Refund is money given back on an order, in the order's currency. PaymentID
+// is set when it went back through the payment provider, ReturnID when it
+// settles a return.
+type Refund struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	OrderID           uint      `gorm:"not null;index:idx_refund_order" json:"order_id"`
+	ReturnID          *uint     `gorm:"uniqueIndex:idx_refund_return" json:"return_id"`
+	PaymentID         *uint     `json:"payment_id"`
+	Payment           *Payment  `gorm:"foreignKey:PaymentID" json:"payment,omitempty"`
+	Amount            Money     `gorm:"not null;type:decimal(10,2)" json:"amount"`
+	Currency          Currency  `gorm:"type:varchar(3);not null" json:"currency"`
+	ProviderReference string    `gorm:"size:100" json:"provider_reference,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for the Return model
+func (Return) TableName() string {
+	return "returns"
+}
+
+// TableName specifies the table name for the ReturnItem model
+func (ReturnItem) TableName() string {
+	return "return_items"
+}
+
+// TableName specifies the table name for the Refund model
+func (Refund) TableName() string {
+	return "refunds"
+}
@@ -0,0 +1,65 @@
+package models
+
+// Role is the access level assigned to a user
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleManager  Role = "manager"
+	RoleCustomer Role = "customer"
+)
+
+// Permission names a single capability checked by route policies
+type Permission string
+
+const (
+	PermissionUsersManage    Permission = "users:manage"
+	PermissionProductsWrite  Permission = "products:write"
+	PermissionProductsDelete Permission = "products:delete"
+	PermissionStockWrite     Permission = "stock:write"
+	PermissionOrdersReadAll  Permission = "orders:read_all"
+	PermissionOrdersManage   Permission = "orders:manage"
+	PermissionRatesWrite     Permission = "exchange_rates:write"
+	PermissionPromotions     Permission = "promotions:manage"
+	PermissionTaxRatesWrite  Permission = "tax_rates:write"
+)
+
+// rolePermissions maps every role to the permissions it grants.
+// Customers get no extra permissions: they may only act on their own records.
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionUsersManage,
+		PermissionProductsWrite,
+		PermissionProductsDelete,
+		PermissionStockWrite,
+		PermissionOrdersReadAll,
+		PermissionOrdersManage,
+		PermissionRatesWrite,
+		PermissionPromotions,
+		PermissionTaxRatesWrite,
+	},
+	RoleManager: {
+		PermissionProductsWrite,
+		PermissionOrdersReadAll,
+		PermissionOrdersManage,
+		PermissionRatesWrite,
+		PermissionPromotions,
+	},
+	RoleCustomer: {},
+}
+
+// IsValid reports whether the role is one of the known roles
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants the given permission
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// ShipmentStatus is the delivery state of a shipment as reported by its
+// carrier
+type ShipmentStatus string
+
+const (
+	// ShipmentInTransit has been handed to the carrier
+	ShipmentInTransit ShipmentStatus = "in_transit"
+	// ShipmentDelivered has reached the customer
+	ShipmentDelivered ShipmentStatus = "delivered"
+)
+
+// Shipment is a parcel with some or all of an order's items. An order ships
+// once its shipments hold every item and is delivered once all of them are.
+type Shipment struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	OrderID      uint           `gorm:"not null;index:idx_shipment_order" json:"order_id"`
+	Carrier      string         `gorm:"size:50;not null" json:"carrier"`
+	TrackingCode string         `gorm:"size:100;not null" json:"tracking_code"`
+	Status       ShipmentStatus `gorm:"type:varchar(20);not null;default:'in_transit';index:idx_shipment_status" json:"status"`
+	Items        []ShipmentItem `gorm:"foreignKey:ShipmentID" json:"items"`
+	ShippedAt    time.Time      `gorm:"not null" json:"shipped_at"`
+	DeliveredAt  *time.Time     `json:"delivered_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// ShipmentItem is a quantity of an order item packed into a shipment
+type ShipmentItem struct {
+	ID          uint `gorm:"primaryKey" json:"id"`
+	ShipmentID  uint `gorm:"not null;index:idx_shipment_item_shipment" json:"-"`
+	OrderItemID uint `gorm:"not null;index:idx_shipment_item_order_item" json:"order_item_id"`
+	ProductID   uint `gorm:"not null" json:"product_id"`
+	Quantity    int  `gorm:"not null" json:"quantity"`
+}
+
+// ShipmentInput is the body of a shipment request. Without items the
+// shipment holds everything not shipped yet.
+type ShipmentInput struct {
+	Items []struct {
+		OrderItemID uint `json:"order_item_id" binding:"required"`
+		Quantity    int  `json:"quantity" binding:"required,min=1"`
+	} `json:"items" binding:"dive"`
+}
+
+// TableName specifies the table name for the Shipment model
+func (Shipment) TableName() string {
+	return "shipments"
+}
+
+// TableName specifies the table name for the ShipmentItem model
+func (ShipmentItem) TableName() string {
+	return "shipment_items"
+}
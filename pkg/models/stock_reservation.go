@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// ReservationStatus is the lifecycle state of a stock reservation
+type ReservationStatus string
+
+const (
+	// ReservationActive holds stock for a pending order until it expires
+	ReservationActive ReservationStatus = "active"
+	// ReservationCommitted has been converted into a stock deduction on payment
+	ReservationCommitted ReservationStatus = "committed"
+	// ReservationReleased no longer holds or deducts stock
+	ReservationReleased ReservationStatus = "released"
+)
+
+// StockReservation holds Quantity units of a product for an order. Active
+// reservations are counted in Product.Reserved and reduce the available stock
+// without touching Product.Stock.
+type StockReservation struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	OrderID   uint              `gorm:"not null;index:idx_stock_reservation_order" json:"order_id"`
+	ProductID uint              `gorm:"not null" json:"product_id"`
+	Quantity  int               `gorm:"not null" json:"quantity"`
+	Status    ReservationStatus `gorm:"type:varchar(20);not null;default:'active'" json:"status"`
+	ExpiresAt time.Time         `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// TableName specifies the table name for the StockReservation model
+func (StockReservation) TableName() string {
+	return "stock_reservations"
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// ProductSyncValue is a synced product field as 1C last sent it
+type ProductSyncValue struct {
+	ProductID uint   `gorm:"primaryKey;autoIncrement:false" json:"product_id"`
+	Field     string `gorm:"primaryKey;size:20" json:"field"`
+	Value     string `gorm:"type:text;not null" json:"value"`
+}
+
+// TableName specifies the table name for the ProductSyncValue model
+func (ProductSyncValue) TableName() string {
+	return "product_sync_values"
+}
+
+// SyncConflict records a product field that the shop and 1C both changed
+// between two syncs: SyncedValue is its value as 1C last sent it, ShopValue
+// and OneCValue the two changes and Winner the side whose change was kept.
+type SyncConflict struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	ProductID   uint          `gorm:"not null;index:idx_sync_conflict_product,priority:1" json:"product_id"`
+	Field       string        `gorm:"size:20;not null" json:"field"`
+	SyncedValue string        `gorm:"type:text;not null" json:"synced_value"`
+	ShopValue   string        `gorm:"type:text;not null" json:"shop_value"`
+	OneCValue   string        `gorm:"column:onec_value;type:text;not null" json:"onec_value"`
+	Winner      ProductSource `gorm:"size:10;not null" json:"winner"`
+	CreatedAt   time.Time     `gorm:"index:idx_sync_conflict_product,priority:2" json:"created_at"`
+}
+
+// TableName specifies the table name for the SyncConflict model
+func (SyncConflict) TableName() string {
+	return "sync_conflicts"
+}
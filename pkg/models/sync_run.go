@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// SyncType is a sync job with 1C
+type SyncType string
+
+const (
+	SyncTypeProducts SyncType = "products"
+	SyncTypeOrders   SyncType = "orders"
+	SyncTypeReturns  SyncType = "returns"
+	SyncTypeStock    SyncType = "stock"
+)
+
+// SyncTypes lists every sync job
+var SyncTypes = []SyncType{SyncTypeProducts, SyncTypeOrders, SyncTypeReturns, SyncTypeStock}
+
+// SyncTrigger is what started a sync run
+type SyncTrigger string
+
+const (
+	// SyncTriggerWorker runs come from the background sync worker
+	SyncTriggerWorker SyncTrigger = "worker"
+	// SyncTriggerManual runs were asked for through the sync API
+	SyncTriggerManual SyncTrigger = "manual"
+	// SyncTriggerForced runs were asked for with force set
+	SyncTriggerForced SyncTrigger = "forced"
+)
+
+// SyncRun records one run of a sync job. Fetched counts the records read
+// from 1C or, for jobs sending to 1C, from the database; Created and Updated
+// the records the run changed and Failed those it could not apply. A run
+// without FinishedAt is still going; one with an Error failed.
+type SyncRun struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	Type       SyncType    `gorm:"type:varchar(20);not null" json:"type"`
+	Trigger    SyncTrigger `gorm:"type:varchar(20);not null" json:"trigger"`
+	StartedAt  time.Time   `gorm:"not null" json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at"`
+	Fetched    int         `gorm:"not null;default:0" json:"fetched"`
+	Created    int         `gorm:"not null;default:0" json:"created"`
+	Updated    int         `gorm:"not null;default:0" json:"updated"`
+	Failed     int         `gorm:"not null;default:0" json:"failed"`
+	Error      string      `gorm:"type:text;not null;default:''" json:"error,omitempty"`
+}
+
+// TableName specifies the table name for the SyncRun model
+func (SyncRun) TableName() string {
+	return "sync_runs"
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// TaxClass groups products that are taxed at the same rate, e.g. the reduced
+// VAT rate for food and children's goods
+type TaxClass string
+
+const (
+	TaxClassStandard TaxClass = "standard"
+	TaxClassReduced  TaxClass = "reduced"
+	TaxClassZero     TaxClass = "zero"
+)
+
+// DefaultTaxRegion is the region orders are taxed in when none is given
+const DefaultTaxRegion = "RU"
+
+// TaxRate is the VAT percentage charged on a tax class in a region. Catalogue
+// prices include VAT, so the rate is used to split a gross amount into its
+// net and tax parts.
+type TaxRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Region    string    `gorm:"size:10;not null;uniqueIndex:idx_tax_rate_region_class,priority:1" json:"region"`
+	TaxClass  TaxClass  `gorm:"size:50;not null;uniqueIndex:idx_tax_rate_region_class,priority:2" json:"tax_class"`
+	Percent   int       `gorm:"not null" json:"percent"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TaxRateInput is the payload for setting the rate of a tax class in a region
+type TaxRateInput struct {
+	Region   string   `json:"region" binding:"required"`
+	TaxClass TaxClass `json:"tax_class" binding:"required"`
+	Percent  int      `json:"percent" binding:"min=0,max=100"`
+}
+
+// TaxLine totals the order items taxed at one rate
+type TaxLine struct {
+	Percent int   `json:"percent"`
+	Net     Money `json:"net"`
+	Tax     Money `json:"tax"`
+	Gross   Money `json:"gross"`
+}
+
+// TableName specifies the table name for the TaxRate model
+func (TaxRate) TableName() string {
+	return "tax_rates"
+}
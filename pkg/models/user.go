@@ -15,6 +15,7 @@ type User struct {
 	FirstName    string         `json:"first_name"`
 	LastName     string         `json:"last_name"`
 	Phone        string         `json:"phone"`
+	Role         Role           `gorm:"type:varchar(20);not null;default:'customer'" json:"role"`
 	CreatedAt    time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt    time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
@@ -29,11 +30,19 @@ type UserInput struct {
 	Phone     string `json:"phone" binding:"required"`
 }
 
+// RoleInput represents the payload for changing a user's role
+type RoleInput struct {
+	Role Role `json:"role" binding:"required"`
+}
+
 // BeforeCreate is a GORM hook that's called before creating a new user
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
 		u.ID = uuid.New()
 	}
+	if u.Role == "" {
+		u.Role = RoleCustomer
+	}
 	return nil
 }
 
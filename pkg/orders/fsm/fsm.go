@@ -0,0 +1,128 @@
+// Package fsm implements the order lifecycle as a state machine. Every code
+// path that changes an order's status goes through a Machine, which checks the
+// transition is defined, runs its guards, persists the change with its audit
+// entry and runs the on-enter hooks of the new state, all in the caller's
+// transaction.
+package fsm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTransition is returned for transitions the machine does not define
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrTransitionDenied is returned when a guard rejects a defined transition
+	ErrTransitionDenied = errors.New("status transition denied")
+)
+
+// Context describes the transition being attempted. Tx is nil when a
+// transition is only being checked.
+type Context struct {
+	Tx     *gorm.DB
+	Order  *models.Order
+	From   models.OrderStatus
+	To     models.OrderStatus
+	Actor  models.Actor
+	Reason string
+}
+
+// Guard decides whether a transition may happen; a non-nil error rejects it
+type Guard func(ctx *Context) error
+
+// Hook runs after the machine has entered a state. An error rolls back the
+// whole transition together with the caller's transaction.
+type Hook func(ctx *Context) error
+
+// Machine is a set of allowed transitions with their guards and hooks
+type Machine struct {
+	transitions map[models.OrderStatus]map[models.OrderStatus][]Guard
+	order       map[models.OrderStatus][]models.OrderStatus
+	onEnter     map[models.OrderStatus][]Hook
+}
+
+// New returns an empty machine
+func New() *Machine {
+	return &Machine{
+		transitions: map[models.OrderStatus]map[models.OrderStatus][]Guard{},
+		order:       map[models.OrderStatus][]models.OrderStatus{},
+		onEnter:     map[models.OrderStatus][]Hook{},
+	}
+}
+
+// AddTransition allows moving from one state to another when all guards pass
+func (m *Machine) AddTransition(from, to models.OrderStatus, guards ...Guard) *Machine {
+	if m.transitions[from] == nil {
+		m.transitions[from] = map[models.OrderStatus][]Guard{}
+	}
+	if _, exists := m.transitions[from][to]; !exists {
+		m.order[from] = append(m.order[from], to)
+	}
+	m.transitions[from][to] = append(m.transitions[from][to], guards...)
+	return m
+}
+
+// OnEnter registers hooks that run whenever the machine enters state
+func (m *Machine) OnEnter(state models.OrderStatus, hooks ...Hook) *Machine {
+	m.onEnter[state] = append(m.onEnter[state], hooks...)
+	return m
+}
+
+// Can reports whether the transition is defined, without running guards
+func (m *Machine) Can(from, to models.OrderStatus) bool {
+	_, ok := m.transitions[from][to]
+	return ok
+}
+
+// Targets returns the states reachable from a state, in definition order
+func (m *Machine) Targets(from models.OrderStatus) []models.OrderStatus {
+	return append([]models.OrderStatus(nil), m.order[from]...)
+}
+
+// Check reports whether the transition described by ctx is defined and
+// accepted by all of its guards
+func (m *Machine) Check(ctx *Context) error {
+	guards, ok := m.transitions[ctx.From][ctx.To]
+	if !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, ctx.From, ctx.To)
+	}
+	for _, guard := range guards {
+		if err := guard(ctx); err != nil {
+			return fmt.Errorf("%w: %v", ErrTransitionDenied, err)
+		}
+	}
+	return nil
+}
+
+// Fire moves the order to a new state inside tx: it checks the transition,
+// persists the status with its history entry and runs the on-enter hooks
+func (m *Machine) Fire(tx *gorm.DB, order *models.Order, to models.OrderStatus, actor models.Actor, reason string) error {
+	ctx := &Context{
+		Tx:     tx,
+		Order:  order,
+		From:   order.Status,
+		To:     to,
+		Actor:  actor,
+		Reason: reason,
+	}
+	if err := m.Check(ctx); err != nil {
+		return err
+	}
+
+	if err := database.SetOrderStatus(tx, order, to, actor, reason); err != nil {
+		return err
+	}
+
+	for _, hook := range m.onEnter[to] {
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("entering %s: %w", to, err)
+		}
+	}
+	return nil
+}
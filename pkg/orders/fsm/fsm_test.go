@@ -0,0 +1,130 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var allStatuses = []models.OrderStatus{
+	models.OrderStatusPending,
+	models.OrderStatusPaid,
+	models.OrderStatusShipped,
+	models.OrderStatusDelivered,
+	models.OrderStatusCancelled,
+	models.OrderStatusPartiallyRefunded,
+	models.OrderStatusRefunded,
+}
+
+var allActors = []models.ActorType{
+	models.ActorUser,
+	models.ActorAdmin,
+	models.ActorOneC,
+	models.ActorSystem,
+	models.ActorPayment,
+}
+
+func TestOrderMachineTransitionMatrix(t *testing.T) {
+	// expected[from][to] lists the actors allowed to take the transition;
+	// missing entries are transitions that must not exist
+	staff := []models.ActorType{models.ActorAdmin, models.ActorOneC, models.ActorSystem}
+	payment := []models.ActorType{models.ActorOneC, models.ActorSystem, models.ActorPayment}
+	expected := map[models.OrderStatus]map[models.OrderStatus][]models.ActorType{
+		models.OrderStatusPending: {
+			models.OrderStatusPaid:      payment,
+			models.OrderStatusCancelled: allActors,
+		},
+		models.OrderStatusPaid: {
+			models.OrderStatusShipped:   staff,
+			models.OrderStatusCancelled: allActors,
+		},
+		models.OrderStatusShipped: {
+			models.OrderStatusDelivered: staff,
+			models.OrderStatusCancelled: staff,
+		},
+		models.OrderStatusDelivered: {
+			models.OrderStatusPartiallyRefunded: staff,
+			models.OrderStatusRefunded:          staff,
+		},
+		models.OrderStatusPartiallyRefunded: {
+			models.OrderStatusRefunded: staff,
+		},
+	}
+
+	m := NewOrderMachine()
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			allowed, defined := expected[from][to]
+			assert.Equal(t, defined, m.Can(from, to), "Can(%s, %s)", from, to)
+
+			for _, actor := range allActors {
+				err := m.Check(&Context{
+					Order: &models.Order{Status: from},
+					From:  from,
+					To:    to,
+					Actor: models.Actor{Type: actor},
+				})
+				switch {
+				case !defined:
+					assert.ErrorIs(t, err, ErrInvalidTransition, "%s -> %s by %s", from, to, actor)
+				case contains(allowed, actor):
+					assert.NoError(t, err, "%s -> %s by %s", from, to, actor)
+				default:
+					assert.ErrorIs(t, err, ErrTransitionDenied, "%s -> %s by %s", from, to, actor)
+				}
+			}
+		}
+	}
+}
+
+func TestOrderMachineFinalStates(t *testing.T) {
+	m := NewOrderMachine()
+	assert.Empty(t, m.Targets(models.OrderStatusRefunded))
+	assert.Empty(t, m.Targets(models.OrderStatusCancelled))
+	assert.Equal(t,
+		[]models.OrderStatus{models.OrderStatusPaid, models.OrderStatusCancelled},
+		m.Targets(models.OrderStatusPending))
+	assert.Equal(t,
+		[]models.OrderStatus{models.OrderStatusPartiallyRefunded, models.OrderStatusRefunded},
+		m.Targets(models.OrderStatusDelivered))
+}
+
+func TestMachineGuardsRunInOrder(t *testing.T) {
+	var calls []string
+	guard := func(name string, err error) Guard {
+		return func(ctx *Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	m := New().AddTransition("a", "b", guard("first", nil), guard("second", errors.New("no")), guard("third", nil))
+	err := m.Check(&Context{From: "a", To: "b"})
+
+	assert.ErrorIs(t, err, ErrTransitionDenied)
+	assert.Contains(t, err.Error(), "no")
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestReleaseStockLeavesShippedGoods(t *testing.T) {
+	// Without a transaction Release would panic, so returning nil shows the
+	// stock of the shipped order was not touched
+	err := ReleaseStock(&Context{
+		Order: &models.Order{ID: 1, Status: models.OrderStatusShipped},
+		From:  models.OrderStatusShipped,
+		To:    models.OrderStatusCancelled,
+	})
+	assert.NoError(t, err)
+}
+
+func contains(actors []models.ActorType, actor models.ActorType) bool {
+	for _, a := range actors {
+		if a == actor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package fsm
+
+import (
+	"fmt"
+
+	"github.com/alzarasatken/FullStackTest/pkg/inventory"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/payments"
+	"github.com/alzarasatken/FullStackTest/pkg/promotions"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewOrderMachine returns the order lifecycle shared by the API and the 1C
+// integration. Orders move pending -> paid -> shipped -> delivered and can be
+// cancelled until they are delivered. Customers may only cancel orders that
+// have not shipped; fulfilment steps are taken by staff, 1C or background
+// jobs. Only captured payments, 1C and background jobs mark orders paid, so
+// staff cannot hand out goods that nobody paid for. Entering paid turns the
+// order's stock reservations into deductions and entering cancelled gives
+// the reserved or deducted stock back, unless the goods already shipped,
+// along with the promotion uses the order took; WithRefunds gives the money
+// of paid orders back as well. Delivered orders are reversed through returns
+// instead, which move them to partially_refunded and finally refunded.
+func NewOrderMachine(opts ...Option) *Machine {
+	fulfilment := RequireActor(models.ActorAdmin, models.ActorOneC, models.ActorSystem)
+	payment := RequireActor(models.ActorOneC, models.ActorSystem, models.ActorPayment)
+
+	m := New().
+		AddTransition(models.OrderStatusPending, models.OrderStatusPaid, payment).
+		AddTransition(models.OrderStatusPending, models.OrderStatusCancelled).
+		AddTransition(models.OrderStatusPaid, models.OrderStatusShipped, fulfilment).
+		AddTransition(models.OrderStatusPaid, models.OrderStatusCancelled).
+		AddTransition(models.OrderStatusShipped, models.OrderStatusDelivered, fulfilment).
+		AddTransition(models.OrderStatusShipped, models.OrderStatusCancelled, fulfilment).
+		AddTransition(models.OrderStatusDelivered, models.OrderStatusPartiallyRefunded, fulfilment).
+		AddTransition(models.OrderStatusDelivered, models.OrderStatusRefunded, fulfilment).
+		AddTransition(models.OrderStatusPartiallyRefunded, models.OrderStatusRefunded, fulfilment).
+		OnEnter(models.OrderStatusPaid, CommitStock).
+		OnEnter(models.OrderStatusCancelled, ReleaseStock, ReleasePromotions)
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Option configures the machine built by NewOrderMachine
+type Option func(*Machine)
+
+// WithRefunds refunds cancelled orders through provider, see RefundPayment
+func WithRefunds(provider payments.Provider) Option {
+	return func(m *Machine) {
+		m.OnEnter(models.OrderStatusCancelled, RefundPayment(provider))
+	}
+}
+
+// RequireActor only lets the given kinds of actor through
+func RequireActor(types ...models.ActorType) Guard {
+	return func(ctx *Context) error {
+		for _, t := range types {
+			if ctx.Actor.Type == t {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s may not move an order from %s to %s", ctx.Actor.Type, ctx.From, ctx.To)
+	}
+}
+
+// CommitStock deducts the stock reserved for the order
+func CommitStock(ctx *Context) error {
+	return inventory.Commit(ctx.Tx, ctx.Order.ID)
+}
+
+// ReleaseStock returns the stock reserved or deducted for the order. Goods
+// of shipped orders have left the warehouse and are not put back; they come
+// back through returns if at all.
+func ReleaseStock(ctx *Context) error {
+	if ctx.From == models.OrderStatusShipped {
+		return nil
+	}
+	return inventory.Release(ctx.Tx, ctx.Order.ID)
+}
+
+// RefundPayment returns a hook that gives what is left of the total of a
+// paid or shipped order back through provider when it is cancelled. Orders
+// paid outside the provider get a refund record for staff to settle, see
+// payments.RefundOrder. Pending orders have taken no money.
+func RefundPayment(provider payments.Provider) Hook {
+	return func(ctx *Context) error {
+		if ctx.From == models.OrderStatusPending {
+			return nil
+		}
+		var refunded models.Money
+		if err := ctx.Tx.Model(&models.Refund{}).Where("order_id = ?", ctx.Order.ID).
+			Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error; err != nil {
+			return fmt.Errorf("loading refunds: %w", err)
+		}
+		remaining := ctx.Order.Total.Sub(refunded)
+		if remaining.IsZero() || remaining.IsNegative() {
+			return nil
+		}
+		if _, err := payments.RefundOrder(ctx.Tx.Statement.Context, ctx.Tx, provider, ctx.Order, remaining, nil); err != nil {
+			return fmt.Errorf("refunding order: %w", err)
+		}
+		return nil
+	}
+}
+
+// ReleasePromotions stops the order's promotion uses counting against their
+// usage limits
+func ReleasePromotions(ctx *Context) error {
+	return promotions.Release(ctx.Tx, ctx.Order.ID)
+}
+
+// CancelExpired cancels an order whose stock reservation ran out. Orders
+// that were paid or cancelled in the meantime are left alone. It is meant to
+// be passed to inventory.NewSweeper.
+func (m *Machine) CancelExpired(tx *gorm.DB, orderID uint) error {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return fmt.Errorf("loading order: %w", err)
+	}
+	if order.Status != models.OrderStatusPending {
+		return nil
+	}
+	return m.Fire(tx, &order, models.OrderStatusCancelled, models.SystemActor, "stock reservation expired")
+}
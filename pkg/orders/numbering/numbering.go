@@ -0,0 +1,70 @@
+// Package numbering gives orders human-readable numbers such as
+// ORD-2026-000042. Numbers come from a gap-free counter in the database, so
+// they are unique across concurrent requests and instances.
+package numbering
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+// Config shapes order numbers. Prefix starts every number; with YearlyReset
+// the year follows it and counting restarts at 1 each January. The counter
+// is zero-padded to Padding digits.
+type Config struct {
+	Prefix      string
+	YearlyReset bool
+	Padding     int
+}
+
+// DefaultConfig numbers orders ORD-000001, ORD-000002 and so on
+var DefaultConfig = Config{Prefix: "ORD-", Padding: 6}
+
+// Generator hands out order numbers
+type Generator struct {
+	config Config
+	now    func() time.Time
+}
+
+// NewGenerator creates a generator numbering orders as config says
+func NewGenerator(config Config) *Generator {
+	if config.Padding < 1 {
+		config.Padding = 1
+	}
+	return &Generator{config: config, now: time.Now}
+}
+
+// Next takes the next order number. The counter stays locked until tx ends,
+// which keeps the numbers free of gaps but serialises the transactions
+// placing orders from that point, so callers take the number last.
+func (g *Generator) Next(tx *gorm.DB) (string, error) {
+	year := g.now().UTC().Year()
+	value, err := database.NextSequenceValue(tx, g.sequence(year))
+	if err != nil {
+		return "", err
+	}
+	return g.Format(year, value), nil
+}
+
+// Format renders the value of the counter of year as an order number
+func (g *Generator) Format(year int, value int64) string {
+	number := fmt.Sprintf("%0*d", g.config.Padding, value)
+	if g.config.YearlyReset {
+		return g.config.Prefix + strconv.Itoa(year) + "-" + number
+	}
+	return g.config.Prefix + number
+}
+
+// sequence names the counter numbers are taken from; yearly numbering keeps
+// a counter per year
+func (g *Generator) sequence(year int) string {
+	if g.config.YearlyReset {
+		return "order-" + strconv.Itoa(year)
+	}
+	return "order"
+}
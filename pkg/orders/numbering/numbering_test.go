@@ -0,0 +1,32 @@
+package numbering
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		config Config
+		value  int64
+		want   string
+	}{
+		{DefaultConfig, 42, "ORD-000042"},
+		{Config{Prefix: "ORD-", YearlyReset: true, Padding: 6}, 42, "ORD-2026-000042"},
+		{Config{Prefix: "", Padding: 3}, 7, "007"},
+		{Config{Prefix: "A", Padding: 2}, 1234, "A1234"},
+		{Config{Prefix: "N"}, 5, "N5"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NewGenerator(tt.config).Format(2026, tt.value))
+	}
+}
+
+func TestSequencePerYear(t *testing.T) {
+	assert.Equal(t, "order", NewGenerator(DefaultConfig).sequence(2026))
+
+	yearly := NewGenerator(Config{Prefix: "ORD-", YearlyReset: true, Padding: 6})
+	assert.Equal(t, "order-2026", yearly.sequence(2026))
+	assert.NotEqual(t, yearly.sequence(2026), yearly.sequence(2027))
+}
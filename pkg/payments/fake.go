@@ -0,0 +1,116 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// FakeSignatureHeader carries the signature of fake provider webhooks
+const FakeSignatureHeader = "X-Fake-Signature"
+
+const fakeIntentPrefix = "fake_pi_"
+
+// FakeProvider is an in-process provider for development and tests. It keeps
+// no state: every intent it issued can be captured and refunded, and
+// webhooks are signed with an HMAC of the shared secret. Authorize and
+// Decline produce the webhooks a real provider would send once the customer
+// acted.
+type FakeProvider struct {
+	secret []byte
+}
+
+// NewFakeProvider creates a fake provider signing webhooks with secret
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: []byte(secret)}
+}
+
+// Name identifies the provider in stored payments
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateIntent issues a new intent ID
+func (f *FakeProvider) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	if req.Amount.IsZero() || req.Amount.IsNegative() {
+		return nil, fmt.Errorf("invalid payment amount %s", req.Amount)
+	}
+	id := fakeIntentPrefix + uuid.NewString()
+	return &Intent{ID: id, ClientSecret: id + "_secret"}, nil
+}
+
+// Capture accepts any intent this provider issued
+func (f *FakeProvider) Capture(ctx context.Context, intentID string, amount models.Money) error {
+	return f.check(intentID, amount)
+}
+
+// Refund accepts any intent this provider issued
+func (f *FakeProvider) Refund(ctx context.Context, intentID string, amount models.Money) (string, error) {
+	if err := f.check(intentID, amount); err != nil {
+		return "", err
+	}
+	return "fake_re_" + uuid.NewString(), nil
+}
+
+// VerifyWebhook checks the HMAC signature of the payload
+func (f *FakeProvider) VerifyWebhook(payload []byte, header http.Header) (*Event, error) {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, f.sign(payload)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decoding event: %w", err)
+	}
+	return &event, nil
+}
+
+// Authorize returns the signed webhook reporting that the customer
+// authorised amount on the intent
+func (f *FakeProvider) Authorize(intentID string, amount models.Money, currency models.Currency) ([]byte, http.Header) {
+	return f.Webhook(Event{Type: EventAuthorized, IntentID: intentID, Amount: amount, Currency: currency})
+}
+
+// Decline returns the signed webhook reporting that the intent was declined
+func (f *FakeProvider) Decline(intentID, reason string) ([]byte, http.Header) {
+	return f.Webhook(Event{Type: EventFailed, IntentID: intentID, Reason: reason})
+}
+
+// Webhook signs an arbitrary event, giving it an ID when it has none
+func (f *FakeProvider) Webhook(event Event) ([]byte, http.Header) {
+	if event.ID == "" {
+		event.ID = "fake_evt_" + uuid.NewString()
+	}
+	payload, _ := json.Marshal(event)
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(FakeSignatureHeader, hex.EncodeToString(f.sign(payload)))
+	return payload, header
+}
+
+func (f *FakeProvider) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (f *FakeProvider) check(intentID string, amount models.Money) error {
+	if !strings.HasPrefix(intentID, fakeIntentPrefix) {
+		return fmt.Errorf("unknown payment intent %q", intentID)
+	}
+	if amount.IsZero() || amount.IsNegative() {
+		return fmt.Errorf("invalid amount %s", amount)
+	}
+	return nil
+}
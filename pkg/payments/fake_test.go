@@ -0,0 +1,49 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeProviderWebhooks(t *testing.T) {
+	fake := NewFakeProvider("secret")
+	intent, err := fake.CreateIntent(context.Background(), IntentRequest{
+		OrderID:  1,
+		Amount:   models.MustParseMoney("12.50"),
+		Currency: models.CurrencyRUB,
+	})
+	require.NoError(t, err)
+
+	payload, header := fake.Authorize(intent.ID, models.MustParseMoney("12.50"), models.CurrencyRUB)
+	event, err := fake.VerifyWebhook(payload, header)
+	require.NoError(t, err)
+	assert.Equal(t, EventAuthorized, event.Type)
+	assert.Equal(t, intent.ID, event.IntentID)
+	assert.Equal(t, models.MustParseMoney("12.50"), event.Amount)
+	assert.NotEmpty(t, event.ID)
+
+	t.Run("Tampered payload", func(t *testing.T) {
+		tampered := append([]byte(nil), payload...)
+		tampered[len(tampered)-2] = ' '
+		_, err := fake.VerifyWebhook(tampered, header)
+		assert.True(t, errors.Is(err, ErrInvalidSignature))
+	})
+
+	t.Run("Other secret", func(t *testing.T) {
+		_, err := NewFakeProvider("other").VerifyWebhook(payload, header)
+		assert.True(t, errors.Is(err, ErrInvalidSignature))
+	})
+}
+
+func TestFakeProviderRejectsForeignIntents(t *testing.T) {
+	fake := NewFakeProvider("secret")
+	assert.Error(t, fake.Capture(context.Background(), "pi_real", models.MustParseMoney("1.00")))
+	_, err := fake.Refund(context.Background(), "fake_pi_x", models.Money{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPaymentNotFound is returned for events about unknown intents
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrOrderNotPayable is returned when paying an order that is not pending
+	ErrOrderNotPayable = errors.New("order cannot be paid")
+	// ErrNotRefundable is returned when refunding a payment that was not
+	// captured or has been refunded in full
+	ErrNotRefundable = errors.New("payment cannot be refunded")
+	// ErrRefundTooLarge is returned when a refund exceeds what is left of
+	// the payment
+	ErrRefundTooLarge = errors.New("refund exceeds the refundable amount")
+)
+
+// MarkPaidFunc moves an order to paid once its payment has been captured. It
+// runs in the transaction that records the payment.
+type MarkPaidFunc func(tx *gorm.DB, order *models.Order) error
+
+// Start creates a payment intent for the total of a pending order
+func Start(ctx context.Context, tx *gorm.DB, p Provider, order *models.Order) (*models.Payment, error) {
+	if order.Status != models.OrderStatusPending {
+		return nil, ErrOrderNotPayable
+	}
+
+	intent, err := p.CreateIntent(ctx, IntentRequest{OrderID: order.ID, Amount: order.Total, Currency: order.Currency})
+	if err != nil {
+		return nil, fmt.Errorf("creating payment intent: %w", err)
+	}
+
+	payment := &models.Payment{
+		OrderID:      order.ID,
+		Provider:     p.Name(),
+		IntentID:     intent.ID,
+		ClientSecret: intent.ClientSecret,
+		Status:       models.PaymentPending,
+		Amount:       order.Total,
+		Currency:     order.Currency,
+	}
+	if err := tx.Create(payment).Error; err != nil {
+		return nil, fmt.Errorf("recording payment: %w", err)
+	}
+	return payment, nil
+}
+
+// HandleEvent applies a verified webhook event to its payment. An authorised
+// payment is captured and its order marked paid with markPaid, unless the
+// order can no longer be paid or the amount does not match, in which case
+// the payment fails without being captured. Events for payments that are no
+// longer pending are redeliveries and are ignored.
+func HandleEvent(ctx context.Context, tx *gorm.DB, p Provider, event *Event, markPaid MarkPaidFunc) (*models.Payment, error) {
+	var payment models.Payment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("intent_id = ? AND provider = ?", event.IntentID, p.Name()).
+		First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading payment: %w", err)
+	}
+	if payment.Status != models.PaymentPending {
+		return &payment, nil
+	}
+
+	switch event.Type {
+	case EventFailed:
+		return &payment, fail(tx, &payment, event.Reason)
+	case EventAuthorized:
+	default:
+		return &payment, nil
+	}
+
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, payment.OrderID).Error; err != nil {
+		return nil, fmt.Errorf("loading order: %w", err)
+	}
+	if event.Amount.Cmp(payment.Amount) != 0 || event.Currency != payment.Currency {
+		return &payment, fail(tx, &payment, fmt.Sprintf("authorised %s %s, expected %s %s", event.Amount, event.Currency, payment.Amount, payment.Currency))
+	}
+	if order.Status != models.OrderStatusPending {
+		return &payment, fail(tx, &payment, fmt.Sprintf("order is %s", order.Status))
+	}
+
+	// Record the outcome before capturing, so a failure to capture rolls
+	// the order back to pending
+	payment.Status = models.PaymentSucceeded
+	if err := tx.Model(&payment).Update("status", payment.Status).Error; err != nil {
+		return nil, fmt.Errorf("updating payment: %w", err)
+	}
+	if err := markPaid(tx, &order); err != nil {
+		return nil, err
+	}
+	if err := p.Capture(ctx, payment.IntentID, payment.Amount); err != nil {
+		return nil, fmt.Errorf("capturing payment: %w", err)
+	}
+	return &payment, nil
+}
+
+// Refund returns amount of a captured payment to the customer, or whatever
+// is left of it when amount is nil, and records the refund
+func Refund(ctx context.Context, tx *gorm.DB, p Provider, paymentID uint, amount *models.Money) (*models.Refund, error) {
+	var payment models.Payment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading payment: %w", err)
+	}
+	return refund(ctx, tx, p, &payment, amount, nil)
+}
+
+// RefundOrder gives amount of an order's total back and records the refund
+// against returnID, if set. The money goes back through the order's
+// captured payment; orders paid outside the provider, e.g. by bank transfer,
+// get a refund record only and are settled by staff.
+func RefundOrder(ctx context.Context, tx *gorm.DB, p Provider, order *models.Order, amount models.Money, returnID *uint) (*models.Refund, error) {
+	var payment models.Payment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status IN ?", order.ID, []models.PaymentStatus{models.PaymentSucceeded, models.PaymentPartiallyRefunded}).
+		First(&payment).Error
+	if err == nil {
+		return refund(ctx, tx, p, &payment, &amount, returnID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("loading payment: %w", err)
+	}
+
+	record := &models.Refund{
+		OrderID:  order.ID,
+		ReturnID: returnID,
+		Amount:   amount,
+		Currency: order.Currency,
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("recording refund: %w", err)
+	}
+	return record, nil
+}
+
+func refund(ctx context.Context, tx *gorm.DB, p Provider, payment *models.Payment, amount *models.Money, returnID *uint) (*models.Refund, error) {
+	if payment.Status != models.PaymentSucceeded && payment.Status != models.PaymentPartiallyRefunded {
+		return nil, ErrNotRefundable
+	}
+	if payment.Provider != p.Name() {
+		return nil, fmt.Errorf("payment was made with provider %q", payment.Provider)
+	}
+
+	remaining := payment.Amount.Sub(payment.RefundedAmount)
+	value := remaining
+	if amount != nil {
+		value = *amount
+	}
+	if value.IsZero() || value.IsNegative() {
+		return nil, fmt.Errorf("%w: refund must be positive", ErrRefundTooLarge)
+	}
+	if value.Cmp(remaining) > 0 {
+		return nil, fmt.Errorf("%w: %s left", ErrRefundTooLarge, remaining)
+	}
+
+	payment.RefundedAmount = payment.RefundedAmount.Add(value)
+	payment.Status = models.PaymentPartiallyRefunded
+	if payment.RefundedAmount.Cmp(payment.Amount) == 0 {
+		payment.Status = models.PaymentRefunded
+	}
+	if err := tx.Model(payment).Updates(map[string]interface{}{
+		"refunded_amount": payment.RefundedAmount,
+		"status":          payment.Status,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("updating payment: %w", err)
+	}
+
+	reference, err := p.Refund(ctx, payment.IntentID, value)
+	if err != nil {
+		return nil, fmt.Errorf("refunding payment: %w", err)
+	}
+
+	record := &models.Refund{
+		OrderID:           payment.OrderID,
+		ReturnID:          returnID,
+		PaymentID:         &payment.ID,
+		Amount:            value,
+		Currency:          payment.Currency,
+		ProviderReference: reference,
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("recording refund: %w", err)
+	}
+	record.Payment = payment
+	return record, nil
+}
+
+func fail(tx *gorm.DB, payment *models.Payment, reason string) error {
+	payment.Status = models.PaymentFailed
+	payment.FailureReason = reason
+	return tx.Model(payment).Updates(map[string]interface{}{
+		"status":         payment.Status,
+		"failure_reason": reason,
+	}).Error
+}
@@ -0,0 +1,70 @@
+// Package payments connects orders to payment providers. A payment starts as
+// an intent the customer authorises with the provider; the provider then
+// reports the outcome through a signed webhook, and an authorised payment is
+// captured only while its order can still be paid.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+)
+
+// ErrInvalidSignature is returned for webhooks that were not signed by the
+// provider
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// EventType is the kind of payment event a provider reports
+type EventType string
+
+const (
+	// EventAuthorized means the customer authorised the payment; it still
+	// has to be captured
+	EventAuthorized EventType = "payment.authorized"
+	// EventFailed means the payment was declined
+	EventFailed EventType = "payment.failed"
+)
+
+// IntentRequest describes the payment of an order
+type IntentRequest struct {
+	OrderID  uint
+	Amount   models.Money
+	Currency models.Currency
+}
+
+// Intent is a payment created with the provider. ClientSecret lets the
+// customer's client complete the payment with the provider directly.
+type Intent struct {
+	ID           string
+	ClientSecret string
+}
+
+// Event is a verified webhook notification
+type Event struct {
+	ID       string          `json:"id"`
+	Type     EventType       `json:"type"`
+	IntentID string          `json:"intent_id"`
+	Amount   models.Money    `json:"amount"`
+	Currency models.Currency `json:"currency"`
+	Reason   string          `json:"reason,omitempty"`
+}
+
+// Provider is a payment gateway
+type Provider interface {
+	// Name identifies the provider in stored payments
+	Name() string
+	// CreateIntent starts a payment
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// Capture collects an authorised payment. Capturing the same intent
+	// again must not collect twice, since a webhook whose handling failed
+	// after the capture is delivered again.
+	Capture(ctx context.Context, intentID string, amount models.Money) error
+	// Refund returns part or all of a captured payment and returns the
+	// provider's refund reference
+	Refund(ctx context.Context, intentID string, amount models.Money) (string, error)
+	// VerifyWebhook checks the signature of a webhook request and decodes
+	// its event, returning ErrInvalidSignature for forged requests
+	VerifyWebhook(payload []byte, header http.Header) (*Event, error)
+}
@@ -0,0 +1,164 @@
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Apply prices the discounts of an order being placed: every automatic
+// promotion it qualifies for, plus the coupon named by order.CouponCode. It
+// sets the order's Adjustments, Subtotal and Total. Automatic promotions that
+// do not apply are skipped, while a coupon that cannot be used is an error.
+// Promotions with usage limits stay locked until the transaction ends, so
+// the limits hold under concurrent checkouts; call Redeem once the order has
+// been created.
+func Apply(tx *gorm.DB, order *models.Order, basket Basket, now time.Time) error {
+	var automatic []models.Promotion
+	if err := tx.Preload("Targets").
+		Where("code IS NULL AND active").
+		Order("id").
+		Find(&automatic).Error; err != nil {
+		return fmt.Errorf("loading promotions: %w", err)
+	}
+
+	var adjustments []models.OrderAdjustment
+	for i := range automatic {
+		p := &automatic[i]
+		if !Active(p, now) {
+			continue
+		}
+		discounts, err := Discounts(p, basket)
+		if errors.Is(err, ErrNotApplicable) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = checkLimits(tx, p, order.UserID)
+		if errors.Is(err, ErrLimitReached) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		adjustments = append(adjustments, discounts...)
+	}
+
+	if code := NormalizeCode(order.CouponCode); code != "" {
+		var coupon models.Promotion
+		err := tx.Preload("Targets").Where("code = ?", code).First(&coupon).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCouponNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("loading coupon: %w", err)
+		}
+		if !Active(&coupon, now) {
+			return ErrInactive
+		}
+		discounts, err := Discounts(&coupon, basket)
+		if err != nil {
+			return err
+		}
+		if err := checkLimits(tx, &coupon, order.UserID); err != nil {
+			return err
+		}
+		adjustments = append(adjustments, discounts...)
+	}
+
+	order.Adjustments = Cap(adjustments, basket.Lines)
+	order.Subtotal = basket.Subtotal()
+	order.Total = order.Subtotal
+	for _, a := range order.Adjustments {
+		order.Total = order.Total.Add(a.Amount)
+	}
+	return nil
+}
+
+// Redeem records the use of every promotion the order's adjustments came
+// from and counts it against the usage limits
+func Redeem(tx *gorm.DB, order *models.Order) error {
+	for _, id := range promotionIDs(order.Adjustments) {
+		if err := tx.Create(&models.PromotionRedemption{
+			PromotionID: id,
+			UserID:      order.UserID,
+			OrderID:     order.ID,
+		}).Error; err != nil {
+			return fmt.Errorf("recording redemption: %w", err)
+		}
+		if err := tx.Model(&models.Promotion{}).
+			Where("id = ?", id).
+			Update("usage_count", gorm.Expr("usage_count + 1")).Error; err != nil {
+			return fmt.Errorf("counting redemption: %w", err)
+		}
+	}
+	return nil
+}
+
+// Release gives back the uses an order took from its promotions, so that a
+// cancelled order does not count against the limits
+func Release(tx *gorm.DB, orderID uint) error {
+	var redemptions []models.PromotionRedemption
+	if err := tx.Where("order_id = ?", orderID).Order("promotion_id").Find(&redemptions).Error; err != nil {
+		return fmt.Errorf("loading redemptions: %w", err)
+	}
+	for _, r := range redemptions {
+		if err := tx.Model(&models.Promotion{}).
+			Where("id = ? AND usage_count > 0", r.PromotionID).
+			Update("usage_count", gorm.Expr("usage_count - 1")).Error; err != nil {
+			return fmt.Errorf("releasing redemption: %w", err)
+		}
+	}
+	if len(redemptions) == 0 {
+		return nil
+	}
+	return tx.Where("order_id = ?", orderID).Delete(&models.PromotionRedemption{}).Error
+}
+
+// checkLimits locks a promotion that has usage limits and checks that the
+// user may still use it
+func checkLimits(tx *gorm.DB, p *models.Promotion, userID uuid.UUID) error {
+	if p.UsageLimit == nil && p.PerUserLimit == nil {
+		return nil
+	}
+
+	var locked models.Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, p.ID).Error; err != nil {
+		return fmt.Errorf("locking promotion: %w", err)
+	}
+	if locked.UsageLimit != nil && locked.UsageCount >= *locked.UsageLimit {
+		return ErrLimitReached
+	}
+	if locked.PerUserLimit != nil {
+		var used int64
+		if err := tx.Model(&models.PromotionRedemption{}).
+			Where("promotion_id = ? AND user_id = ?", p.ID, userID).
+			Count(&used).Error; err != nil {
+			return fmt.Errorf("counting redemptions: %w", err)
+		}
+		if used >= int64(*locked.PerUserLimit) {
+			return fmt.Errorf("%w for this customer", ErrLimitReached)
+		}
+	}
+	return nil
+}
+
+// promotionIDs lists the distinct promotions of the adjustments in order
+func promotionIDs(adjustments []models.OrderAdjustment) []uint {
+	seen := map[uint]bool{}
+	var ids []uint
+	for _, a := range adjustments {
+		if a.PromotionID != nil && !seen[*a.PromotionID] {
+			seen[*a.PromotionID] = true
+			ids = append(ids, *a.PromotionID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,267 @@
+// Package promotions prices coupons and automatic promotions into order
+// adjustment lines. The engine in this file is pure: it works on a Basket and
+// never touches the database, which apply.go does.
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+)
+
+var (
+	// ErrCouponNotFound is returned for a coupon code that does not exist
+	ErrCouponNotFound = errors.New("coupon not found")
+	// ErrInactive is returned for a promotion outside its validity window or
+	// switched off
+	ErrInactive = errors.New("promotion is not active")
+	// ErrNotApplicable is returned when the order does not qualify
+	ErrNotApplicable = errors.New("promotion does not apply to this order")
+	// ErrLimitReached is returned when a usage limit has been used up
+	ErrLimitReached = errors.New("promotion usage limit reached")
+)
+
+// Line is an order line as seen by the engine. Price is the unit price in
+// the order currency and BasePrice the same in the base currency.
+type Line struct {
+	ProductID uint
+	SKU       string
+	Category  string
+	Quantity  int
+	Price     models.Money
+	BasePrice models.Money
+}
+
+// Total returns the line total in the order currency
+func (l Line) Total() models.Money {
+	return l.Price.Mul(l.Quantity)
+}
+
+// Basket is an order being priced. Rate is the order's exchange rate, used to
+// convert base currency amounts into the order currency.
+type Basket struct {
+	Lines []Line
+	Rate  models.Rate
+}
+
+// Subtotal returns the sum of the lines in the order currency
+func (b Basket) Subtotal() models.Money {
+	var total models.Money
+	for _, l := range b.Lines {
+		total = total.Add(l.Total())
+	}
+	return total
+}
+
+// BaseSubtotal returns the sum of the lines in the base currency
+func (b Basket) BaseSubtotal() models.Money {
+	var total models.Money
+	for _, l := range b.Lines {
+		total = total.Add(l.BasePrice.Mul(l.Quantity))
+	}
+	return total
+}
+
+// NormalizeCode returns the stored form of a coupon code
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Active reports whether the promotion can be used at the given time. Usage
+// limits are checked separately since they need the database.
+func Active(p *models.Promotion, now time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// Validate checks that a promotion is complete and consistent before it is
+// stored
+func Validate(p *models.Promotion) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name is required")
+	}
+	if p.Code != nil && NormalizeCode(*p.Code) == "" {
+		return errors.New("code must not be blank")
+	}
+
+	switch p.Type {
+	case models.PromotionPercentage:
+		if p.Percent < 1 || p.Percent > 100 {
+			return errors.New("percent must be between 1 and 100")
+		}
+	case models.PromotionFixedAmount:
+		if p.Amount.IsNegative() || p.Amount.IsZero() {
+			return errors.New("amount must be positive")
+		}
+	case models.PromotionBuyXGetY:
+		if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+			return errors.New("buy_quantity and get_quantity must be at least 1")
+		}
+	default:
+		return fmt.Errorf("unknown promotion type %q", p.Type)
+	}
+
+	if p.MinBasket.IsNegative() {
+		return errors.New("min_basket must not be negative")
+	}
+	if p.UsageLimit != nil && *p.UsageLimit < 1 {
+		return errors.New("usage_limit must be at least 1")
+	}
+	if p.PerUserLimit != nil && *p.PerUserLimit < 1 {
+		return errors.New("per_user_limit must be at least 1")
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	for _, t := range p.Targets {
+		if t.Kind != models.PromotionTargetSKU && t.Kind != models.PromotionTargetCategory {
+			return fmt.Errorf("unknown target kind %q", t.Kind)
+		}
+		if strings.TrimSpace(t.Value) == "" {
+			return errors.New("target value is required")
+		}
+	}
+	return nil
+}
+
+// Discounts returns the discount lines the promotion gives the basket, one
+// per discounted product. It does not check the validity window or usage
+// limits.
+func Discounts(p *models.Promotion, b Basket) ([]models.OrderAdjustment, error) {
+	eligible := eligibleLines(p, b.Lines)
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("%w: no eligible products", ErrNotApplicable)
+	}
+	if b.BaseSubtotal().Cmp(p.MinBasket) < 0 {
+		return nil, fmt.Errorf("%w: order is below the minimum of %s %s", ErrNotApplicable, p.MinBasket, models.BaseCurrency)
+	}
+
+	amounts := make([]models.Money, len(eligible))
+	switch p.Type {
+	case models.PromotionPercentage:
+		for i, l := range eligible {
+			amounts[i] = l.Total().Percent(p.Percent)
+		}
+	case models.PromotionFixedAmount:
+		amounts = allocate(p.Amount.Convert(models.RateOne, b.Rate), eligible)
+	case models.PromotionBuyXGetY:
+		group := p.BuyQuantity + p.GetQuantity
+		for i, l := range eligible {
+			amounts[i] = l.Price.Mul(l.Quantity / group * p.GetQuantity)
+		}
+	default:
+		return nil, fmt.Errorf("unknown promotion type %q", p.Type)
+	}
+
+	var adjustments []models.OrderAdjustment
+	for i, l := range eligible {
+		if amounts[i].IsZero() {
+			continue
+		}
+		adjustments = append(adjustments, discount(p, l.ProductID, amounts[i]))
+	}
+	if len(adjustments) == 0 {
+		return nil, fmt.Errorf("%w: not enough eligible items", ErrNotApplicable)
+	}
+	return adjustments, nil
+}
+
+// Cap trims discounts so that no product is discounted below zero. Earlier
+// adjustments take precedence over later ones.
+func Cap(adjustments []models.OrderAdjustment, lines []Line) []models.OrderAdjustment {
+	remaining := make(map[uint]models.Money, len(lines))
+	for _, l := range lines {
+		remaining[l.ProductID] = remaining[l.ProductID].Add(l.Total())
+	}
+
+	capped := adjustments[:0]
+	for _, a := range adjustments {
+		if a.ProductID == nil {
+			capped = append(capped, a)
+			continue
+		}
+		left := remaining[*a.ProductID]
+		// Amounts are negative, so the discount is -a.Amount
+		if models.MoneyFromCents(-a.Amount.Cents()).Cmp(left) > 0 {
+			a.Amount = models.MoneyFromCents(-left.Cents())
+		}
+		if a.Amount.IsZero() {
+			continue
+		}
+		remaining[*a.ProductID] = left.Add(a.Amount)
+		capped = append(capped, a)
+	}
+	return capped
+}
+
+// eligibleLines returns the lines the promotion's targets match; a promotion
+// without targets matches every line
+func eligibleLines(p *models.Promotion, lines []Line) []Line {
+	if len(p.Targets) == 0 {
+		return lines
+	}
+	var eligible []Line
+	for _, l := range lines {
+		for _, t := range p.Targets {
+			if (t.Kind == models.PromotionTargetSKU && strings.EqualFold(t.Value, l.SKU)) ||
+				(t.Kind == models.PromotionTargetCategory && l.Category != "" && strings.EqualFold(t.Value, l.Category)) {
+				eligible = append(eligible, l)
+				break
+			}
+		}
+	}
+	return eligible
+}
+
+// allocate spreads amount over the lines in proportion to their totals,
+// capped at their sum. The rounding remainder goes to the last line.
+func allocate(amount models.Money, lines []Line) []models.Money {
+	var total models.Money
+	for _, l := range lines {
+		total = total.Add(l.Total())
+	}
+	if amount.Cmp(total) > 0 {
+		amount = total
+	}
+
+	shares := make([]models.Money, len(lines))
+	if total.IsZero() {
+		return shares
+	}
+	left := amount
+	for i, l := range lines {
+		if i == len(lines)-1 {
+			shares[i] = left
+			break
+		}
+		shares[i] = models.MoneyFromCents(amount.Cents() * l.Total().Cents() / total.Cents())
+		left = left.Sub(shares[i])
+	}
+	return shares
+}
+
+func discount(p *models.Promotion, productID uint, amount models.Money) models.OrderAdjustment {
+	promotionID := p.ID
+	a := models.OrderAdjustment{
+		Kind:        models.AdjustmentDiscount,
+		PromotionID: &promotionID,
+		ProductID:   &productID,
+		Description: p.Name,
+		Amount:      models.MoneyFromCents(-amount.Cents()),
+	}
+	if p.Code != nil {
+		a.Code = *p.Code
+	}
+	return a
+}
@@ -0,0 +1,178 @@
+package promotions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func money(s string) models.Money {
+	return models.MustParseMoney(s)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// basket has a 3 x 10.00 book and a 1 x 50.00 lamp, priced in the base
+// currency
+func basket() Basket {
+	return Basket{
+		Rate: models.RateOne,
+		Lines: []Line{
+			{ProductID: 1, SKU: "BOOK-1", Category: "books", Quantity: 3, Price: money("10.00"), BasePrice: money("10.00")},
+			{ProductID: 2, SKU: "LAMP-1", Category: "home", Quantity: 1, Price: money("50.00"), BasePrice: money("50.00")},
+		},
+	}
+}
+
+// total sums the adjustment amounts
+func total(adjustments []models.OrderAdjustment) models.Money {
+	var sum models.Money
+	for _, a := range adjustments {
+		sum = sum.Add(a.Amount)
+	}
+	return sum
+}
+
+func TestPercentageDiscount(t *testing.T) {
+	p := &models.Promotion{ID: 7, Name: "Ten off", Type: models.PromotionPercentage, Percent: 10}
+
+	adjustments, err := Discounts(p, basket())
+	require.NoError(t, err)
+	require.Len(t, adjustments, 2)
+	assert.Equal(t, money("-3.00"), adjustments[0].Amount)
+	assert.Equal(t, uint(1), *adjustments[0].ProductID)
+	assert.Equal(t, money("-5.00"), adjustments[1].Amount)
+	assert.Equal(t, uint(7), *adjustments[1].PromotionID)
+	assert.Equal(t, models.AdjustmentDiscount, adjustments[1].Kind)
+}
+
+func TestFixedAmountIsSpreadAndConverted(t *testing.T) {
+	p := &models.Promotion{Name: "Twenty off", Type: models.PromotionFixedAmount, Amount: money("20.00")}
+
+	adjustments, err := Discounts(p, basket())
+	require.NoError(t, err)
+	require.Len(t, adjustments, 2)
+	// 30.00 and 50.00 of an 80.00 basket
+	assert.Equal(t, money("-7.50"), adjustments[0].Amount)
+	assert.Equal(t, money("-12.50"), adjustments[1].Amount)
+
+	// In an order placed at 100.00 base per unit, 20.00 base is 0.20
+	b := basket()
+	b.Rate = models.MustParseRate("100")
+	adjustments, err = Discounts(p, b)
+	require.NoError(t, err)
+	assert.Equal(t, money("-0.20"), total(adjustments))
+
+	// The amount never exceeds the eligible lines
+	p.Amount = money("500.00")
+	adjustments, err = Discounts(p, basket())
+	require.NoError(t, err)
+	assert.Equal(t, money("-80.00"), total(adjustments))
+}
+
+func TestBuyXGetY(t *testing.T) {
+	p := &models.Promotion{
+		Name:        "Buy two books get one free",
+		Type:        models.PromotionBuyXGetY,
+		BuyQuantity: 2,
+		GetQuantity: 1,
+		Targets:     []models.PromotionTarget{{Kind: models.PromotionTargetCategory, Value: "Books"}},
+	}
+
+	adjustments, err := Discounts(p, basket())
+	require.NoError(t, err)
+	require.Len(t, adjustments, 1)
+	assert.Equal(t, money("-10.00"), adjustments[0].Amount)
+
+	b := basket()
+	b.Lines[0].Quantity = 2
+	_, err = Discounts(p, b)
+	assert.True(t, errors.Is(err, ErrNotApplicable))
+}
+
+func TestTargetsAndMinimumBasket(t *testing.T) {
+	p := &models.Promotion{
+		Name:    "Lamp deal",
+		Type:    models.PromotionPercentage,
+		Percent: 50,
+		Targets: []models.PromotionTarget{{Kind: models.PromotionTargetSKU, Value: "lamp-1"}},
+	}
+
+	adjustments, err := Discounts(p, basket())
+	require.NoError(t, err)
+	require.Len(t, adjustments, 1)
+	assert.Equal(t, uint(2), *adjustments[0].ProductID)
+	assert.Equal(t, money("-25.00"), adjustments[0].Amount)
+
+	p.MinBasket = money("80.01")
+	_, err = Discounts(p, basket())
+	assert.True(t, errors.Is(err, ErrNotApplicable))
+
+	p.MinBasket = money("80.00")
+	_, err = Discounts(p, basket())
+	assert.NoError(t, err)
+
+	p.Targets = []models.PromotionTarget{{Kind: models.PromotionTargetCategory, Value: "garden"}}
+	_, err = Discounts(p, basket())
+	assert.True(t, errors.Is(err, ErrNotApplicable))
+}
+
+func TestCapKeepsLinesAtZeroOrAbove(t *testing.T) {
+	half := &models.Promotion{ID: 1, Name: "Half", Type: models.PromotionPercentage, Percent: 50}
+	all := &models.Promotion{ID: 2, Name: "All", Type: models.PromotionPercentage, Percent: 100}
+
+	first, err := Discounts(half, basket())
+	require.NoError(t, err)
+	second, err := Discounts(all, basket())
+	require.NoError(t, err)
+
+	capped := Cap(append(first, second...), basket().Lines)
+	assert.Equal(t, money("-80.00"), total(capped))
+	require.Len(t, capped, 4)
+	assert.Equal(t, money("-15.00"), capped[2].Amount)
+}
+
+func TestActive(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	assert.True(t, Active(&models.Promotion{Active: true}, now))
+	assert.False(t, Active(&models.Promotion{Active: false}, now))
+	assert.False(t, Active(&models.Promotion{Active: true, StartsAt: &after}, now))
+	assert.False(t, Active(&models.Promotion{Active: true, EndsAt: &now}, now))
+	assert.True(t, Active(&models.Promotion{Active: true, StartsAt: &before, EndsAt: &after}, now))
+}
+
+func TestValidate(t *testing.T) {
+	valid := models.Promotion{Name: "Ten off", Type: models.PromotionPercentage, Percent: 10}
+	assert.NoError(t, Validate(&valid))
+
+	blank := ""
+	now := time.Now()
+	invalid := map[string]func(p *models.Promotion){
+		"missing name":    func(p *models.Promotion) { p.Name = " " },
+		"blank code":      func(p *models.Promotion) { p.Code = &blank },
+		"unknown type":    func(p *models.Promotion) { p.Type = "free_shipping" },
+		"percent too big": func(p *models.Promotion) { p.Percent = 101 },
+		"zero amount":     func(p *models.Promotion) { p.Type = models.PromotionFixedAmount },
+		"zero buy":        func(p *models.Promotion) { p.Type = models.PromotionBuyXGetY; p.GetQuantity = 1 },
+		"zero limit":      func(p *models.Promotion) { p.UsageLimit = intPtr(0) },
+		"zero user limit": func(p *models.Promotion) { p.PerUserLimit = intPtr(0) },
+		"empty window":    func(p *models.Promotion) { p.StartsAt, p.EndsAt = &now, &now },
+		"unknown target": func(p *models.Promotion) {
+			p.Targets = []models.PromotionTarget{{Kind: "brand", Value: "acme"}}
+		},
+	}
+	for name, mutate := range invalid {
+		p := valid
+		mutate(&p)
+		assert.Error(t, Validate(&p), name)
+	}
+}
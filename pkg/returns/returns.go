@@ -0,0 +1,269 @@
+// Package returns implements returns (RMAs) of delivered orders. A customer
+// requests the return of quantities of order items, staff approve or reject
+// the request, and once the goods are received they may go back into stock
+// and the refund is made. The order follows to partially_refunded and, when
+// every item has come back, refunded.
+package returns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/inventory"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/payments"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound is returned for unknown returns
+	ErrNotFound = errors.New("return not found")
+	// ErrOrderNotReturnable is returned for orders that were not delivered
+	// or have been refunded in full
+	ErrOrderNotReturnable = errors.New("order cannot be returned")
+	// ErrInvalidItem is returned for items that are not part of the order or
+	// quantities above what is left to return
+	ErrInvalidItem = errors.New("invalid return item")
+	// ErrInvalidStatus is returned when a return is not in the state an
+	// action needs
+	ErrInvalidStatus = errors.New("invalid return status")
+)
+
+// ItemRequest asks to return Quantity units of an order item
+type ItemRequest struct {
+	OrderItemID uint
+	Quantity    int
+}
+
+// SetStatusFunc moves an order to a refund status. It runs in the
+// transaction that completes the return.
+type SetStatusFunc func(tx *gorm.DB, order *models.Order, status models.OrderStatus) error
+
+// Request records a return of items of a delivered order. Every item's
+// Amount is the part of its line's gross total, discounts included, that
+// the returned units are worth. The order must be locked by the caller so
+// concurrent requests cannot return the same units twice.
+func Request(tx *gorm.DB, order *models.Order, userID uuid.UUID, reason string, items []ItemRequest) (*models.Return, error) {
+	if order.Status != models.OrderStatusDelivered && order.Status != models.OrderStatusPartiallyRefunded {
+		return nil, ErrOrderNotReturnable
+	}
+
+	lines, err := orderItems(tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	returned, err := returnedQuantities(tx, order.ID, models.ReturnRequested, models.ReturnApproved, models.ReturnCompleted)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &models.Return{
+		OrderID: order.ID,
+		UserID:  userID,
+		Status:  models.ReturnRequested,
+		Reason:  reason,
+	}
+	requested := map[uint]int{}
+	for _, req := range items {
+		line, ok := lines[req.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("%w: order item %d is not part of order %d", ErrInvalidItem, req.OrderItemID, order.ID)
+		}
+		before := returned[line.ID] + requested[line.ID]
+		if req.Quantity < 1 || before+req.Quantity > line.Quantity {
+			return nil, fmt.Errorf("%w: %d of order item %d left to return, %d requested",
+				ErrInvalidItem, line.Quantity-before, line.ID, req.Quantity)
+		}
+		requested[line.ID] += req.Quantity
+
+		ret.Items = append(ret.Items, models.ReturnItem{
+			OrderItemID: line.ID,
+			ProductID:   line.ProductID,
+			Quantity:    req.Quantity,
+			Amount:      LineRefund(line, before, req.Quantity),
+		})
+	}
+
+	if err := tx.Create(ret).Error; err != nil {
+		return nil, fmt.Errorf("recording return: %w", err)
+	}
+	return ret, nil
+}
+
+// Approve accepts a requested return; the customer may send the goods back
+func Approve(tx *gorm.DB, ret *models.Return, resolution string) error {
+	return resolve(tx, ret, models.ReturnApproved, resolution, models.ReturnRequested)
+}
+
+// Reject turns down a requested or approved return
+func Reject(tx *gorm.DB, ret *models.Return, resolution string) error {
+	return resolve(tx, ret, models.ReturnRejected, resolution, models.ReturnRequested, models.ReturnApproved)
+}
+
+// Complete settles an approved return once its goods have arrived: restock
+// puts them back into stock, the refund is made through the order's payment
+// and the order moves to partially_refunded, or refunded when nothing is
+// left to return, through setStatus.
+func Complete(ctx context.Context, tx *gorm.DB, p payments.Provider, ret *models.Return, restock bool, setStatus SetStatusFunc) error {
+	if ret.Status != models.ReturnApproved {
+		return fmt.Errorf("%w: return is %s", ErrInvalidStatus, ret.Status)
+	}
+
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, ret.OrderID).Error; err != nil {
+		return fmt.Errorf("loading order: %w", err)
+	}
+	lines, err := orderItems(tx, order.ID)
+	if err != nil {
+		return err
+	}
+	completed, err := returnedQuantities(tx, order.ID, models.ReturnCompleted)
+	if err != nil {
+		return err
+	}
+
+	// Price the items again against the returns completed so far, so the
+	// refunds of all returns of a line add up to its gross total exactly
+	var total models.Money
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		line := lines[item.OrderItemID]
+		item.Amount = LineRefund(line, completed[line.ID], item.Quantity)
+		completed[line.ID] += item.Quantity
+		total = total.Add(item.Amount)
+
+		if err := tx.Model(item).Update("amount", item.Amount).Error; err != nil {
+			return fmt.Errorf("updating return item: %w", err)
+		}
+		if restock {
+			if err := inventory.Restock(tx, item.ProductID, item.Quantity); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Fully discounted items are worth nothing and need no refund
+	if total.Cmp(models.Money{}) > 0 {
+		refund, err := payments.RefundOrder(ctx, tx, p, &order, total, &ret.ID)
+		if err != nil {
+			return err
+		}
+		ret.Refund = refund
+	}
+
+	ret.Restocked = restock
+	if err := tx.Model(ret).Update("restocked", restock).Error; err != nil {
+		return fmt.Errorf("updating return: %w", err)
+	}
+	if err := resolve(tx, ret, models.ReturnCompleted, ret.Resolution, models.ReturnApproved); err != nil {
+		return err
+	}
+
+	status := models.OrderStatusRefunded
+	for _, line := range lines {
+		if completed[line.ID] < line.Quantity {
+			status = models.OrderStatusPartiallyRefunded
+			break
+		}
+	}
+	if status == order.Status {
+		return nil
+	}
+	return setStatus(tx, &order, status)
+}
+
+// Load returns a return with its items and refund, locking it for update
+// when tx is a transaction that will change it
+func Load(tx *gorm.DB, id uint, lock bool) (*models.Return, error) {
+	query := tx.Preload("Items").Preload("Refund")
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+
+	var ret models.Return
+	err := query.First(&ret, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading return: %w", err)
+	}
+	return &ret, nil
+}
+
+// LineRefund is the share of an order line's gross total that quantity
+// units are worth when before units of the line were returned already. The
+// shares are cut from the running total, so returning the whole line in any
+// number of steps refunds exactly its gross total.
+func LineRefund(line models.OrderItem, before, quantity int) models.Money {
+	if line.Quantity == 0 {
+		return models.Money{}
+	}
+	gross := line.Gross.Cents()
+	upTo := func(n int) int64 { return gross * int64(n) / int64(line.Quantity) }
+	return models.MoneyFromCents(upTo(before+quantity) - upTo(before))
+}
+
+func resolve(tx *gorm.DB, ret *models.Return, to models.ReturnStatus, resolution string, from ...models.ReturnStatus) error {
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || ret.Status == s
+	}
+	if !allowed {
+		return fmt.Errorf("%w: return is %s", ErrInvalidStatus, ret.Status)
+	}
+
+	now := time.Now()
+	ret.Status = to
+	ret.Resolution = resolution
+	ret.ResolvedAt = &now
+	if err := tx.Model(ret).Updates(map[string]interface{}{
+		"status":      ret.Status,
+		"resolution":  ret.Resolution,
+		"resolved_at": ret.ResolvedAt,
+	}).Error; err != nil {
+		return fmt.Errorf("updating return: %w", err)
+	}
+	return nil
+}
+
+// orderItems returns the items of an order by ID
+func orderItems(tx *gorm.DB, orderID uint) (map[uint]models.OrderItem, error) {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", orderID).Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("loading order items: %w", err)
+	}
+	lines := make(map[uint]models.OrderItem, len(items))
+	for _, item := range items {
+		lines[item.ID] = item
+	}
+	return lines, nil
+}
+
+// returnedQuantities sums the quantities of the order's returns in the given
+// states per order item
+func returnedQuantities(tx *gorm.DB, orderID uint, statuses ...models.ReturnStatus) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := tx.Table("return_items ri").
+		Select("ri.order_item_id, SUM(ri.quantity) AS quantity").
+		Joins("JOIN returns r ON r.id = ri.return_id").
+		Where("r.order_id = ? AND r.status IN ?", orderID, statuses).
+		Group("ri.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("loading returned quantities: %w", err)
+	}
+	returned := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		returned[row.OrderItemID] = row.Quantity
+	}
+	return returned, nil
+}
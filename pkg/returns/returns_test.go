@@ -0,0 +1,26 @@
+package returns
+
+import (
+	"testing"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineRefund(t *testing.T) {
+	line := models.OrderItem{Quantity: 3, Gross: models.MustParseMoney("10.00")}
+
+	assert.Equal(t, models.MustParseMoney("3.33"), LineRefund(line, 0, 1))
+	assert.Equal(t, models.MustParseMoney("6.66"), LineRefund(line, 0, 2))
+	assert.Equal(t, models.MustParseMoney("10.00"), LineRefund(line, 0, 3))
+
+	// Returning the line one unit at a time adds up to its gross total
+	var total models.Money
+	for before := 0; before < line.Quantity; before++ {
+		total = total.Add(LineRefund(line, before, 1))
+	}
+	assert.Equal(t, line.Gross, total)
+
+	assert.True(t, LineRefund(models.OrderItem{}, 0, 1).IsZero())
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// policy describes who may call a route
+type policy struct {
+	authenticated bool
+	optionalAuth  bool
+	permission    models.Permission
+	idempotent    bool
+}
+
+var (
+	// public routes need no token
+	public = policy{}
+	// authenticated routes need a valid token; ownership checks are left to
+	// the handler
+	authenticated = policy{authenticated: true}
+	// optionalAuth routes serve anonymous callers but identify the user when
+	// a token is sent
+	optionalAuth = policy{optionalAuth: true}
+)
+
+// requires returns a policy for routes that need a specific permission
+func requires(permission models.Permission) policy {
+	return policy{authenticated: true, permission: permission}
+}
+
+// withIdempotency returns a copy of the policy that also honours the
+// Idempotency-Key header, for routes clients are expected to retry
+func (p policy) withIdempotency() policy {
+	p.idempotent = true
+	return p
+}
+
+// policyMiddleware holds the configured middleware policies are built from
+type policyMiddleware struct {
+	authRequired gin.HandlerFunc
+	authOptional gin.HandlerFunc
+	idempotency  gin.HandlerFunc
+}
+
+// chain builds the middleware chain enforcing the policy in front of handler
+func (p policy) chain(m policyMiddleware, handler gin.HandlerFunc) []gin.HandlerFunc {
+	var chain []gin.HandlerFunc
+	switch {
+	case p.authenticated:
+		chain = append(chain, m.authRequired)
+	case p.optionalAuth:
+		chain = append(chain, m.authOptional)
+	}
+	if p.permission != "" {
+		chain = append(chain, middleware.RequirePermission(p.permission))
+	}
+	if p.idempotent {
+		chain = append(chain, m.idempotency)
+	}
+	return append(chain, handler)
+}
+
+// route is a single entry of the API policy table
+type route struct {
+	method  string
+	path    string
+	policy  policy
+	handler gin.HandlerFunc
+}
@@ -1,21 +1,35 @@
 package router
 
 import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/database"
 	"github.com/alzarasatken/FullStackTest/pkg/handlers"
+	"github.com/alzarasatken/FullStackTest/pkg/inventory"
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/numbering"
+	"github.com/alzarasatken/FullStackTest/pkg/payments"
+	"github.com/alzarasatken/FullStackTest/pkg/shipping"
+	"github.com/alzarasatken/FullStackTest/pkg/utils"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-// SetupRouter configures the Gin router
-func SetupRouter() *gin.Engine {
+// SetupRouter configures the Gin router. Payments are taken through
+// paymentProvider and every order status change goes through machine.
+func SetupRouter(paymentProvider payments.Provider, machine *fsm.Machine) *gin.Engine {
 	router := gin.Default()
 
 	// Enable CORS
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Cart-Token")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -24,52 +38,140 @@ func SetupRouter() *gin.Engine {
 	})
 
 	// Create handlers
+	secretKey := os.Getenv("JWT_SECRET")
 	userHandler := handlers.NewUserHandler()
+	authHandler := handlers.NewAuthHandler(
+		secretKey,
+		utils.DurationFromEnv("JWT_EXPIRATION", 15*time.Minute),
+		utils.DurationFromEnv("JWT_REFRESH_EXPIRATION", 30*24*time.Hour),
+	)
+	orderHandler := handlers.NewOrderHandler(machine)
+	paymentHandler := handlers.NewPaymentHandler(paymentProvider, machine)
+	returnHandler := handlers.NewReturnHandler(paymentProvider, machine)
+	// The fake carrier is the only one so far; its parcels arrive after
+	// SHIPPING_FAKE_TRANSIT
+	shipmentHandler := handlers.NewShipmentHandler(shipping.NewFakeCarrier(utils.DurationFromEnv("SHIPPING_FAKE_TRANSIT", shipping.DefaultFakeTransit)), machine)
+	mw := policyMiddleware{
+		authRequired: middleware.AuthMiddleware(secretKey),
+		authOptional: middleware.OptionalAuthMiddleware(secretKey),
+		idempotency:  middleware.Idempotency(database.DB, utils.DurationFromEnv("IDEMPOTENCY_TTL", 24*time.Hour)),
+	}
+	handlers.SetReservationTTL(utils.DurationFromEnv("STOCK_RESERVATION_TTL", inventory.DefaultTTL))
+	handlers.SetTaxRegion(os.Getenv("TAX_REGION"))
+	handlers.SetOrderNumbering(orderNumberingFromEnv())
 
-	// API routes
-	api := router.Group("/api")
-	{
-		// User routes
-		users := api.Group("/users")
-		{
-			users.GET("", userHandler.GetUsers)
-			users.GET("/:id", userHandler.GetUser)
-			users.POST("", userHandler.CreateUser)
-			users.PUT("/:id", userHandler.UpdateUser)
-			users.DELETE("/:id", userHandler.DeleteUser)
-		}
+	// API routes. Every route is declared in the policy table below so the
+	// access rules for the whole API can be reviewed in one place. Routes
+	// marked withIdempotency replay their response to retries that reuse an
+	// Idempotency-Key.
+	routes := []route{
+		// Auth
+		{"POST", "/auth/login", public, authHandler.Login},
+		{"POST", "/auth/refresh", public, authHandler.Refresh},
+		{"POST", "/auth/logout", public, authHandler.Logout},
 
-		// Product routes
-		products := api.Group("/products")
-		{
-			products.GET("", handlers.GetProducts)
-			products.GET("/:id", handlers.GetProduct)
-			products.POST("", handlers.CreateProduct)
-			products.PUT("/:id", handlers.UpdateProduct)
-			products.DELETE("/:id", handlers.DeleteProduct)
-			products.PUT("/:id/stock", handlers.UpdateStock)
-		}
+		// Users; registration is public, users may read and edit themselves
+		{"GET", "/users", requires(models.PermissionUsersManage), userHandler.GetUsers},
+		{"GET", "/users/with-orders", requires(models.PermissionUsersManage), handlers.GetUsersWithOrders},
+		{"GET", "/users/:id", authenticated, userHandler.GetUser},
+		{"GET", "/users/:id/orders/summary", authenticated, handlers.GetUserOrderSummary},
+		{"POST", "/users", public.withIdempotency(), userHandler.CreateUser},
+		{"PUT", "/users/:id", authenticated, userHandler.UpdateUser},
+		{"PUT", "/users/:id/role", requires(models.PermissionUsersManage), userHandler.UpdateUserRole},
+		{"DELETE", "/users/:id", requires(models.PermissionUsersManage), userHandler.DeleteUser},
 
-		// Order routes
-		orders := api.Group("/orders")
-		{
-			orders.GET("", handlers.GetOrders)
-			orders.GET("/:id", handlers.GetOrder)
-			orders.POST("", handlers.CreateOrder)
-			orders.PUT("/:id/status", handlers.UpdateOrderStatus)
-			orders.POST("/:id/cancel", handlers.CancelOrder)
-		}
+		// Products; the catalogue is readable without a token
+		{"GET", "/products", public, handlers.GetProducts},
+		{"GET", "/products/:id", public, handlers.GetProduct},
+		{"POST", "/products", requires(models.PermissionProductsWrite), handlers.CreateProduct},
+		{"PUT", "/products/:id", requires(models.PermissionProductsWrite), handlers.UpdateProduct},
+		{"DELETE", "/products/:id", requires(models.PermissionProductsDelete), handlers.DeleteProduct},
+		{"PUT", "/products/:id/stock", requires(models.PermissionStockWrite), handlers.UpdateStock},
+
+		// Exchange rates; history is public like the catalogue prices it converts
+		{"GET", "/exchange-rates", public, handlers.GetExchangeRates},
+		{"POST", "/exchange-rates", requires(models.PermissionRatesWrite), handlers.CreateExchangeRate},
+
+		// Tax rates; public like the prices they are contained in
+		{"GET", "/tax-rates", public, handlers.GetTaxRates},
+		{"PUT", "/tax-rates", requires(models.PermissionTaxRatesWrite), handlers.SetTaxRate},
+		{"DELETE", "/tax-rates/:id", requires(models.PermissionTaxRatesWrite), handlers.DeleteTaxRate},
+
+		// Promotions and coupons; codes are secret, so reads are staff only too
+		{"GET", "/promotions", requires(models.PermissionPromotions), handlers.GetPromotions},
+		{"GET", "/promotions/:id", requires(models.PermissionPromotions), handlers.GetPromotion},
+		{"POST", "/promotions", requires(models.PermissionPromotions), handlers.CreatePromotion},
+		{"PUT", "/promotions/:id", requires(models.PermissionPromotions), handlers.UpdatePromotion},
+		{"DELETE", "/promotions/:id", requires(models.PermissionPromotions), handlers.DeletePromotion},
+
+		// Orders; customers are limited to their own orders inside the handlers
+		{"GET", "/orders", authenticated, handlers.GetOrders},
+		{"GET", "/orders/:id", authenticated, handlers.GetOrder},
+		{"GET", "/orders/:id/history", authenticated, handlers.GetOrderHistory},
+		{"GET", "/orders/:id/invoice.pdf", authenticated, handlers.GetInvoice},
+		{"GET", "/orders/:id/packing-slip.pdf", requires(models.PermissionOrdersReadAll), handlers.GetPackingSlip},
+		{"POST", "/orders", authenticated.withIdempotency(), handlers.CreateOrder},
+		{"PUT", "/orders/:id/status", requires(models.PermissionOrdersManage), orderHandler.UpdateOrderStatus},
+		{"POST", "/orders/:id/cancel", authenticated.withIdempotency(), orderHandler.CancelOrder},
+		{"GET", "/orders/:id/payments", authenticated, paymentHandler.GetOrderPayments},
+		{"POST", "/orders/:id/payments", authenticated.withIdempotency(), paymentHandler.CreatePayment},
+		{"GET", "/orders/:id/shipments", authenticated, shipmentHandler.GetOrderShipments},
+		{"POST", "/orders/:id/shipments", requires(models.PermissionOrdersManage).withIdempotency(), shipmentHandler.CreateShipment},
+		{"GET", "/orders/:id/returns", authenticated, returnHandler.GetOrderReturns},
+		{"POST", "/orders/:id/returns", authenticated.withIdempotency(), returnHandler.CreateReturn},
+
+		// Payments; the webhook is authenticated by the provider's signature
+		{"POST", "/payments/webhook", public, paymentHandler.Webhook},
+		{"POST", "/payments/:id/refund", requires(models.PermissionOrdersManage).withIdempotency(), paymentHandler.RefundPayment},
+
+		// Returns; customers request them on their orders, staff review them
+		{"GET", "/returns", requires(models.PermissionOrdersReadAll), returnHandler.GetReturns},
+		{"GET", "/returns/:id", authenticated, returnHandler.GetReturn},
+		{"POST", "/returns/:id/approve", requires(models.PermissionOrdersManage), returnHandler.ApproveReturn},
+		{"POST", "/returns/:id/reject", requires(models.PermissionOrdersManage), returnHandler.RejectReturn},
+		{"POST", "/returns/:id/receive", requires(models.PermissionOrdersManage).withIdempotency(), returnHandler.ReceiveReturn},
+
+		// Cart; visitors keep an anonymous cart identified by the X-Cart-Token
+		// header until they log in, checkout needs an account
+		{"GET", "/cart", optionalAuth, handlers.GetCart},
+		{"POST", "/cart/items", optionalAuth, handlers.AddCartItem},
+		{"PUT", "/cart/items/:product_id", optionalAuth, handlers.UpdateCartItem},
+		{"DELETE", "/cart/items/:product_id", optionalAuth, handlers.RemoveCartItem},
+		{"POST", "/cart/checkout", authenticated.withIdempotency(), handlers.CheckoutCart},
 
 		// Health check
-		api.GET("/health", func(c *gin.Context) {
+		{"GET", "/health", public, func(c *gin.Context) {
 			c.JSON(200, gin.H{
 				"status": "ok",
 			})
-		})
+		}},
+	}
+
+	api := router.Group("/api")
+	for _, r := range routes {
+		api.Handle(r.method, r.path, r.policy.chain(mw, r.handler)...)
 	}
 
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	return router
-} 
\ No newline at end of file
+}
+
+// orderNumberingFromEnv reads the order number format from
+// ORDER_NUMBER_PREFIX, ORDER_NUMBER_PADDING and ORDER_NUMBER_YEARLY_RESET,
+// keeping the defaults for unset or malformed values. An empty prefix is
+// allowed when the variable is set.
+func orderNumberingFromEnv() numbering.Config {
+	config := numbering.DefaultConfig
+	if prefix, ok := os.LookupEnv("ORDER_NUMBER_PREFIX"); ok {
+		config.Prefix = prefix
+	}
+	if padding, err := strconv.Atoi(os.Getenv("ORDER_NUMBER_PADDING")); err == nil && padding > 0 {
+		config.Padding = padding
+	}
+	if reset, err := strconv.ParseBool(os.Getenv("ORDER_NUMBER_YEARLY_RESET")); err == nil {
+		config.YearlyReset = reset
+	}
+	return config
+}
@@ -0,0 +1,39 @@
+// Package shipping sends orders to customers in one or more shipments. Each
+// shipment is booked with a carrier, whose tracking is polled until the
+// parcel is delivered; an order ships with its last shipment and is
+// delivered with it.
+package shipping
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+)
+
+// ErrUnknownTrackingCode is returned by carriers for codes they did not issue
+var ErrUnknownTrackingCode = errors.New("unknown tracking code")
+
+// ParcelRequest describes a parcel to book with a carrier
+type ParcelRequest struct {
+	OrderID uint
+	Items   []models.ShipmentItem
+}
+
+// Tracking is the state of a parcel reported by its carrier. DeliveredAt is
+// set once Status is delivered.
+type Tracking struct {
+	Status      models.ShipmentStatus
+	DeliveredAt time.Time
+}
+
+// Carrier is a delivery service
+type Carrier interface {
+	// Name identifies the carrier in stored shipments
+	Name() string
+	// Book hands a parcel to the carrier and returns its tracking code
+	Book(ctx context.Context, req ParcelRequest) (string, error)
+	// Track reports where the parcel with the tracking code is
+	Track(ctx context.Context, trackingCode string) (*Tracking, error)
+}
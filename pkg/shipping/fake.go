@@ -0,0 +1,63 @@
+package shipping
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// DefaultFakeTransit is how long fake carrier parcels take by default
+const DefaultFakeTransit = 48 * time.Hour
+
+const fakeTrackingPrefix = "FAKE"
+
+// FakeCarrier is an in-process carrier for development and tests. It keeps
+// no state: the tracking code records when the parcel was booked, and every
+// parcel is delivered transit after that.
+type FakeCarrier struct {
+	transit time.Duration
+	now     func() time.Time
+}
+
+// NewFakeCarrier creates a fake carrier delivering parcels after transit
+func NewFakeCarrier(transit time.Duration) *FakeCarrier {
+	return &FakeCarrier{transit: transit, now: time.Now}
+}
+
+// Name identifies the carrier in stored shipments
+func (f *FakeCarrier) Name() string {
+	return "fake"
+}
+
+// Book issues a tracking code carrying the booking time
+func (f *FakeCarrier) Book(ctx context.Context, req ParcelRequest) (string, error) {
+	if len(req.Items) == 0 {
+		return "", fmt.Errorf("empty parcel for order %d", req.OrderID)
+	}
+	id := strings.ToUpper(strings.ReplaceAll(uuid.NewString(), "-", "")[:8])
+	return fmt.Sprintf("%s-%d-%s", fakeTrackingPrefix, f.now().Unix(), id), nil
+}
+
+// Track reports the parcel delivered once transit has passed since booking
+func (f *FakeCarrier) Track(ctx context.Context, trackingCode string) (*Tracking, error) {
+	parts := strings.Split(trackingCode, "-")
+	if len(parts) != 3 || parts[0] != fakeTrackingPrefix {
+		return nil, ErrUnknownTrackingCode
+	}
+	booked, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrUnknownTrackingCode
+	}
+
+	due := time.Unix(booked, 0).Add(f.transit)
+	if f.now().Before(due) {
+		return &Tracking{Status: models.ShipmentInTransit}, nil
+	}
+	return &Tracking{Status: models.ShipmentDelivered, DeliveredAt: due}, nil
+}
@@ -0,0 +1,45 @@
+package shipping
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeCarrierDeliversAfterTransit(t *testing.T) {
+	booked := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	carrier := NewFakeCarrier(48 * time.Hour)
+	carrier.now = func() time.Time { return booked }
+
+	code, err := carrier.Book(context.Background(), ParcelRequest{OrderID: 1, Items: []models.ShipmentItem{{Quantity: 1}}})
+	require.NoError(t, err)
+
+	tracking, err := carrier.Track(context.Background(), code)
+	require.NoError(t, err)
+	assert.Equal(t, models.ShipmentInTransit, tracking.Status)
+
+	// Another instance tracks the same parcel, the code carries its state
+	later := NewFakeCarrier(48 * time.Hour)
+	later.now = func() time.Time { return booked.Add(72 * time.Hour) }
+	tracking, err = later.Track(context.Background(), code)
+	require.NoError(t, err)
+	assert.Equal(t, models.ShipmentDelivered, tracking.Status)
+	assert.True(t, tracking.DeliveredAt.Equal(booked.Add(48*time.Hour)))
+}
+
+func TestFakeCarrierRejectsForeignCodes(t *testing.T) {
+	carrier := NewFakeCarrier(time.Hour)
+
+	for _, code := range []string{"", "1Z999AA10123456784", "FAKE-soon-ABC", "FAKE-1-2-3"} {
+		_, err := carrier.Track(context.Background(), code)
+		assert.ErrorIs(t, err, ErrUnknownTrackingCode, code)
+	}
+
+	_, err := carrier.Book(context.Background(), ParcelRequest{OrderID: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package shipping
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pollBatch bounds how many shipments a single poll tracks
+const pollBatch = 100
+
+// Poller periodically asks the carrier about shipments in transit and
+// delivers the ones that arrived
+type Poller struct {
+	db        *gorm.DB
+	carrier   Carrier
+	interval  time.Duration
+	setStatus SetStatusFunc
+}
+
+// NewPoller creates a poller that tracks the carrier's shipments every
+// interval
+func NewPoller(db *gorm.DB, carrier Carrier, interval time.Duration, setStatus SetStatusFunc) *Poller {
+	return &Poller{db: db, carrier: carrier, interval: interval, setStatus: setStatus}
+}
+
+// Run polls until the context is cancelled
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := p.Poll(ctx); err != nil {
+				log.Printf("Shipment tracking poll failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Delivered %d shipments", n)
+			}
+		}
+	}
+}
+
+// Poll tracks one batch of shipments in transit, oldest first, and returns
+// how many were delivered. A failure on one shipment is logged and does not
+// stop the others.
+func (p *Poller) Poll(ctx context.Context) (int, error) {
+	var shipments []models.Shipment
+	err := p.db.WithContext(ctx).
+		Where("carrier = ? AND status = ?", p.carrier.Name(), models.ShipmentInTransit).
+		Order("shipped_at").
+		Limit(pollBatch).
+		Find(&shipments).Error
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, shipment := range shipments {
+		tracking, err := p.carrier.Track(ctx, shipment.TrackingCode)
+		if err != nil {
+			log.Printf("Failed to track shipment %d: %v", shipment.ID, err)
+			continue
+		}
+		if tracking.Status != models.ShipmentDelivered {
+			continue
+		}
+
+		err = p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var locked models.Shipment
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, shipment.ID).Error; err != nil {
+				return err
+			}
+			return Deliver(tx, &locked, tracking.DeliveredAt, p.setStatus)
+		})
+		if err != nil {
+			log.Printf("Failed to deliver shipment %d: %v", shipment.ID, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered, nil
+}
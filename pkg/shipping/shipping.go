@@ -0,0 +1,171 @@
+package shipping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrOrderNotShippable is returned for orders that are not paid
+	ErrOrderNotShippable = errors.New("order cannot be shipped")
+	// ErrInvalidItem is returned for items that are not part of the order or
+	// quantities above what is left to ship
+	ErrInvalidItem = errors.New("invalid shipment item")
+	// ErrNothingToShip is returned when every item has been shipped already
+	ErrNothingToShip = errors.New("nothing left to ship")
+)
+
+// ItemRequest asks to ship Quantity units of an order item
+type ItemRequest struct {
+	OrderItemID uint
+	Quantity    int
+}
+
+// SetStatusFunc moves an order to shipped or delivered. It runs in the
+// transaction that changed the order's shipments.
+type SetStatusFunc func(tx *gorm.DB, order *models.Order, status models.OrderStatus) error
+
+// Create books a shipment of items of a paid order with the carrier. Without
+// items the shipment holds everything not shipped yet. The shipment that
+// leaves nothing to ship moves the order to shipped through setStatus. The
+// order must be locked by the caller so concurrent requests cannot ship the
+// same units twice.
+func Create(ctx context.Context, tx *gorm.DB, c Carrier, order *models.Order, items []ItemRequest, setStatus SetStatusFunc) (*models.Shipment, error) {
+	if order.Status != models.OrderStatusPaid {
+		return nil, ErrOrderNotShippable
+	}
+
+	var lines []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Order("id").Find(&lines).Error; err != nil {
+		return nil, fmt.Errorf("loading order items: %w", err)
+	}
+	shipped, err := shippedQuantities(tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		for _, line := range lines {
+			if left := line.Quantity - shipped[line.ID]; left > 0 {
+				items = append(items, ItemRequest{OrderItemID: line.ID, Quantity: left})
+			}
+		}
+		if len(items) == 0 {
+			return nil, ErrNothingToShip
+		}
+	}
+
+	byID := make(map[uint]models.OrderItem, len(lines))
+	for _, line := range lines {
+		byID[line.ID] = line
+	}
+	shipment := &models.Shipment{
+		OrderID:   order.ID,
+		Carrier:   c.Name(),
+		Status:    models.ShipmentInTransit,
+		ShippedAt: time.Now(),
+	}
+	for _, req := range items {
+		line, ok := byID[req.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("%w: order item %d is not part of order %d", ErrInvalidItem, req.OrderItemID, order.ID)
+		}
+		if req.Quantity < 1 || shipped[line.ID]+req.Quantity > line.Quantity {
+			return nil, fmt.Errorf("%w: %d of order item %d left to ship, %d requested",
+				ErrInvalidItem, line.Quantity-shipped[line.ID], line.ID, req.Quantity)
+		}
+		shipped[line.ID] += req.Quantity
+
+		shipment.Items = append(shipment.Items, models.ShipmentItem{
+			OrderItemID: line.ID,
+			ProductID:   line.ProductID,
+			Quantity:    req.Quantity,
+		})
+	}
+
+	code, err := c.Book(ctx, ParcelRequest{OrderID: order.ID, Items: shipment.Items})
+	if err != nil {
+		return nil, fmt.Errorf("booking shipment: %w", err)
+	}
+	shipment.TrackingCode = code
+	if err := tx.Create(shipment).Error; err != nil {
+		return nil, fmt.Errorf("recording shipment: %w", err)
+	}
+
+	for _, line := range lines {
+		if shipped[line.ID] < line.Quantity {
+			return shipment, nil
+		}
+	}
+	if err := setStatus(tx, order, models.OrderStatusShipped); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// Deliver records that a shipment reached the customer. Once no shipment of
+// a shipped order is in transit any more, the order moves to delivered
+// through setStatus.
+func Deliver(tx *gorm.DB, shipment *models.Shipment, deliveredAt time.Time, setStatus SetStatusFunc) error {
+	if shipment.Status == models.ShipmentDelivered {
+		return nil
+	}
+
+	shipment.Status = models.ShipmentDelivered
+	shipment.DeliveredAt = &deliveredAt
+	if err := tx.Model(shipment).Updates(map[string]interface{}{
+		"status":       shipment.Status,
+		"delivered_at": shipment.DeliveredAt,
+	}).Error; err != nil {
+		return fmt.Errorf("updating shipment: %w", err)
+	}
+
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, shipment.OrderID).Error; err != nil {
+		return fmt.Errorf("loading order: %w", err)
+	}
+	// Orders still being packed wait for their last shipment
+	if order.Status != models.OrderStatusShipped {
+		return nil
+	}
+
+	var inTransit int64
+	if err := tx.Model(&models.Shipment{}).
+		Where("order_id = ? AND status = ?", order.ID, models.ShipmentInTransit).
+		Count(&inTransit).Error; err != nil {
+		return fmt.Errorf("counting shipments: %w", err)
+	}
+	if inTransit > 0 {
+		return nil
+	}
+	return setStatus(tx, &order, models.OrderStatusDelivered)
+}
+
+// shippedQuantities sums the shipped quantities of the order per order item
+func shippedQuantities(tx *gorm.DB, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := tx.Table("shipment_items si").
+		Select("si.order_item_id, SUM(si.quantity) AS quantity").
+		Joins("JOIN shipments s ON s.id = si.shipment_id").
+		Where("s.order_id = ?", orderID).
+		Group("si.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("loading shipped quantities: %w", err)
+	}
+	shipped := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		shipped[row.OrderItemID] = row.Quantity
+	}
+	return shipped, nil
+}
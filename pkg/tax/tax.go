@@ -0,0 +1,144 @@
+// Package tax computes the VAT breakdown of orders. Catalogue prices include
+// VAT, so taxes never change what the customer pays: every order line is
+// split into its net amount and the tax contained in it, at the rate of the
+// product's tax class in the order's region.
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownRegion is returned for a region without any tax rates
+	ErrUnknownRegion = errors.New("unknown tax region")
+	// ErrNoRate is returned when a product's tax class has no rate in the
+	// order's region
+	ErrNoRate = errors.New("no tax rate")
+)
+
+// MissingRateError names the order line whose tax class has no rate
+type MissingRateError struct {
+	ProductID uint
+	Region    string
+	TaxClass  models.TaxClass
+}
+
+func (e *MissingRateError) Error() string {
+	return fmt.Sprintf("no tax rate for class %q in region %s (product %d)", e.TaxClass, e.Region, e.ProductID)
+}
+
+// Unwrap lets errors.Is match ErrNoRate
+func (e *MissingRateError) Unwrap() error {
+	return ErrNoRate
+}
+
+// Rates maps the tax classes of one region to their percentage
+type Rates map[models.TaxClass]int
+
+// NormalizeRegion returns the canonical form of a region code
+func NormalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+// LoadRates returns the rates of a region, or ErrUnknownRegion when it has
+// none
+func LoadRates(db *gorm.DB, region string) (Rates, error) {
+	var rows []models.TaxRate
+	if err := db.Where("region = ?", region).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("loading tax rates: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRegion, region)
+	}
+
+	rates := make(Rates, len(rows))
+	for _, row := range rows {
+		rates[row.TaxClass] = row.Percent
+	}
+	return rates, nil
+}
+
+// Split splits a VAT-inclusive amount into its net and tax parts. The tax is
+// rounded half away from zero to whole cents and net is the remainder, so net
+// and tax always add up to gross.
+func Split(gross models.Money, percent int) (net, tax models.Money) {
+	num := gross.Cents() * int64(percent)
+	den := int64(100 + percent)
+
+	cents, rem := num/den, num%den
+	if rem < 0 {
+		rem = -rem
+	}
+	if 2*rem >= den {
+		if num < 0 {
+			cents--
+		} else {
+			cents++
+		}
+	}
+	tax = models.MoneyFromCents(cents)
+	return gross.Sub(tax), tax
+}
+
+// Apply fills in the tax breakdown of an order being placed from its items'
+// TaxClass. An item's gross amount is its line total less the adjustments of
+// its product, so discounts reduce the taxable amount; the items then add up
+// to the order's Total. Order.NetTotal and Order.TaxTotal are set from the
+// items.
+func Apply(order *models.Order, rates Rates) error {
+	byProduct := make(map[uint][]int)
+	for i := range order.Items {
+		item := &order.Items[i]
+		percent, ok := rates[item.TaxClass]
+		if !ok {
+			return &MissingRateError{ProductID: item.ProductID, Region: order.TaxRegion, TaxClass: item.TaxClass}
+		}
+		item.TaxPercent = percent
+		item.Gross = item.Price.Mul(item.Quantity)
+		byProduct[item.ProductID] = append(byProduct[item.ProductID], i)
+	}
+
+	for _, a := range order.Adjustments {
+		if a.ProductID == nil || len(byProduct[*a.ProductID]) == 0 {
+			return fmt.Errorf("adjustment %q is not tied to an order item", a.Description)
+		}
+		allocate(order.Items, byProduct[*a.ProductID], a.Amount)
+	}
+
+	order.NetTotal, order.TaxTotal = models.Money{}, models.Money{}
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.Net, item.Tax = Split(item.Gross, item.TaxPercent)
+		order.NetTotal = order.NetTotal.Add(item.Net)
+		order.TaxTotal = order.TaxTotal.Add(item.Tax)
+	}
+	if order.NetTotal.Add(order.TaxTotal).Cmp(order.Total) != 0 {
+		return fmt.Errorf("tax breakdown %s does not add up to order total %s", order.NetTotal.Add(order.TaxTotal), order.Total)
+	}
+	return nil
+}
+
+// allocate adds amount to the gross of the given items in proportion to
+// their line totals, the last item taking the rounding remainder
+func allocate(items []models.OrderItem, indexes []int, amount models.Money) {
+	var total int64
+	for _, i := range indexes {
+		total += items[i].Price.Mul(items[i].Quantity).Cents()
+	}
+
+	left := amount
+	for n, i := range indexes {
+		share := left
+		if n < len(indexes)-1 && total != 0 {
+			share = models.MoneyFromCents(amount.Cents() * items[i].Price.Mul(items[i].Quantity).Cents() / total)
+		}
+		items[i].Gross = items[i].Gross.Add(share)
+		left = left.Sub(share)
+	}
+}
@@ -0,0 +1,111 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func money(s string) models.Money {
+	return models.MustParseMoney(s)
+}
+
+var ruRates = Rates{
+	models.TaxClassStandard: 22,
+	models.TaxClassReduced:  10,
+	models.TaxClassZero:     0,
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		gross   string
+		percent int
+		net     string
+		tax     string
+	}{
+		{"122.00", 22, "100.00", "22.00"},
+		{"110.00", 10, "100.00", "10.00"},
+		{"99.99", 22, "81.96", "18.03"},
+		{"0.01", 22, "0.01", "0.00"},
+		{"50.00", 0, "50.00", "0.00"},
+		{"-12.20", 22, "-10.00", "-2.20"},
+	}
+	for _, tt := range tests {
+		net, tax := Split(money(tt.gross), tt.percent)
+		assert.Equal(t, money(tt.net), net, tt.gross)
+		assert.Equal(t, money(tt.tax), tax, tt.gross)
+		assert.Equal(t, money(tt.gross), net.Add(tax), tt.gross)
+	}
+}
+
+func TestApply(t *testing.T) {
+	book, lamp := uint(1), uint(2)
+	order := models.Order{
+		TaxRegion: "RU",
+		Total:     money("79.00"),
+		Items: []models.OrderItem{
+			{ProductID: book, Quantity: 3, Price: money("10.00"), TaxClass: models.TaxClassReduced},
+			{ProductID: lamp, Quantity: 1, Price: money("50.00"), TaxClass: models.TaxClassStandard},
+		},
+		Adjustments: []models.OrderAdjustment{
+			{ProductID: &lamp, Amount: money("-1.00")},
+		},
+	}
+
+	require.NoError(t, Apply(&order, ruRates))
+
+	bookItem, lampItem := order.Items[0], order.Items[1]
+	assert.Equal(t, 10, bookItem.TaxPercent)
+	assert.Equal(t, money("30.00"), bookItem.Gross)
+	assert.Equal(t, money("27.27"), bookItem.Net)
+	assert.Equal(t, money("2.73"), bookItem.Tax)
+
+	// The discount lowers the taxable amount of the lamp
+	assert.Equal(t, 22, lampItem.TaxPercent)
+	assert.Equal(t, money("49.00"), lampItem.Gross)
+	assert.Equal(t, money("40.16"), lampItem.Net)
+	assert.Equal(t, money("8.84"), lampItem.Tax)
+
+	assert.Equal(t, money("67.43"), order.NetTotal)
+	assert.Equal(t, money("11.57"), order.TaxTotal)
+}
+
+func TestApplySpreadsAdjustmentOverLinesOfAProduct(t *testing.T) {
+	book := uint(1)
+	order := models.Order{
+		Total: money("29.00"),
+		Items: []models.OrderItem{
+			{ProductID: book, Quantity: 1, Price: money("10.00"), TaxClass: models.TaxClassStandard},
+			{ProductID: book, Quantity: 2, Price: money("10.00"), TaxClass: models.TaxClassStandard},
+		},
+		Adjustments: []models.OrderAdjustment{
+			{ProductID: &book, Amount: money("-1.00")},
+		},
+	}
+
+	require.NoError(t, Apply(&order, ruRates))
+	assert.Equal(t, money("9.67"), order.Items[0].Gross)
+	assert.Equal(t, money("19.33"), order.Items[1].Gross)
+	assert.Equal(t, order.Total, order.NetTotal.Add(order.TaxTotal))
+}
+
+func TestApplyMissingRate(t *testing.T) {
+	order := models.Order{
+		TaxRegion: "RU",
+		Total:     money("10.00"),
+		Items: []models.OrderItem{
+			{ProductID: 7, Quantity: 1, Price: money("10.00"), TaxClass: "luxury"},
+		},
+	}
+
+	err := Apply(&order, ruRates)
+	require.True(t, errors.Is(err, ErrNoRate))
+	var missing *MissingRateError
+	require.True(t, errors.As(err, &missing))
+	assert.Equal(t, uint(7), missing.ProductID)
+	assert.Equal(t, models.TaxClass("luxury"), missing.TaxClass)
+}
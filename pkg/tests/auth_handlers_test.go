@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func postJSON(path string, body interface{}) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthFlow(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "auth@example.com", FirstName: "Auth", LastName: "User"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	var pair tokenPair
+
+	t.Run("Login with wrong password", func(t *testing.T) {
+		w := postJSON("/api/auth/login", gin.H{"email": user.Email, "password": "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		w := postJSON("/api/auth/login", gin.H{"email": user.Email, "password": "secret123"})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+		assert.NotEmpty(t, pair.AccessToken)
+		assert.NotEmpty(t, pair.RefreshToken)
+	})
+
+	t.Run("Protected route requires token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/orders", nil)
+		testRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/api/orders", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		testRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Refresh rotates the token", func(t *testing.T) {
+		w := postJSON("/api/auth/refresh", gin.H{"refresh_token": pair.RefreshToken})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var rotated tokenPair
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+		assert.NotEqual(t, pair.RefreshToken, rotated.RefreshToken)
+
+		// Replaying the old token revokes the whole family
+		w = postJSON("/api/auth/refresh", gin.H{"refresh_token": pair.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON("/api/auth/refresh", gin.H{"refresh_token": rotated.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Logout revokes the token", func(t *testing.T) {
+		w := postJSON("/api/auth/login", gin.H{"email": user.Email, "password": "secret123"})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+
+		w = postJSON("/api/auth/logout", gin.H{"refresh_token": pair.RefreshToken})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = postJSON("/api/auth/refresh", gin.H{"refresh_token": pair.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
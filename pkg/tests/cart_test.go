@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCart(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "cart@example.com", FirstName: "Cart", LastName: "User"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	keyboard := models.Product{Name: "Keyboard", Price: models.MustParseMoney("50.00"), Stock: 10, SKU: "CART-KB"}
+	mouse := models.Product{Name: "Mouse", Price: models.MustParseMoney("20.00"), Stock: 1, SKU: "CART-MS"}
+	require.NoError(t, testDB.Create(&keyboard).Error)
+	require.NoError(t, testDB.Create(&mouse).Error)
+
+	// send calls the cart API as the user when authenticated is set and as
+	// the anonymous visitor holding token otherwise
+	send := func(method, path string, body interface{}, authenticated bool, token string) *httptest.ResponseRecorder {
+		reader := &bytes.Buffer{}
+		if body != nil {
+			jsonValue, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(jsonValue)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, reader)
+		if authenticated {
+			authorize(req, user.ID, models.RoleCustomer)
+		}
+		if token != "" {
+			req.Header.Set("X-Cart-Token", token)
+		}
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) database.CartView {
+		var view database.CartView
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+		return view
+	}
+
+	var anonymousToken string
+
+	t.Run("Anonymous visitor gets a cart token", func(t *testing.T) {
+		w := send("POST", "/api/cart/items", gin.H{"product_id": keyboard.ID, "quantity": 1}, false, "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		anonymousToken = w.Header().Get("X-Cart-Token")
+		require.NotEmpty(t, anonymousToken)
+
+		view := decode(send("GET", "/api/cart", nil, false, anonymousToken))
+		require.Len(t, view.Items, 1)
+		assert.Equal(t, keyboard.ID, view.Items[0].ProductID)
+		assert.Equal(t, models.MustParseMoney("50.00"), view.Total)
+	})
+
+	t.Run("Cart shows current prices and stock warnings", func(t *testing.T) {
+		w := send("POST", "/api/cart/items", gin.H{"product_id": mouse.ID, "quantity": 2}, true, "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		require.NoError(t, testDB.Model(&mouse).Update("price", models.MustParseMoney("25.00")).Error)
+
+		view := decode(send("GET", "/api/cart", nil, true, ""))
+		require.Len(t, view.Items, 1)
+		line := view.Items[0]
+		assert.Equal(t, models.MustParseMoney("25.00"), line.Price)
+		assert.Equal(t, models.MustParseMoney("50.00"), line.LineTotal)
+		assert.Equal(t, 1, line.Available)
+		assert.Equal(t, database.CartWarningInsufficientStock, line.Warning)
+		assert.Equal(t, 1, view.Warnings)
+	})
+
+	t.Run("Update and remove items", func(t *testing.T) {
+		w := send("PUT", fmt.Sprintf("/api/cart/items/%d", mouse.ID), gin.H{"quantity": 1}, true, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		view := decode(w)
+		require.Len(t, view.Items, 1)
+		assert.Equal(t, 1, view.Items[0].Quantity)
+		assert.Empty(t, view.Items[0].Warning)
+
+		w = send("DELETE", fmt.Sprintf("/api/cart/items/%d", keyboard.ID), nil, true, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = send("PUT", fmt.Sprintf("/api/cart/items/%d", mouse.ID), gin.H{"quantity": 0}, true, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Login merges the anonymous cart", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(gin.H{"email": user.Email, "password": "secret123"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(jsonValue))
+		req.Header.Set("X-Cart-Token", anonymousToken)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		view := decode(send("GET", "/api/cart", nil, true, ""))
+		require.Len(t, view.Items, 2)
+		assert.Equal(t, models.MustParseMoney("75.00"), view.Total)
+
+		var count int64
+		testDB.Model(&models.Cart{}).Where("token = ?", uuid.MustParse(anonymousToken)).Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Checkout turns the cart into an order", func(t *testing.T) {
+		w := send("POST", "/api/cart/checkout", nil, true, "")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+		assert.Equal(t, user.ID, order.UserID)
+		assert.Len(t, order.Items, 2)
+		assert.Equal(t, models.MustParseMoney("75.00"), order.Total)
+
+		view := decode(send("GET", "/api/cart", nil, true, ""))
+		assert.Empty(t, view.Items)
+
+		w = send("POST", "/api/cart/checkout", nil, true, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Failed checkout keeps the cart", func(t *testing.T) {
+		w := send("POST", "/api/cart/items", gin.H{"product_id": mouse.ID, "quantity": 1}, true, "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		// The only mouse is reserved by the previous order
+		w = send("POST", "/api/cart/checkout", nil, true, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Insufficient stock")
+
+		view := decode(send("GET", "/api/cart", nil, true, ""))
+		require.Len(t, view.Items, 1)
+		assert.Equal(t, database.CartWarningOutOfStock, view.Items[0].Warning)
+	})
+
+	t.Run("Concurrent checkouts order the cart once", func(t *testing.T) {
+		w := send("DELETE", fmt.Sprintf("/api/cart/items/%d", mouse.ID), nil, true, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = send("POST", "/api/cart/items", gin.H{"product_id": keyboard.ID, "quantity": 1}, true, "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var wg sync.WaitGroup
+		codes := make([]int, 5)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i] = send("POST", "/api/cart/checkout", nil, true, "").Code
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, code := range codes {
+			if code == http.StatusCreated {
+				created++
+			} else {
+				assert.Equal(t, http.StatusBadRequest, code)
+			}
+		}
+		assert.Equal(t, 1, created)
+	})
+
+	t.Run("Expired anonymous carts are deleted", func(t *testing.T) {
+		w := send("POST", "/api/cart/items", gin.H{"product_id": keyboard.ID, "quantity": 1}, false, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		token := uuid.MustParse(w.Header().Get("X-Cart-Token"))
+		testDB.Model(&models.Cart{}).Where("token = ?", token).Update("updated_at", time.Now().Add(-48*time.Hour))
+
+		deleted, err := database.DeleteExpiredAnonymousCarts(testDB, time.Now().Add(-24*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		var count int64
+		testDB.Model(&models.Cart{}).Where("token = ?", token).Count(&count)
+		assert.Zero(t, count)
+		testDB.Model(&models.Cart{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Checkout needs an account", func(t *testing.T) {
+		w := send("POST", "/api/cart/checkout", nil, false, anonymousToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	integration "github.com/alzarasatken/FullStackTest/pkg/integration/handlers"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exchangeCatalog = `<?xml version="1.0" encoding="UTF-8"?>
+<КоммерческаяИнформация ВерсияСхемы="2.05">
+  <Классификатор>
+    <Группы><Группа><Ид>g1</Ид><Наименование>Посуда</Наименование></Группа></Группы>
+  </Классификатор>
+  <Каталог>
+    <Товары>
+      <Товар><Ид>cml-pot</Ид><Артикул>POT-5L</Артикул><Наименование>Кастрюля 5 л</Наименование><Группы><Ид>g1</Ид></Группы></Товар>
+      <Товар><Ид>cml-lid</Ид><Наименование>Крышка</Наименование></Товар>
+    </Товары>
+  </Каталог>
+</КоммерческаяИнформация>`
+
+const exchangeOffers = `<?xml version="1.0" encoding="UTF-8"?>
+<КоммерческаяИнформация ВерсияСхемы="2.05">
+  <ПакетПредложений>
+    <ТипыЦен><ТипЦены><Ид>retail</Ид><Наименование>Розничная</Наименование><Валюта>руб</Валюта></ТипЦены></ТипыЦен>
+    <Предложения>
+      <Предложение><Ид>cml-pot</Ид><Цены><Цена><ИдТипаЦены>retail</ИдТипаЦены><ЦенаЗаЕдиницу>1500</ЦенаЗаЕдиницу></Цена></Цены><Количество>8</Количество></Предложение>
+      <Предложение><Ид>cml-lid</Ид><Цены><Цена><ИдТипаЦены>retail</ИдТипаЦены><ЦенаЗаЕдиницу>300.50</ЦенаЗаЕдиницу></Цена></Цены><Количество>3</Количество></Предложение>
+      <Предложение><Ид>cml-unknown</Ид><Количество>1</Количество></Предложение>
+    </Предложения>
+  </ПакетПредложений>
+</КоммерческаяИнформация>`
+
+func TestCommerceMLExchange(t *testing.T) {
+	clearTables()
+
+	engine := gin.New()
+	integration.NewExchangeHandler(testDB, integration.ExchangeConfig{
+		User:     "1c",
+		Password: "exchange-secret",
+		Dir:      t.TempDir(),
+	}).RegisterRoutes(engine)
+
+	var cookie *http.Cookie
+	exchange := func(method, query string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/1c/exchange?"+query, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Login needs the exchange credentials", func(t *testing.T) {
+		w := exchange("GET", "type=catalog&mode=init", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.True(t, strings.HasPrefix(w.Body.String(), "failure\n"))
+
+		req, _ := http.NewRequest("GET", "/api/1c/exchange?type=catalog&mode=checkauth", nil)
+		req.SetBasicAuth("1c", "wrong")
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		req, _ = http.NewRequest("GET", "/api/1c/exchange?type=catalog&mode=checkauth", nil)
+		req.SetBasicAuth("1c", "exchange-secret")
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, "success", lines[0])
+		cookie = &http.Cookie{Name: lines[1], Value: lines[2]}
+	})
+	require.NotNil(t, cookie)
+
+	t.Run("Catalogue and offers are imported", func(t *testing.T) {
+		w := exchange("GET", "type=catalog&mode=init", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "zip=no\n")
+
+		// import.xml arrives in two chunks
+		half := len(exchangeCatalog) / 2
+		for _, chunk := range []string{exchangeCatalog[:half], exchangeCatalog[half:]} {
+			w = exchange("POST", "type=catalog&mode=file&filename=import.xml", chunk)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}
+		w = exchange("POST", "type=catalog&mode=file&filename=offers.xml", exchangeOffers)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = exchange("GET", "type=catalog&mode=import&filename=import.xml", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "success\n", w.Body.String())
+		w = exchange("GET", "type=catalog&mode=import&filename=offers.xml", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var pot models.Product
+		require.NoError(t, testDB.Where("external_id = ?", "cml-pot").First(&pot).Error)
+		assert.Equal(t, "Кастрюля 5 л", pot.Name)
+		assert.Equal(t, "POT-5L", pot.SKU)
+		assert.Equal(t, "Посуда", pot.Category)
+		assert.Equal(t, models.MustParseMoney("1500.00"), pot.Price)
+		assert.Equal(t, models.CurrencyRUB, pot.Currency)
+		assert.Equal(t, 8, pot.Stock)
+
+		var lid models.Product
+		require.NoError(t, testDB.Where("external_id = ?", "cml-lid").First(&lid).Error)
+		assert.Equal(t, "1C-cml-lid", lid.SKU)
+		assert.Equal(t, models.MustParseMoney("300.50"), lid.Price)
+	})
+
+	t.Run("Reimports keep changes made in the shop", func(t *testing.T) {
+		var pot models.Product
+		require.NoError(t, testDB.Where("external_id = ?", "cml-pot").First(&pot).Error)
+		require.NotNil(t, pot.SyncedAt)
+		assert.Equal(t, models.ProductSourceOneC, pot.ModifiedBy)
+		require.NoError(t, testDB.Model(&pot).Updates(map[string]interface{}{
+			"name":        "Кастрюля для супа",
+			"modified_by": models.ProductSourceShop,
+		}).Error)
+
+		renamed := strings.Replace(exchangeCatalog, "<Наименование>Кастрюля 5 л</Наименование>", "<Наименование>Кастрюля 5 литров</Наименование>", 1)
+		exchange("GET", "type=catalog&mode=init", "")
+		exchange("POST", "type=catalog&mode=file&filename=import.xml", renamed)
+		w := exchange("GET", "type=catalog&mode=import&filename=import.xml", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		require.NoError(t, testDB.First(&pot, pot.ID).Error)
+		assert.Equal(t, "Кастрюля для супа", pot.Name)
+
+		var conflict models.SyncConflict
+		require.NoError(t, testDB.Where("product_id = ? AND field = 'name'", pot.ID).First(&conflict).Error)
+		assert.Equal(t, "Кастрюля 5 литров", conflict.OneCValue)
+		assert.Equal(t, models.ProductSourceShop, conflict.Winner)
+
+		var synced models.ProductSyncValue
+		require.NoError(t, testDB.Where("product_id = ? AND field = 'stock'", pot.ID).First(&synced).Error)
+		assert.Equal(t, "8", synced.Value)
+	})
+
+	t.Run("Removed products are deleted", func(t *testing.T) {
+		removed := strings.Replace(exchangeCatalog, "<Товар><Ид>cml-lid</Ид>", `<Товар Статус="Удален"><Ид>cml-lid</Ид>`, 1)
+		exchange("GET", "type=catalog&mode=init", "")
+		exchange("POST", "type=catalog&mode=file&filename=import.xml", removed)
+		w := exchange("GET", "type=catalog&mode=import&filename=import.xml", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var count int64
+		testDB.Model(&models.Product{}).Where("external_id = ?", "cml-lid").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("File names cannot leave the exchange directory", func(t *testing.T) {
+		w := exchange("POST", "type=catalog&mode=file&filename=../../escape.xml", exchangeOffers)
+		require.Equal(t, http.StatusOK, w.Code)
+		w = exchange("GET", "type=catalog&mode=import&filename=escape.xml", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Orders are pulled until 1C confirms them", func(t *testing.T) {
+		user := models.User{Email: "cml@example.com", FirstName: "Анна", LastName: "Смирнова"}
+		require.NoError(t, user.SetPassword("secret123"))
+		require.NoError(t, testDB.Create(&user).Error)
+		var pot models.Product
+		require.NoError(t, testDB.Where("external_id = ?", "cml-pot").First(&pot).Error)
+
+		jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": pot.ID, "quantity": 2}}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+		w = exchange("GET", "type=sale&mode=query", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, fmt.Sprintf("<Ид>%s</Ид>", order.Number))
+		assert.Contains(t, body, "<Ид>cml-pot</Ид>")
+		assert.Contains(t, body, "<Наименование>Анна Смирнова</Наименование>")
+
+		// Without confirmation the order is sent again
+		w = exchange("GET", "type=sale&mode=query", "")
+		assert.Contains(t, w.Body.String(), order.Number)
+
+		w = exchange("GET", "type=sale&mode=success", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		w = exchange("GET", "type=sale&mode=query", "")
+		assert.NotContains(t, w.Body.String(), order.Number)
+	})
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiCurrency(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "currency@example.com", FirstName: "Currency", LastName: "User"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	product := models.Product{
+		Name:  "Test Product",
+		Price: models.MustParseMoney("1000.00"),
+		Stock: 10,
+		SKU:   "TEST-SKU-CUR",
+	}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	t.Run("Customer cannot set rates", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(gin.H{"currency": "EUR", "rate": "100"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/exchange-rates", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Conversion without a rate fails", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/products?currency=EUR", nil)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// An older rate proves the latest one wins
+	for i, rate := range []string{"90", "100"} {
+		jsonValue, _ := json.Marshal(gin.H{
+			"currency":     "EUR",
+			"rate":         rate,
+			"effective_at": time.Now().Add(time.Duration(i-2) * time.Hour),
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/exchange-rates", bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	t.Run("Products converted to EUR", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/products?currency=eur", nil)
+		testRouter.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Products []struct {
+				Price    models.Money    `json:"price"`
+				Currency models.Currency `json:"currency"`
+			} `json:"products"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Products, 1)
+		assert.Equal(t, models.MustParseMoney("10.00"), response.Products[0].Price)
+		assert.Equal(t, models.CurrencyEUR, response.Products[0].Currency)
+	})
+
+	t.Run("Order snapshots currency and rate", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(gin.H{
+			"currency": "EUR",
+			"items":    []gin.H{{"product_id": product.ID, "quantity": 3}},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+		assert.Equal(t, models.CurrencyEUR, order.Currency)
+		assert.Equal(t, models.MustParseRate("100"), order.ExchangeRate)
+		assert.Equal(t, models.MustParseMoney("30.00"), order.Total)
+		require.Len(t, order.Items, 1)
+		assert.Equal(t, models.MustParseMoney("1000.00"), order.Items[0].BasePrice)
+
+		// A later rate change must not affect the stored order
+		testDB.Create(&models.ExchangeRate{
+			Currency:    models.CurrencyEUR,
+			Rate:        models.MustParseRate("120"),
+			EffectiveAt: time.Now(),
+		})
+		var stored models.Order
+		require.NoError(t, testDB.First(&stored, order.ID).Error)
+		assert.Equal(t, order.Total, stored.Total)
+		assert.Equal(t, order.ExchangeRate, stored.ExchangeRate)
+	})
+
+	t.Run("Rate history", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/exchange-rates?currency=%s", models.CurrencyEUR), nil)
+		testRouter.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			ExchangeRates []models.ExchangeRate `json:"exchange_rates"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.ExchangeRates, 3)
+		assert.Equal(t, models.MustParseRate("120"), response.ExchangeRates[0].Rate)
+	})
+}
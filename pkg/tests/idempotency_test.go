@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "retry@example.com", FirstName: "Retry", LastName: "User"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	product := models.Product{
+		Name:  "Retried Product",
+		Price: models.MustParseMoney("10.00"),
+		Stock: 10,
+		SKU:   "TEST-SKU-RETRY",
+	}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	send := func(method, path, key string, body interface{}) *httptest.ResponseRecorder {
+		var reader *bytes.Buffer
+		if body != nil {
+			jsonValue, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(jsonValue)
+		} else {
+			reader = &bytes.Buffer{}
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, reader)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	orderBody := gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 2}}}
+
+	t.Run("Retried order is created once", func(t *testing.T) {
+		first := send("POST", "/api/orders", "order-key-1", orderBody)
+		require.Equal(t, http.StatusCreated, first.Code)
+
+		retry := send("POST", "/api/orders", "order-key-1", orderBody)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+		var count int64
+		testDB.Model(&models.Order{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		var stored models.Product
+		require.NoError(t, testDB.First(&stored, product.ID).Error)
+		assert.Equal(t, 2, stored.Reserved)
+	})
+
+	t.Run("Same key with a different body is rejected", func(t *testing.T) {
+		w := send("POST", "/api/orders", "order-key-1", gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 3}}})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Keys are scoped per user", func(t *testing.T) {
+		other := models.User{Email: "other-retry@example.com", FirstName: "Other", LastName: "User"}
+		require.NoError(t, other.SetPassword("secret123"))
+		require.NoError(t, testDB.Create(&other).Error)
+
+		jsonValue, _ := json.Marshal(orderBody)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Idempotency-Key", "order-key-1")
+		authorize(req, other.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("Retried cancellation is replayed", func(t *testing.T) {
+		var order models.Order
+		require.NoError(t, testDB.Where("user_id = ?", user.ID).First(&order).Error)
+		path := fmt.Sprintf("/api/orders/%d/cancel", order.ID)
+
+		first := send("POST", path, "cancel-key-1", nil)
+		require.Equal(t, http.StatusOK, first.Code)
+
+		retry := send("POST", path, "cancel-key-1", nil)
+		assert.Equal(t, http.StatusOK, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+		// Without a key the request runs again and sees the cancelled order
+		w := send("POST", path, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Retried registration is created once", func(t *testing.T) {
+		body := gin.H{
+			"email":      "new-retry@example.com",
+			"password":   "secret123",
+			"first_name": "New",
+			"last_name":  "User",
+			"phone":      "+70000000000",
+		}
+		register := func() *httptest.ResponseRecorder {
+			jsonValue, _ := json.Marshal(body)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/users", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Idempotency-Key", "register-key-1")
+			testRouter.ServeHTTP(w, req)
+			return w
+		}
+
+		first := register()
+		require.Equal(t, http.StatusCreated, first.Code)
+		retry := register()
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+		var count int64
+		testDB.Model(&models.User{}).Where("email = ?", body["email"]).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderDocuments(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "invoiced@example.com", FirstName: "In", LastName: "Voiced"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	product := models.Product{Name: "Teapot", Price: models.MustParseMoney("40.00"), Stock: 10, SKU: "DOC-TEAPOT"}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	get := func(path string, userID uuid.UUID, role models.Role) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		authorize(req, userID, role)
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	placeOrder := func(paid bool) models.Order {
+		jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 1}}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+		if paid {
+			require.NoError(t, testDB.Model(&order).Update("status", models.OrderStatusPaid).Error)
+		}
+		return order
+	}
+	invoice := func(order models.Order) *httptest.ResponseRecorder {
+		return get(fmt.Sprintf("/api/orders/%d/invoice.pdf", order.ID), user.ID, models.RoleCustomer)
+	}
+
+	t.Run("Pending orders are not invoiced", func(t *testing.T) {
+		w := invoice(placeOrder(false))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invoices are numbered in sequence and stored", func(t *testing.T) {
+		first, second := placeOrder(true), placeOrder(true)
+
+		w := invoice(first)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "invoice-000001.pdf")
+		assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")))
+		assert.Contains(t, w.Body.String(), "DOC-TEAPOT")
+		document := w.Body.Bytes()
+
+		w = invoice(second)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "invoice-000002.pdf")
+
+		// Downloading again returns the stored copy, even after a change
+		require.NoError(t, testDB.Model(&models.User{}).Where("id = ?", user.ID).Update("first_name", "Renamed").Error)
+		w = invoice(first)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, document, w.Body.Bytes())
+
+		var count int64
+		require.NoError(t, testDB.Model(&models.Invoice{}).Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Other customers cannot download invoices", func(t *testing.T) {
+		w := get(fmt.Sprintf("/api/orders/%d/invoice.pdf", placeOrder(true).ID), uuid.New(), models.RoleCustomer)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Packing slips are for staff", func(t *testing.T) {
+		order := placeOrder(true)
+
+		w := get(fmt.Sprintf("/api/orders/%d/packing-slip.pdf", order.ID), user.ID, models.RoleCustomer)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = get(fmt.Sprintf("/api/orders/%d/packing-slip.pdf", order.ID), uuid.New(), models.RoleAdmin)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")))
+		assert.Contains(t, w.Body.String(), "DOC-TEAPOT")
+		assert.NotContains(t, w.Body.String(), "40.00")
+	})
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"github.com/alzarasatken/FullStackTest/migrations"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator(t *testing.T) {
+	// testDB is opened like the API's connection, with the prepared statement
+	// cache that multi-statement migrations must bypass
+	migrator, err := database.NewMigrator(testDB, migrations.FS)
+	require.NoError(t, err)
+
+	all, err := database.LoadMigrations(migrations.FS)
+	require.NoError(t, err)
+	latest := all[len(all)-1]
+
+	t.Run("Everything is applied", func(t *testing.T) {
+		statuses, err := migrator.Status()
+		require.NoError(t, err)
+		require.Len(t, statuses, len(all))
+		for _, status := range statuses {
+			assert.NotNil(t, status.AppliedAt, "migration %03d_%s", status.Version, status.Name)
+		}
+	})
+
+	t.Run("The latest migration reverts and reapplies", func(t *testing.T) {
+		reverted, err := migrator.Down(1)
+		require.NoError(t, err)
+		require.Len(t, reverted, 1)
+		assert.Equal(t, latest.Version, reverted[0].Version)
+
+		applied, err := migrator.Up()
+		require.NoError(t, err)
+		require.Len(t, applied, 1)
+		assert.Equal(t, latest.Version, applied[0].Version)
+	})
+
+	t.Run("Nothing is pending afterwards", func(t *testing.T) {
+		applied, err := migrator.Up()
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+	})
+}
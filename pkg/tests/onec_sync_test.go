@@ -0,0 +1,566 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	integration "github.com/alzarasatken/FullStackTest/pkg/integration/handlers"
+	"github.com/alzarasatken/FullStackTest/pkg/integration/onec"
+	"github.com/alzarasatken/FullStackTest/pkg/integration/sync"
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOneCOrderSync(t *testing.T) {
+	clearTables()
+
+	// A stand-in for 1C recording the orders it receives and running during,
+	// if set, before it replies
+	var received [][]map[string]interface{}
+	var during func()
+	oneC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orders/batch" || r.Header.Get("X-API-Key") != "onec-key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var batch []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, batch)
+		if during != nil {
+			during()
+		}
+	}))
+	defer oneC.Close()
+	service := sync.NewService(testDB, onec.NewClient(oneC.URL, "onec-key", onec.DefaultConfig), nil, nil, testMachine)
+
+	user := models.User{Email: "synced@example.com", FirstName: "Syn", LastName: "Ced"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	product := models.Product{Name: "Pan", Price: models.MustParseMoney("12.00"), Stock: 10, SKU: "SYNC-PAN", ExternalID: "1c-pan", Code: "00042"}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 2}}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+	authorize(req, user.ID, models.RoleCustomer)
+	testRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var order models.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+	t.Run("New orders are sent by number", func(t *testing.T) {
+		run, err := service.SyncOrders(context.Background(), models.SyncTriggerManual)
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Fetched)
+		assert.Equal(t, 1, run.Updated)
+		require.Len(t, received, 1)
+		require.Len(t, received[0], 1)
+
+		sent := received[0][0]
+		assert.Equal(t, order.Number, sent["id"])
+		assert.Equal(t, order.Number, sent["number"])
+		assert.Equal(t, user.ID.String(), sent["customerId"])
+		assert.Equal(t, "pending", sent["status"])
+		items := sent["items"].([]interface{})
+		require.Len(t, items, 1)
+		assert.Equal(t, "1c-pan", items[0].(map[string]interface{})["productId"])
+
+		// Synced orders are not sent again
+		_, err = service.SyncOrders(context.Background(), models.SyncTriggerManual)
+		require.NoError(t, err)
+		assert.Len(t, received, 1)
+	})
+
+	t.Run("Status changes are sent again", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/orders/%d/status", order.ID), bytes.NewBufferString(`{"status": "cancelled"}`))
+		authorize(req, uuid.New(), models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		_, err := service.SyncOrders(context.Background(), models.SyncTriggerWorker)
+		require.NoError(t, err)
+		require.Len(t, received, 2)
+		assert.Equal(t, "cancelled", received[1][0]["status"])
+	})
+
+	t.Run("Changes made while 1C receives the order are sent again", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 1}}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var other models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &other))
+
+		during = func() {
+			req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/orders/%d/status", other.ID), bytes.NewBufferString(`{"status": "cancelled"}`))
+			authorize(req, uuid.New(), models.RoleAdmin)
+			testRouter.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		run, err := service.SyncOrders(context.Background(), models.SyncTriggerWorker)
+		during = nil
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Fetched)
+		assert.Equal(t, 0, run.Updated)
+		assert.Equal(t, "pending", received[len(received)-1][0]["status"])
+
+		var stored models.Order
+		require.NoError(t, testDB.First(&stored, other.ID).Error)
+		assert.Equal(t, models.OrderStatusCancelled, stored.Status)
+		assert.False(t, stored.Synced)
+
+		run, err = service.SyncOrders(context.Background(), models.SyncTriggerWorker)
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Updated)
+		assert.Equal(t, "cancelled", received[len(received)-1][0]["status"])
+		require.NoError(t, testDB.First(&stored, other.ID).Error)
+		assert.True(t, stored.Synced)
+	})
+
+	t.Run("Paid orders cancelled by 1C are refunded", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 1}}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var paid models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &paid))
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/payments", paid.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var payment models.Payment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &payment))
+
+		payload, header := testPayments.Authorize(payment.IntentID, payment.Amount, payment.Currency)
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/api/payments/webhook", bytes.NewBuffer(payload))
+		req.Header = header
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		require.NoError(t, service.HandleOrderStatusUpdate(context.Background(), paid.Number, "cancelled"))
+
+		var stored models.Order
+		require.NoError(t, testDB.First(&stored, paid.ID).Error)
+		assert.Equal(t, models.OrderStatusCancelled, stored.Status)
+
+		require.NoError(t, testDB.First(&payment, payment.ID).Error)
+		assert.Equal(t, models.PaymentRefunded, payment.Status)
+		var refunds []models.Refund
+		require.NoError(t, testDB.Where("order_id = ?", paid.ID).Find(&refunds).Error)
+		require.Len(t, refunds, 1)
+		assert.Equal(t, paid.Total, refunds[0].Amount)
+		assert.NotEmpty(t, refunds[0].ProviderReference)
+	})
+}
+
+func TestOneCStockSync(t *testing.T) {
+	clearTables()
+
+	// A stand-in for 1C reporting stock changes since the requested time and
+	// running during, if set, before it replies
+	var sinces []time.Time
+	var during func()
+	deltas := map[string]int{}
+	oneC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/products/stock" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		since, err := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sinces = append(sinces, since)
+		if during != nil {
+			during()
+		}
+		json.NewEncoder(w).Encode(deltas)
+	}))
+	defer oneC.Close()
+	service := sync.NewService(testDB, onec.NewClient(oneC.URL, "onec-key", onec.DefaultConfig), nil, nil, testMachine)
+
+	kettle := models.Product{Name: "Kettle", Price: models.MustParseMoney("20.00"), Stock: 10, Reserved: 4, SKU: "STOCK-KETTLE", ExternalID: "1c-kettle"}
+	require.NoError(t, testDB.Create(&kettle).Error)
+	mug := models.Product{Name: "Mug", Price: models.MustParseMoney("5.00"), Stock: 3, SKU: "STOCK-MUG"}
+	require.NoError(t, testDB.Create(&mug).Error)
+
+	stock := func(id uint) (int, int) {
+		var product models.Product
+		require.NoError(t, testDB.First(&product, id).Error)
+		return product.Stock, product.Reserved
+	}
+
+	// The first run starts the cursor
+	run, err := service.SyncStock(context.Background(), models.SyncTriggerWorker)
+	require.NoError(t, err)
+	assert.Equal(t, 0, run.Updated)
+
+	t.Run("Deltas are applied by 1C ID and SKU", func(t *testing.T) {
+		deltas = map[string]int{"1c-kettle": 5, "STOCK-MUG": -5, "unknown": 7}
+		run, err := service.SyncStock(context.Background(), models.SyncTriggerWorker)
+		require.NoError(t, err)
+		assert.Equal(t, 3, run.Fetched)
+		assert.Equal(t, 2, run.Updated)
+
+		onHand, reserved := stock(kettle.ID)
+		assert.Equal(t, 15, onHand)
+		assert.Equal(t, 4, reserved)
+		onHand, _ = stock(mug.ID)
+		assert.Equal(t, 0, onHand)
+	})
+
+	t.Run("The cursor moves with every run", func(t *testing.T) {
+		deltas = map[string]int{}
+		_, err := service.SyncStock(context.Background(), models.SyncTriggerWorker)
+		require.NoError(t, err)
+
+		require.Len(t, sinces, 3)
+		assert.True(t, sinces[1].After(sinces[0]))
+		var cursor time.Time
+		require.NoError(t, testDB.Raw("SELECT since FROM sync_cursors WHERE name = 'stock'").Scan(&cursor).Error)
+		assert.False(t, cursor.Before(sinces[2]))
+		assert.WithinDuration(t, time.Now(), cursor, 2*time.Second)
+		onHand, _ := stock(kettle.ID)
+		assert.Equal(t, 15, onHand)
+	})
+
+	t.Run("Stock never drops below the reservations", func(t *testing.T) {
+		deltas = map[string]int{"1c-kettle": -20}
+		run, err := service.SyncStock(context.Background(), models.SyncTriggerWorker)
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Updated)
+
+		onHand, reserved := stock(kettle.ID)
+		assert.Equal(t, 4, onHand)
+		assert.Equal(t, 4, reserved)
+	})
+
+	t.Run("Updates are dropped when another run moved the cursor", func(t *testing.T) {
+		deltas = map[string]int{"STOCK-MUG": 6}
+		during = func() {
+			testDB.Exec("UPDATE sync_cursors SET since = NOW() + INTERVAL '1 second' WHERE name = 'stock'")
+		}
+		run, err := service.SyncStock(context.Background(), models.SyncTriggerWorker)
+		during = nil
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Fetched)
+		assert.Equal(t, 0, run.Updated)
+
+		onHand, _ := stock(mug.ID)
+		assert.Equal(t, 0, onHand)
+	})
+}
+
+func TestOneCSyncHistory(t *testing.T) {
+	clearTables()
+
+	// A stand-in for 1C that is down until it is brought back up
+	up := false
+	oneC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer oneC.Close()
+	config := onec.DefaultConfig
+	config.MaxRetries = 0
+	service := sync.NewService(testDB, onec.NewClient(oneC.URL, "onec-key", config), nil, nil, testMachine)
+
+	engine := gin.New()
+	integration.NewHandler(service).RegisterRoutes(engine, middleware.APIKey("sync-key"))
+	call := func(method, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set(middleware.APIKeyHeader, "sync-key")
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	var status integration.StatusResponse
+
+	t.Run("Status is unknown before the first run", func(t *testing.T) {
+		w := call("GET", "/api/sync/status", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, "unknown", status.Status)
+		assert.Nil(t, status.LastProductSync)
+	})
+
+	t.Run("Failed runs are recorded with their error", func(t *testing.T) {
+		w := call("POST", "/api/sync/stock", `{"force": true}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		w = call("GET", "/api/sync/status", "")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, "failing", status.Status)
+		stock := status.Jobs[models.SyncTypeStock]
+		require.NotNil(t, stock.LastRun)
+		assert.Equal(t, models.SyncTriggerForced, stock.LastRun.Trigger)
+		assert.Contains(t, stock.LastError, "503")
+		assert.Nil(t, stock.LastSuccess)
+	})
+
+	t.Run("A successful run clears the failure", func(t *testing.T) {
+		up = true
+		w := call("POST", "/api/sync/stock", `{}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Run models.SyncRun `json:"run"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, models.SyncTriggerManual, response.Run.Trigger)
+		assert.NotNil(t, response.Run.FinishedAt)
+
+		w = call("GET", "/api/sync/status", "")
+		status = integration.StatusResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, "healthy", status.Status)
+		stock := status.Jobs[models.SyncTypeStock]
+		require.NotNil(t, stock.LastSuccess)
+		require.NotNil(t, stock.LagSeconds)
+		assert.LessOrEqual(t, *stock.LagSeconds, int64(5))
+		assert.NotEmpty(t, stock.LastError)
+	})
+
+	t.Run("History is paged newest first", func(t *testing.T) {
+		_, err := service.SyncOrders(context.Background(), models.SyncTriggerWorker)
+		require.NoError(t, err)
+
+		w := call("GET", "/api/sync/runs?limit=2", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Runs       []models.SyncRun `json:"runs"`
+			Pagination struct {
+				TotalItems int64 `json:"total_items"`
+				TotalPages int64 `json:"total_pages"`
+			} `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, int64(3), response.Pagination.TotalItems)
+		assert.Equal(t, int64(2), response.Pagination.TotalPages)
+		require.Len(t, response.Runs, 2)
+		assert.Equal(t, models.SyncTypeOrders, response.Runs[0].Type)
+
+		w = call("GET", "/api/sync/runs?type=stock", "")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Runs, 2)
+	})
+}
+
+func TestOneCSyncDryRun(t *testing.T) {
+	clearTables()
+
+	// A stand-in for 1C listing its products and counting the order batches
+	// it receives
+	var modifiedSince []string
+	batches := 0
+	oneC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products":
+			modifiedSince = append(modifiedSince, r.URL.Query().Get("modifiedSince"))
+			w.Write([]byte(`[
+				{"id": "1c-pan", "code": "00042", "name": "Pan", "price": 13.5, "stock": 10},
+				{"id": "1c-pot", "code": "00043", "name": "Pot", "price": 20, "stock": 3, "deleted": true},
+				{"id": "1c-lid", "code": "00044", "name": "Lid", "price": 3, "stock": 7}
+			]`))
+		case "/orders/batch":
+			batches++
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer oneC.Close()
+	service := sync.NewService(testDB, onec.NewClient(oneC.URL, "onec-key", onec.DefaultConfig), nil, nil, testMachine)
+
+	engine := gin.New()
+	integration.NewHandler(service).RegisterRoutes(engine, middleware.APIKey("sync-key"))
+	call := func(path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set(middleware.APIKeyHeader, "sync-key")
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	pan := models.Product{Name: "Pan", Price: models.MustParseMoney("12.00"), Stock: 10, SKU: "00042", ExternalID: "1c-pan", Code: "00042"}
+	pot := models.Product{Name: "Pot", Price: models.MustParseMoney("20.00"), Stock: 4, SKU: "00043", ExternalID: "1c-pot", Code: "00043"}
+	require.NoError(t, testDB.Create(&pan).Error)
+	require.NoError(t, testDB.Create(&pot).Error)
+
+	user := models.User{Email: "preview@example.com", FirstName: "Pre", LastName: "View"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+	jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": pan.ID, "quantity": 1}}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+	authorize(req, user.ID, models.RoleCustomer)
+	testRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var order models.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+	t.Run("A forced product dry run previews a full resync", func(t *testing.T) {
+		w := call("/api/sync/products", `{"force": true, "dry_run": true}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Status string           `json:"status"`
+			Diff   sync.ProductDiff `json:"diff"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "dry_run", response.Status)
+		assert.Equal(t, []string{""}, modifiedSince)
+
+		diff := response.Diff
+		assert.Equal(t, 3, diff.Fetched)
+		require.Len(t, diff.Created, 1)
+		assert.Equal(t, "1c-lid", diff.Created[0].ExternalID)
+		require.Len(t, diff.PriceChanges, 1)
+		assert.Equal(t, "12.00", diff.PriceChanges[0].OldPrice.String())
+		assert.Equal(t, "13.50", diff.PriceChanges[0].NewPrice.String())
+		assert.Empty(t, diff.StockChanges)
+		require.Len(t, diff.Deactivated, 1)
+		assert.Equal(t, "1c-pot", diff.Deactivated[0].ExternalID)
+
+		// Nothing was written, not even a sync run
+		var count int64
+		testDB.Model(&models.Product{}).Count(&count)
+		assert.Equal(t, int64(2), count)
+		var product models.Product
+		require.NoError(t, testDB.First(&product, pan.ID).Error)
+		assert.Equal(t, "12.00", product.Price.String())
+		testDB.Model(&models.SyncRun{}).Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("An order dry run sends nothing", func(t *testing.T) {
+		w := call("/api/sync/orders", `{"dry_run": true}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Diff struct {
+				Orders []map[string]interface{} `json:"orders"`
+			} `json:"diff"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Diff.Orders, 1)
+		assert.Equal(t, order.Number, response.Diff.Orders[0]["number"])
+		assert.Zero(t, batches)
+	})
+
+	t.Run("Orders sync without a body and force resends synced orders", func(t *testing.T) {
+		w := call("/api/sync/orders", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, batches)
+
+		w = call("/api/sync/orders", `{"dry_run": true}`)
+		assert.NotContains(t, w.Body.String(), order.Number)
+
+		w = call("/api/sync/orders", `{"force": true}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Run models.SyncRun `json:"run"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.Run.Fetched)
+		assert.Equal(t, 2, batches)
+	})
+}
+
+func TestOneCSyncConflicts(t *testing.T) {
+	clearTables()
+
+	// A stand-in for 1C where the pan got a new description and price since
+	// the last sync, and two pans were sold
+	oneC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products":
+			w.Write([]byte(`[{"id": "1c-pan", "code": "00042", "name": "Pan", "description": "A pan from 1C",
+				"price": 13.5, "stock": 10, "category": "Kitchen"}]`))
+		case "/products/stock":
+			w.Write([]byte(`{"1c-pan": -2}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer oneC.Close()
+	service := sync.NewService(testDB, onec.NewClient(oneC.URL, "onec-key", onec.DefaultConfig), nil, nil, testMachine)
+
+	pan := models.Product{Name: "Pan", Description: "A pan", Price: models.MustParseMoney("12.00"), Stock: 10,
+		SKU: "00042", ExternalID: "1c-pan", Code: "00042", Category: "Kitchen", ModifiedBy: models.ProductSourceOneC}
+	require.NoError(t, testDB.Create(&pan).Error)
+	for field, value := range map[string]string{
+		"code": "00042", "sku": "00042", "name": "Pan", "description": "A pan",
+		"price": "12.00", "stock": "10", "category": "Kitchen",
+	} {
+		require.NoError(t, testDB.Create(&models.ProductSyncValue{ProductID: pan.ID, Field: field, Value: value}).Error)
+	}
+
+	t.Run("Edits in the shop are recorded as the shop's", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/products/%d", pan.ID),
+			bytes.NewBufferString(`{"description": "A pan marketing rewrote", "synced_at": "2020-01-01T00:00:00Z"}`))
+		authorize(req, uuid.New(), models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var product models.Product
+		require.NoError(t, testDB.First(&product, pan.ID).Error)
+		assert.Equal(t, models.ProductSourceShop, product.ModifiedBy)
+		require.NotNil(t, product.ModifiedAt)
+		assert.WithinDuration(t, time.Now(), *product.ModifiedAt, 5*time.Second)
+		assert.Nil(t, product.SyncedAt)
+	})
+
+	t.Run("A dry run shows the owners winning the conflicts", func(t *testing.T) {
+		diff, err := service.PreviewProducts(context.Background(), true)
+		require.NoError(t, err)
+
+		require.Len(t, diff.Conflicts, 1)
+		assert.Equal(t, "description", diff.Conflicts[0].Field)
+		assert.Equal(t, "A pan marketing rewrote", diff.Conflicts[0].ShopValue)
+		assert.Equal(t, "A pan from 1C", diff.Conflicts[0].OneCValue)
+		assert.Equal(t, models.ProductSourceShop, diff.Conflicts[0].Winner)
+		require.Len(t, diff.PriceChanges, 1)
+		assert.Equal(t, "13.50", diff.PriceChanges[0].NewPrice.String())
+
+		var count int64
+		testDB.Model(&models.SyncConflict{}).Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Stock changes from 1C move the synced stock too", func(t *testing.T) {
+		run, err := service.SyncStock(context.Background(), models.SyncTriggerWorker)
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Updated)
+
+		var product models.Product
+		require.NoError(t, testDB.First(&product, pan.ID).Error)
+		assert.Equal(t, 8, product.Stock)
+		assert.Equal(t, models.ProductSourceOneC, product.ModifiedBy)
+		var synced models.ProductSyncValue
+		require.NoError(t, testDB.Where("product_id = ? AND field = 'stock'", pan.ID).First(&synced).Error)
+		assert.Equal(t, "8", synced.Value)
+	})
+}
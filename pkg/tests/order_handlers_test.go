@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,14 +19,15 @@ func TestCreateOrder(t *testing.T) {
 
 	// Create test user and product
 	user := models.User{
-		Name:  "Test User",
-		Email: "test@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Email:     "test@example.com",
 	}
 	testDB.Create(&user)
 
 	product := models.Product{
 		Name:  "Test Product",
-		Price: 99.99,
+		Price: models.MustParseMoney("99.99"),
 		Stock: 100,
 		SKU:   "TEST-SKU-001",
 	}
@@ -44,6 +47,7 @@ func TestCreateOrder(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusCreated, w.Code)
@@ -53,13 +57,78 @@ func TestCreateOrder(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotZero(t, response.ID)
 		assert.Equal(t, models.OrderStatusPending, response.Status)
-		assert.Equal(t, 199.98, response.Total) // 2 * 99.99
+		assert.Equal(t, models.MustParseMoney("199.98"), response.Total) // 2 * 99.99
 		assert.Len(t, response.Items, 1)
 
-		// Verify stock was updated
+		// Verify stock was reserved, not yet deducted
 		var updatedProduct models.Product
 		testDB.First(&updatedProduct, product.ID)
-		assert.Equal(t, 98, updatedProduct.Stock)
+		assert.Equal(t, 100, updatedProduct.Stock)
+		assert.Equal(t, 2, updatedProduct.Reserved)
+	})
+
+	t.Run("Prices and owner come from the shop", func(t *testing.T) {
+		other := models.User{FirstName: "Other", LastName: "User", Email: "other@example.com"}
+		testDB.Create(&other)
+
+		jsonValue, _ := json.Marshal(gin.H{
+			"user_id": other.ID,
+			"user":    gin.H{"id": other.ID},
+			"status":  "paid",
+			"total":   "0.01",
+			"items": []gin.H{{
+				"product_id": product.ID,
+				"quantity":   1,
+				"price":      "0.01",
+				"product":    gin.H{"id": product.ID, "price": "0.01"},
+			}},
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response models.Order
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, response.UserID)
+		assert.Equal(t, models.OrderStatusPending, response.Status)
+		assert.Equal(t, models.MustParseMoney("99.99"), response.Total)
+
+		var unchanged models.Product
+		testDB.First(&unchanged, product.ID)
+		assert.Equal(t, models.MustParseMoney("99.99"), unchanged.Price)
+	})
+
+	t.Run("Shipments cannot be sent with the order", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(gin.H{
+			"items": []gin.H{{"product_id": product.ID, "quantity": 1}},
+			"shipments": []gin.H{{
+				"carrier":       "forged",
+				"tracking_code": "FORGED-1",
+				"status":        models.ShipmentDelivered,
+			}},
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response models.Order
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.NotZero(t, response.ID)
+		assert.Empty(t, response.Shipments)
+
+		var count int64
+		testDB.Model(&models.Shipment{}).Where("order_id = ?", response.ID).Count(&count)
+		assert.Zero(t, count)
 	})
 
 	t.Run("Insufficient stock", func(t *testing.T) {
@@ -76,6 +145,7 @@ func TestCreateOrder(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
@@ -96,6 +166,7 @@ func TestCreateOrder(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
@@ -108,14 +179,15 @@ func TestGetOrders(t *testing.T) {
 
 	// Create test user and product
 	user := models.User{
-		Name:  "Test User",
-		Email: "test@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Email:     "test@example.com",
 	}
 	testDB.Create(&user)
 
 	product := models.Product{
 		Name:  "Test Product",
-		Price: 99.99,
+		Price: models.MustParseMoney("99.99"),
 		Stock: 100,
 		SKU:   "TEST-SKU-001",
 	}
@@ -126,12 +198,12 @@ func TestGetOrders(t *testing.T) {
 		order := models.Order{
 			UserID: user.ID,
 			Status: models.OrderStatusPending,
-			Total:  float64(i) * 99.99,
+			Total:  models.MustParseMoney("99.99").Mul(i),
 			Items: []models.OrderItem{
 				{
 					ProductID: product.ID,
 					Quantity:  i,
-					Price:    99.99,
+					Price:     models.MustParseMoney("99.99"),
 				},
 			},
 		}
@@ -141,18 +213,19 @@ func TestGetOrders(t *testing.T) {
 	t.Run("Get all orders", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/api/orders", nil)
+		authorize(req, user.ID, models.RoleCustomer)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var response struct {
 			Orders []struct {
-				OrderID   uint    `json:"order_id"`
-				UserID    uint    `json:"user_id"`
-				UserName  string  `json:"user_name"`
-				Status    string  `json:"status"`
-				Total     float64 `json:"total"`
-				ItemCount int     `json:"item_count"`
+				OrderID   uint         `json:"order_id"`
+				UserID    uuid.UUID    `json:"user_id"`
+				UserName  string       `json:"user_name"`
+				Status    string       `json:"status"`
+				Total     models.Money `json:"total"`
+				ItemCount int          `json:"item_count"`
 			} `json:"orders"`
 			Pagination struct {
 				TotalItems int64 `json:"total_items"`
@@ -163,7 +236,7 @@ func TestGetOrders(t *testing.T) {
 		assert.Equal(t, int64(5), response.Pagination.TotalItems)
 		for _, order := range response.Orders {
 			assert.Equal(t, user.ID, order.UserID)
-			assert.Equal(t, user.Name, order.UserName)
+			assert.Equal(t, "Test User", order.UserName)
 			assert.Equal(t, string(models.OrderStatusPending), order.Status)
 			assert.Equal(t, 1, order.ItemCount)
 		}
@@ -171,14 +244,15 @@ func TestGetOrders(t *testing.T) {
 
 	t.Run("Filter by user", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/orders?user_id=%d", user.ID), nil)
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/orders?user_id=%s", user.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var response struct {
 			Orders []struct {
-				UserID uint `json:"user_id"`
+				UserID uuid.UUID `json:"user_id"`
 			} `json:"orders"`
 		}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
@@ -193,17 +267,17 @@ func TestUpdateOrderStatus(t *testing.T) {
 	clearTables()
 
 	// Create test order
-	user := models.User{Name: "Test User", Email: "test@example.com"}
+	user := models.User{FirstName: "Test", LastName: "User", Email: "test@example.com"}
 	testDB.Create(&user)
 
 	order := models.Order{
 		UserID: user.ID,
 		Status: models.OrderStatusPending,
-		Total:  99.99,
+		Total:  models.MustParseMoney("99.99"),
 	}
 	testDB.Create(&order)
 
-	t.Run("Valid status transition", func(t *testing.T) {
+	t.Run("Staff cannot mark an order paid", func(t *testing.T) {
 		statusUpdate := struct {
 			Status models.OrderStatus `json:"status"`
 		}{
@@ -213,25 +287,49 @@ func TestUpdateOrderStatus(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/orders/%d/status", order.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var updatedOrder models.Order
+		testDB.First(&updatedOrder, order.ID)
+		assert.Equal(t, models.OrderStatusPending, updatedOrder.Status)
+	})
+
+	t.Run("Valid status transition", func(t *testing.T) {
+		markPaid(t, order.ID, "")
+
+		statusUpdate := struct {
+			Status models.OrderStatus `json:"status"`
+		}{
+			Status: models.OrderStatusShipped,
+		}
+		jsonValue, _ := json.Marshal(statusUpdate)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/orders/%d/status", order.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var updatedOrder models.Order
 		testDB.First(&updatedOrder, order.ID)
-		assert.Equal(t, models.OrderStatusPaid, updatedOrder.Status)
+		assert.Equal(t, models.OrderStatusShipped, updatedOrder.Status)
 	})
 
 	t.Run("Invalid status transition", func(t *testing.T) {
 		statusUpdate := struct {
 			Status models.OrderStatus `json:"status"`
 		}{
-			Status: models.OrderStatusDelivered, // Cannot go directly from paid to delivered
+			Status: models.OrderStatusPaid, // Cannot go back from shipped to paid
 		}
 		jsonValue, _ := json.Marshal(statusUpdate)
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/orders/%d/status", order.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
@@ -243,12 +341,12 @@ func TestCancelOrder(t *testing.T) {
 	clearTables()
 
 	// Create test data
-	user := models.User{Name: "Test User", Email: "test@example.com"}
+	user := models.User{FirstName: "Test", LastName: "User", Email: "test@example.com"}
 	testDB.Create(&user)
 
 	product := models.Product{
 		Name:  "Test Product",
-		Price: 99.99,
+		Price: models.MustParseMoney("99.99"),
 		Stock: 100,
 		SKU:   "TEST-SKU-001",
 	}
@@ -257,12 +355,12 @@ func TestCancelOrder(t *testing.T) {
 	order := models.Order{
 		UserID: user.ID,
 		Status: models.OrderStatusPending,
-		Total:  199.98,
+		Total:  models.MustParseMoney("199.98"),
 		Items: []models.OrderItem{
 			{
 				ProductID: product.ID,
 				Quantity:  2,
-				Price:    99.99,
+				Price:     models.MustParseMoney("99.99"),
 			},
 		},
 	}
@@ -274,6 +372,7 @@ func TestCancelOrder(t *testing.T) {
 	t.Run("Valid cancellation", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/cancel", order.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
@@ -292,9 +391,10 @@ func TestCancelOrder(t *testing.T) {
 	t.Run("Already cancelled order", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/cancel", order.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 		assert.Contains(t, w.Body.String(), "Order is already cancelled")
 	})
-} 
\ No newline at end of file
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderStatusHistory(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "history@example.com", FirstName: "History", LastName: "User"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	product := models.Product{
+		Name:  "Test Product",
+		Price: models.MustParseMoney("10.00"),
+		Stock: 10,
+		SKU:   "TEST-SKU-HIST",
+	}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 1}}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+	authorize(req, user.ID, models.RoleCustomer)
+	testRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+	markPaid(t, order.ID, "paid by bank transfer")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/cancel", order.ID), nil)
+	authorize(req, user.ID, models.RoleCustomer)
+	testRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("Owner sees every transition", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/orders/%d/history", order.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			History []models.OrderStatusHistory `json:"history"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.History, 3)
+
+		created, paid, cancelled := response.History[0], response.History[1], response.History[2]
+		assert.Nil(t, created.FromStatus)
+		assert.Equal(t, models.OrderStatusPending, created.ToStatus)
+		assert.Equal(t, models.ActorUser, created.ActorType)
+
+		require.NotNil(t, paid.FromStatus)
+		assert.Equal(t, models.OrderStatusPending, *paid.FromStatus)
+		assert.Equal(t, models.OrderStatusPaid, paid.ToStatus)
+		assert.Equal(t, models.ActorOneC, paid.ActorType)
+		assert.Nil(t, paid.ActorID)
+		assert.Equal(t, "paid by bank transfer", paid.Reason)
+
+		assert.Equal(t, models.OrderStatusCancelled, cancelled.ToStatus)
+		assert.Equal(t, &user.ID, cancelled.ActorID)
+	})
+
+	t.Run("Cancellation restored stock", func(t *testing.T) {
+		var stored models.Product
+		require.NoError(t, testDB.First(&stored, product.ID).Error)
+		assert.Equal(t, 10, stored.Stock)
+	})
+
+	t.Run("Other customers cannot see it", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/orders/%d/history", order.ID), nil)
+		authorize(req, uuid.New(), models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
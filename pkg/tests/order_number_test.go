@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderNumbers(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "numbered@example.com", FirstName: "Num", LastName: "Bered"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	product := models.Product{Name: "Spoon", Price: models.MustParseMoney("3.00"), Stock: 100, SKU: "NUM-SPOON"}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	placeOrder := func() (models.Order, int) {
+		jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 1}}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		var order models.Order
+		json.Unmarshal(w.Body.Bytes(), &order)
+		return order, w.Code
+	}
+
+	t.Run("Concurrent orders get consecutive numbers", func(t *testing.T) {
+		const count = 8
+		numbers := make([]string, count)
+		var wg sync.WaitGroup
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				order, code := placeOrder()
+				if code == http.StatusCreated {
+					numbers[i] = order.Number
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		sort.Strings(numbers)
+		assert.Equal(t, []string{
+			"ORD-000001", "ORD-000002", "ORD-000003", "ORD-000004",
+			"ORD-000005", "ORD-000006", "ORD-000007", "ORD-000008",
+		}, numbers)
+	})
+
+	t.Run("Orders are found by number", func(t *testing.T) {
+		order, code := placeOrder()
+		require.Equal(t, http.StatusCreated, code)
+		assert.Equal(t, "ORD-000009", order.Number)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/orders?number=ord-000009", nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Orders []struct {
+				OrderID uint   `json:"order_id"`
+				Number  string `json:"number"`
+			} `json:"orders"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Orders, 1)
+		assert.Equal(t, order.ID, response.Orders[0].OrderID)
+		assert.Equal(t, "ORD-000009", response.Orders[0].Number)
+	})
+}
@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayments(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "payer@example.com", FirstName: "Pay", LastName: "Er"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	product := models.Product{Name: "Kettle", Price: models.MustParseMoney("25.00"), Stock: 10, SKU: "PAY-KETTLE"}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	placeOrder := func() models.Order {
+		jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 2}}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+		return order
+	}
+	startPayment := func(orderID uint) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/payments", orderID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	deliver := func(payload []byte, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/payments/webhook", bytes.NewBuffer(payload))
+		req.Header = header
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	orderStatus := func(orderID uint) models.OrderStatus {
+		var order models.Order
+		require.NoError(t, testDB.First(&order, orderID).Error)
+		return order.Status
+	}
+
+	order := placeOrder()
+	var payment models.Payment
+
+	t.Run("Start a payment", func(t *testing.T) {
+		w := startPayment(order.ID)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &payment))
+		assert.Equal(t, models.PaymentPending, payment.Status)
+		assert.Equal(t, models.MustParseMoney("50.00"), payment.Amount)
+		assert.NotEmpty(t, payment.ClientSecret)
+	})
+
+	t.Run("Forged webhook is rejected", func(t *testing.T) {
+		payload, header := testPayments.Authorize(payment.IntentID, payment.Amount, payment.Currency)
+		header.Set("X-Fake-Signature", "00")
+		w := deliver(payload, header)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, models.OrderStatusPending, orderStatus(order.ID))
+	})
+
+	t.Run("Authorised payment pays the order", func(t *testing.T) {
+		payload, header := testPayments.Authorize(payment.IntentID, payment.Amount, payment.Currency)
+		w := deliver(payload, header)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.OrderStatusPaid, orderStatus(order.ID))
+
+		// Paying committed the reserved stock
+		var stored models.Product
+		require.NoError(t, testDB.First(&stored, product.ID).Error)
+		assert.Equal(t, 8, stored.Stock)
+		assert.Equal(t, 0, stored.Reserved)
+
+		var history models.OrderStatusHistory
+		require.NoError(t, testDB.Where("order_id = ? AND to_status = ?", order.ID, models.OrderStatusPaid).First(&history).Error)
+		assert.Equal(t, models.ActorPayment, history.ActorType)
+
+		// Redelivery changes nothing
+		w = deliver(payload, header)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Paid orders cannot be paid again", func(t *testing.T) {
+		w := startPayment(order.ID)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Declined payment leaves the order pending", func(t *testing.T) {
+		other := placeOrder()
+		w := startPayment(other.ID)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var declined models.Payment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &declined))
+
+		w = deliver(testPayments.Decline(declined.IntentID, "card declined"))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.OrderStatusPending, orderStatus(other.ID))
+
+		require.NoError(t, testDB.First(&declined, declined.ID).Error)
+		assert.Equal(t, models.PaymentFailed, declined.Status)
+		assert.Equal(t, "card declined", declined.FailureReason)
+	})
+
+	t.Run("Authorisation for a cancelled order is not captured", func(t *testing.T) {
+		other := placeOrder()
+		w := startPayment(other.ID)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var late models.Payment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &late))
+
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/cancel", other.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = deliver(testPayments.Authorize(late.IntentID, late.Amount, late.Currency))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.OrderStatusCancelled, orderStatus(other.ID))
+
+		require.NoError(t, testDB.First(&late, late.ID).Error)
+		assert.Equal(t, models.PaymentFailed, late.Status)
+	})
+
+	t.Run("Staff refund captured payments", func(t *testing.T) {
+		refund := func(body gin.H) *httptest.ResponseRecorder {
+			jsonValue, _ := json.Marshal(body)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/api/payments/%d/refund", payment.ID), bytes.NewBuffer(jsonValue))
+			authorize(req, uuid.New(), models.RoleAdmin)
+			testRouter.ServeHTTP(w, req)
+			return w
+		}
+
+		w := refund(gin.H{"amount": "20.00"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var refunded models.Refund
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refunded))
+		assert.Equal(t, models.MustParseMoney("20.00"), refunded.Amount)
+		assert.NotEmpty(t, refunded.ProviderReference)
+		require.NotNil(t, refunded.Payment)
+		assert.Equal(t, models.PaymentPartiallyRefunded, refunded.Payment.Status)
+
+		w = refund(gin.H{"amount": "40.00"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = refund(gin.H{})
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refunded))
+		assert.Equal(t, models.MustParseMoney("30.00"), refunded.Amount)
+		assert.Equal(t, models.PaymentRefunded, refunded.Payment.Status)
+		assert.Equal(t, models.MustParseMoney("50.00"), refunded.Payment.RefundedAmount)
+	})
+
+	t.Run("Cancelling a paid order refunds it", func(t *testing.T) {
+		other := placeOrder()
+		w := startPayment(other.ID)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var paid models.Payment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &paid))
+		w = deliver(testPayments.Authorize(paid.IntentID, paid.Amount, paid.Currency))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, models.OrderStatusPaid, orderStatus(other.ID))
+
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/cancel", other.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.OrderStatusCancelled, orderStatus(other.ID))
+
+		require.NoError(t, testDB.First(&paid, paid.ID).Error)
+		assert.Equal(t, models.PaymentRefunded, paid.Status)
+		assert.Equal(t, other.Total, paid.RefundedAmount)
+
+		var refunds []models.Refund
+		require.NoError(t, testDB.Where("order_id = ?", other.ID).Find(&refunds).Error)
+		require.Len(t, refunds, 1)
+		assert.Equal(t, other.Total, refunds[0].Amount)
+		assert.NotEmpty(t, refunds[0].ProviderReference)
+	})
+}
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,7 +20,7 @@ func TestCreateProduct(t *testing.T) {
 		product := models.Product{
 			Name:        "Test Product",
 			Description: "Test Description",
-			Price:       99.99,
+			Price:       models.MustParseMoney("99.99"),
 			SKU:         "TEST-SKU-001",
 			Stock:       100,
 		}
@@ -27,6 +28,7 @@ func TestCreateProduct(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("POST", "/api/products", bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusCreated, w.Code)
@@ -40,6 +42,34 @@ func TestCreateProduct(t *testing.T) {
 		assert.Equal(t, product.SKU, response.SKU)
 	})
 
+	t.Run("Server-managed fields are ignored", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(map[string]interface{}{
+			"id":          4242,
+			"name":        "Managed Product",
+			"price":       "10.00",
+			"sku":         "TEST-SKU-002",
+			"stock":       5,
+			"reserved":    3,
+			"external_id": "1c-guid",
+			"modified_by": "1c",
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/products", bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response models.Product
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEqual(t, uint(4242), response.ID)
+		assert.Equal(t, 5, response.Stock)
+		assert.Zero(t, response.Reserved)
+		assert.Empty(t, response.ExternalID)
+		assert.Equal(t, models.ProductSourceShop, response.ModifiedBy)
+	})
+
 	t.Run("Invalid product data", func(t *testing.T) {
 		invalidProduct := struct {
 			Name string `json:"name"`
@@ -50,6 +80,7 @@ func TestCreateProduct(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("POST", "/api/products", bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
@@ -64,7 +95,7 @@ func TestGetProducts(t *testing.T) {
 		product := models.Product{
 			Name:        fmt.Sprintf("Product %d", i),
 			Description: fmt.Sprintf("Description %d", i),
-			Price:       float64(i) * 10.0,
+			Price:       models.MoneyFromCents(int64(i) * 1000),
 			SKU:         fmt.Sprintf("SKU-%d", i),
 			Stock:       i * 10,
 		}
@@ -82,11 +113,11 @@ func TestGetProducts(t *testing.T) {
 			Products []struct {
 				ID          uint    `json:"id"`
 				Name        string  `json:"name"`
-				Price       float64 `json:"price"`
+				Price       models.Money `json:"price"`
 				Stock       int     `json:"stock"`
 				TotalOrders int     `json:"total_orders"`
 				TotalSold   int     `json:"total_sold"`
-				Revenue     float64 `json:"revenue"`
+				Revenue     models.Money `json:"revenue"`
 			} `json:"products"`
 			Pagination struct {
 				CurrentPage   int   `json:"current_page"`
@@ -111,14 +142,14 @@ func TestGetProducts(t *testing.T) {
 
 		var response struct {
 			Products []struct {
-				Price float64 `json:"price"`
+				Price models.Money `json:"price"`
 			} `json:"products"`
 		}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
 		for _, product := range response.Products {
-			assert.GreaterOrEqual(t, product.Price, 20.0)
-			assert.LessOrEqual(t, product.Price, 50.0)
+			assert.GreaterOrEqual(t, product.Price.Cmp(models.MustParseMoney("20")), 0)
+			assert.LessOrEqual(t, product.Price.Cmp(models.MustParseMoney("50")), 0)
 		}
 	})
 
@@ -142,6 +173,67 @@ func TestGetProducts(t *testing.T) {
 	})
 }
 
+func TestUpdateProduct(t *testing.T) {
+	clearTables()
+
+	// Create test product
+	product := models.Product{
+		ExternalID:  "1c-product",
+		Name:        "Test Product",
+		Description: "Test Description",
+		Price:       models.MustParseMoney("99.99"),
+		SKU:         "TEST-SKU-001",
+		Stock:       100,
+		Reserved:    5,
+	}
+	testDB.Create(&product)
+
+	t.Run("Editable fields are updated", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(map[string]interface{}{
+			"name":  "Renamed Product",
+			"price": "89.99",
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/products/%d", product.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleManager)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var updatedProduct models.Product
+		testDB.First(&updatedProduct, product.ID)
+		assert.Equal(t, "Renamed Product", updatedProduct.Name)
+		assert.Equal(t, models.MustParseMoney("89.99"), updatedProduct.Price)
+		assert.Equal(t, "Test Description", updatedProduct.Description)
+		assert.Equal(t, models.ProductSourceShop, updatedProduct.ModifiedBy)
+	})
+
+	t.Run("Other fields are ignored", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(map[string]interface{}{
+			"id":          product.ID + 1000,
+			"external_id": "forged",
+			"stock":       0,
+			"reserved":    0,
+			"modified_by": models.ProductSourceOneC,
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/products/%d", product.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleManager)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var updatedProduct models.Product
+		testDB.First(&updatedProduct, product.ID)
+		assert.Equal(t, "1c-product", updatedProduct.ExternalID)
+		assert.Equal(t, 100, updatedProduct.Stock)
+		assert.Equal(t, 5, updatedProduct.Reserved)
+		assert.Equal(t, models.ProductSourceShop, updatedProduct.ModifiedBy)
+	})
+}
+
 func TestUpdateStock(t *testing.T) {
 	clearTables()
 
@@ -149,7 +241,7 @@ func TestUpdateStock(t *testing.T) {
 	product := models.Product{
 		Name:        "Test Product",
 		Description: "Test Description",
-		Price:       99.99,
+		Price:       models.MustParseMoney("99.99"),
 		SKU:         "TEST-SKU-001",
 		Stock:       100,
 	}
@@ -165,6 +257,7 @@ func TestUpdateStock(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/products/%d/stock", product.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
@@ -175,6 +268,24 @@ func TestUpdateStock(t *testing.T) {
 		assert.Equal(t, 50, updatedProduct.Stock)
 	})
 
+	t.Run("Stock below the reserved quantity", func(t *testing.T) {
+		testDB.Model(&product).Update("reserved", 20)
+		defer testDB.Model(&product).Update("reserved", 0)
+
+		jsonValue, _ := json.Marshal(map[string]int{"quantity": 10})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/products/%d/stock", product.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var updatedProduct models.Product
+		testDB.First(&updatedProduct, product.ID)
+		assert.Equal(t, 50, updatedProduct.Stock)
+	})
+
 	t.Run("Invalid stock quantity", func(t *testing.T) {
 		stockUpdate := struct {
 			Quantity string `json:"quantity"`
@@ -185,6 +296,7 @@ func TestUpdateStock(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/products/%d/stock", product.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
@@ -200,6 +312,7 @@ func TestUpdateStock(t *testing.T) {
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("PUT", "/api/products/9999/stock", bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
@@ -213,7 +326,7 @@ func TestDeleteProduct(t *testing.T) {
 	product := models.Product{
 		Name:        "Test Product",
 		Description: "Test Description",
-		Price:       99.99,
+		Price:       models.MustParseMoney("99.99"),
 		SKU:         "TEST-SKU-001",
 		Stock:       100,
 	}
@@ -222,6 +335,7 @@ func TestDeleteProduct(t *testing.T) {
 	t.Run("Valid deletion", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/products/%d", product.ID), nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNoContent, w.Code)
@@ -236,8 +350,18 @@ func TestDeleteProduct(t *testing.T) {
 	t.Run("Non-existent product", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("DELETE", "/api/products/9999", nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
+
+	t.Run("Non-numeric ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/products/id%20%3E%200", nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 } 
\ No newline at end of file
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromotions(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "promo@example.com", FirstName: "Promo", LastName: "User"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	book := models.Product{Name: "Book", Price: models.MustParseMoney("10.00"), Stock: 100, SKU: "PROMO-BOOK", Category: "books"}
+	lamp := models.Product{Name: "Lamp", Price: models.MustParseMoney("50.00"), Stock: 100, SKU: "PROMO-LAMP", Category: "home"}
+	require.NoError(t, testDB.Create(&book).Error)
+	require.NoError(t, testDB.Create(&lamp).Error)
+
+	adminID := uuid.New()
+	createPromotion := func(body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/promotions", bytes.NewBuffer(jsonValue))
+		authorize(req, adminID, models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	placeOrder := func(coupon string) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(gin.H{
+			"coupon_code": coupon,
+			"items": []gin.H{
+				{"product_id": book.ID, "quantity": 3},
+				{"product_id": lamp.ID, "quantity": 1},
+			},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Customers cannot manage promotions", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/promotions", nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Invalid promotions are rejected", func(t *testing.T) {
+		w := createPromotion(gin.H{"name": "Broken", "type": "percentage", "percent": 150})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	var coupon models.Promotion
+	t.Run("Coupon discounts the order", func(t *testing.T) {
+		w := createPromotion(gin.H{
+			"name":           "Ten percent off",
+			"code":           "save10",
+			"type":           "percentage",
+			"percent":        10,
+			"min_basket":     "50.00",
+			"per_user_limit": 1,
+		})
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &coupon))
+		assert.Equal(t, "SAVE10", *coupon.Code)
+
+		w = createPromotion(gin.H{"name": "Duplicate", "code": "SAVE10", "type": "percentage", "percent": 5})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = placeOrder("save10")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+		assert.Equal(t, models.MustParseMoney("80.00"), order.Subtotal)
+		assert.Equal(t, models.MustParseMoney("72.00"), order.Total)
+		require.Len(t, order.Adjustments, 2)
+		for _, a := range order.Adjustments {
+			assert.Equal(t, "SAVE10", a.Code)
+			assert.NotNil(t, a.ProductID)
+		}
+	})
+
+	t.Run("Coupon errors", func(t *testing.T) {
+		w := placeOrder("NOPE")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid coupon code")
+
+		// The per-user limit of one is used up
+		w = placeOrder("SAVE10")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Coupon usage limit reached")
+	})
+
+	t.Run("Cancelling gives the coupon use back", func(t *testing.T) {
+		var order models.Order
+		require.NoError(t, testDB.Where("user_id = ?", user.ID).First(&order).Error)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/cancel", order.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var stored models.Promotion
+		require.NoError(t, testDB.First(&stored, coupon.ID).Error)
+		assert.Zero(t, stored.UsageCount)
+
+		w = placeOrder("SAVE10")
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("Automatic promotions apply without a code", func(t *testing.T) {
+		w := createPromotion(gin.H{
+			"name":         "Third book free",
+			"type":         "buy_x_get_y",
+			"buy_quantity": 2,
+			"get_quantity": 1,
+			"targets":      []gin.H{{"kind": "category", "value": "books"}},
+		})
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		w = placeOrder("")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+		assert.Equal(t, models.MustParseMoney("70.00"), order.Total)
+		require.Len(t, order.Adjustments, 1)
+		assert.Equal(t, book.ID, *order.Adjustments[0].ProductID)
+	})
+}
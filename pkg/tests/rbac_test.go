@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutePolicies(t *testing.T) {
+	clearTables()
+
+	product := models.Product{
+		Name:  "Test Product",
+		Price: models.MustParseMoney("99.99"),
+		Stock: 10,
+		SKU:   "TEST-SKU-RBAC",
+	}
+	testDB.Create(&product)
+
+	t.Run("Customer cannot delete products", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/products/%d", product.ID), nil)
+		authorize(req, uuid.New(), models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Manager cannot change stock", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/products/%d/stock", product.ID), nil)
+		authorize(req, uuid.New(), models.RoleManager)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Customer cannot list users", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/users", nil)
+		authorize(req, uuid.New(), models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Customer cannot read another user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/%s", uuid.New()), nil)
+		authorize(req, uuid.New(), models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
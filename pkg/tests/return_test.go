@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReturns(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "returner@example.com", FirstName: "Re", LastName: "Turner"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+	staffID := uuid.New()
+
+	product := models.Product{Name: "Toaster", Price: models.MustParseMoney("25.00"), Stock: 10, SKU: "RET-TOASTER"}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	call := func(method, path string, body interface{}, userID uuid.UUID, role models.Role) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			jsonValue, _ := json.Marshal(body)
+			buf.Write(jsonValue)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, &buf)
+		authorize(req, userID, role)
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	customer := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		return call(method, path, body, user.ID, models.RoleCustomer)
+	}
+	staff := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		return call(method, path, body, staffID, models.RoleAdmin)
+	}
+
+	// placeOrder places an order for two toasters, pays for it through the
+	// fake provider and, unless it should stay paid, delivers it
+	placeOrder := func(deliver bool) models.Order {
+		w := customer("POST", "/api/orders", gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 2}}})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+		w = customer("POST", fmt.Sprintf("/api/orders/%d/payments", order.ID), nil)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var payment models.Payment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &payment))
+
+		payload, header := testPayments.Authorize(payment.IntentID, payment.Amount, payment.Currency)
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/payments/webhook", bytes.NewBuffer(payload))
+		req.Header = header
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		if deliver {
+			for _, status := range []models.OrderStatus{models.OrderStatusShipped, models.OrderStatusDelivered} {
+				w = staff("PUT", fmt.Sprintf("/api/orders/%d/status", order.ID), gin.H{"status": status})
+				require.Equal(t, http.StatusOK, w.Code)
+			}
+		}
+
+		require.NoError(t, testDB.Preload("Items").First(&order, order.ID).Error)
+		return order
+	}
+	requestReturn := func(order models.Order, quantity int) *httptest.ResponseRecorder {
+		return customer("POST", fmt.Sprintf("/api/orders/%d/returns", order.ID), gin.H{
+			"reason": "does not toast evenly",
+			"items":  []gin.H{{"order_item_id": order.Items[0].ID, "quantity": quantity}},
+		})
+	}
+	orderStatus := func(orderID uint) models.OrderStatus {
+		var order models.Order
+		require.NoError(t, testDB.First(&order, orderID).Error)
+		return order.Status
+	}
+	stock := func() int {
+		var stored models.Product
+		require.NoError(t, testDB.First(&stored, product.ID).Error)
+		return stored.Stock
+	}
+
+	order := placeOrder(true)
+
+	t.Run("Undelivered orders cannot be returned", func(t *testing.T) {
+		paid := placeOrder(false)
+		w := requestReturn(paid, 1)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Quantities above the ordered ones are rejected", func(t *testing.T) {
+		w := requestReturn(order, 3)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Other customers cannot request returns", func(t *testing.T) {
+		w := call("POST", fmt.Sprintf("/api/orders/%d/returns", order.ID), gin.H{
+			"reason": "not mine",
+			"items":  []gin.H{{"order_item_id": order.Items[0].ID, "quantity": 1}},
+		}, uuid.New(), models.RoleCustomer)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Partial return refunds its items", func(t *testing.T) {
+		before := stock()
+
+		w := requestReturn(order, 1)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var ret models.Return
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+		assert.Equal(t, models.ReturnRequested, ret.Status)
+		require.Len(t, ret.Items, 1)
+		assert.Equal(t, models.MustParseMoney("25.00"), ret.Items[0].Amount)
+
+		// Goods cannot be received before the return is approved
+		w = staff("POST", fmt.Sprintf("/api/returns/%d/receive", ret.ID), gin.H{"restock": true})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = staff("POST", fmt.Sprintf("/api/returns/%d/approve", ret.ID), gin.H{"resolution": "send it back"})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = staff("POST", fmt.Sprintf("/api/returns/%d/receive", ret.ID), gin.H{"restock": true})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+		assert.Equal(t, models.ReturnCompleted, ret.Status)
+		assert.True(t, ret.Restocked)
+		require.NotNil(t, ret.Refund)
+		assert.Equal(t, models.MustParseMoney("25.00"), ret.Refund.Amount)
+		assert.NotNil(t, ret.Refund.PaymentID)
+
+		assert.Equal(t, before+1, stock())
+		assert.Equal(t, models.OrderStatusPartiallyRefunded, orderStatus(order.ID))
+
+		var payment models.Payment
+		require.NoError(t, testDB.Where("order_id = ?", order.ID).First(&payment).Error)
+		assert.Equal(t, models.PaymentPartiallyRefunded, payment.Status)
+		assert.Equal(t, models.MustParseMoney("25.00"), payment.RefundedAmount)
+	})
+
+	t.Run("Rejected returns free their quantities", func(t *testing.T) {
+		w := requestReturn(order, 1)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var ret models.Return
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+
+		// The only toaster left to return is claimed by the open request
+		w = requestReturn(order, 1)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = staff("POST", fmt.Sprintf("/api/returns/%d/reject", ret.ID), gin.H{"resolution": "used"})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+		assert.Equal(t, models.ReturnRejected, ret.Status)
+		assert.Equal(t, "used", ret.Resolution)
+	})
+
+	t.Run("Returning the rest refunds the order", func(t *testing.T) {
+		before := stock()
+
+		w := requestReturn(order, 1)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var ret models.Return
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+
+		w = staff("POST", fmt.Sprintf("/api/returns/%d/approve", ret.ID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		w = staff("POST", fmt.Sprintf("/api/returns/%d/receive", ret.ID), gin.H{"restock": false})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, before, stock())
+		assert.Equal(t, models.OrderStatusRefunded, orderStatus(order.ID))
+
+		var payment models.Payment
+		require.NoError(t, testDB.Where("order_id = ?", order.ID).First(&payment).Error)
+		assert.Equal(t, models.PaymentRefunded, payment.Status)
+		assert.Equal(t, models.MustParseMoney("50.00"), payment.RefundedAmount)
+
+		var history models.OrderStatusHistory
+		require.NoError(t, testDB.Where("order_id = ? AND to_status = ?", order.ID, models.OrderStatusRefunded).First(&history).Error)
+		assert.Equal(t, models.ActorAdmin, history.ActorType)
+	})
+
+	t.Run("Customers see their returns", func(t *testing.T) {
+		w := customer("GET", fmt.Sprintf("/api/orders/%d/returns", order.ID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Returns []models.Return `json:"returns"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Returns, 3)
+
+		w = customer("GET", "/api/returns", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = staff("GET", "/api/returns?status=completed", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Returns, 2)
+	})
+}
@@ -1,15 +1,22 @@
 package tests
 
 import (
-	"fullstacktest/pkg/database"
-	"fullstacktest/pkg/models"
-	"fullstacktest/pkg/router"
+	"github.com/alzarasatken/FullStackTest/migrations"
+	"github.com/alzarasatken/FullStackTest/pkg/database"
+	"github.com/alzarasatken/FullStackTest/pkg/middleware"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/orders/fsm"
+	"github.com/alzarasatken/FullStackTest/pkg/payments"
+	"github.com/alzarasatken/FullStackTest/pkg/router"
 	"log"
+	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/postgres"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -17,9 +24,20 @@ import (
 var testDB *gorm.DB
 var testRouter *gin.Engine
 
+const testJWTSecret = "test-secret"
+
+// testPayments is the router's payment provider; it signs the webhooks tests
+// deliver
+var testPayments = payments.NewFakeProvider("test-payment-secret")
+
+// testMachine is the order state machine shared by the router and the
+// background jobs under test, refunding through testPayments like in main
+var testMachine = fsm.NewOrderMachine(fsm.WithRefunds(testPayments))
+
 func TestMain(m *testing.M) {
 	// Set Gin to test mode
 	gin.SetMode(gin.TestMode)
+	os.Setenv("JWT_SECRET", testJWTSecret)
 
 	// Setup test database
 	setupTestDB()
@@ -37,39 +55,27 @@ func setupTestDB() {
 	// Configure test database connection
 	dsn := "host=localhost user=postgres password=postgres dbname=fullstacktest_test port=5432 sslmode=disable"
 	
-	var err error
-	testDB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
-	})
+	// Connect the way the API does, prepared statement cache included
+	db, err := database.Open(dsn)
 	if err != nil {
 		log.Fatal("Failed to connect to test database:", err)
 	}
+	testDB = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
 
-	// Migrate schema
-	err = testDB.AutoMigrate(
-		&models.User{},
-		&models.Product{},
-		&models.Order{},
-		&models.OrderItem{},
-	)
+	// Apply the same versioned migrations as production
+	migrator, err := database.NewMigrator(testDB, migrations.FS)
 	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
+	}
+	if _, err := migrator.Up(); err != nil {
 		log.Fatal("Failed to migrate test database:", err)
 	}
 
-	// Create indexes
-	testDB.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_order_items_order_product ON order_items (order_id, product_id);
-		CREATE INDEX IF NOT EXISTS idx_orders_user_created ON orders (user_id, created_at DESC);
-		CREATE INDEX IF NOT EXISTS idx_products_name_price ON products (name, price);
-		CREATE INDEX IF NOT EXISTS idx_active_orders ON orders (user_id, created_at DESC) 
-		WHERE status NOT IN ('delivered', 'cancelled');
-	`)
-
 	// Set the test DB for the application
 	database.DB = testDB
 
 	// Initialize the test router
-	testRouter = router.SetupRouter()
+	testRouter = router.SetupRouter(testPayments, testMachine)
 }
 
 func cleanupTestDB() {
@@ -93,4 +99,38 @@ func clearTables() {
 	testDB.Exec("TRUNCATE TABLE products CASCADE")
 	testDB.Exec("TRUNCATE TABLE orders CASCADE")
 	testDB.Exec("TRUNCATE TABLE order_items CASCADE")
-} 
\ No newline at end of file
+	testDB.Exec("TRUNCATE TABLE refresh_tokens CASCADE")
+	testDB.Exec("TRUNCATE TABLE exchange_rates CASCADE")
+	testDB.Exec("TRUNCATE TABLE order_status_history CASCADE")
+	testDB.Exec("TRUNCATE TABLE stock_reservations CASCADE")
+	testDB.Exec("TRUNCATE TABLE idempotency_keys CASCADE")
+	testDB.Exec("TRUNCATE TABLE carts CASCADE")
+	testDB.Exec("TRUNCATE TABLE promotions CASCADE")
+	testDB.Exec("TRUNCATE TABLE payments CASCADE")
+	testDB.Exec("TRUNCATE TABLE returns CASCADE")
+	testDB.Exec("TRUNCATE TABLE refunds CASCADE")
+	testDB.Exec("TRUNCATE TABLE shipments CASCADE")
+	testDB.Exec("TRUNCATE TABLE invoices CASCADE")
+	testDB.Exec("TRUNCATE TABLE number_sequences")
+	testDB.Exec("TRUNCATE TABLE sync_cursors")
+	testDB.Exec("TRUNCATE TABLE sync_runs")
+}
+
+// Helper function to attach a valid access token to a request
+func authorize(req *http.Request, userID uuid.UUID, role models.Role) {
+	token, err := middleware.GenerateToken(userID, string(role), testJWTSecret, time.Hour)
+	if err != nil {
+		log.Fatal("Failed to generate test token:", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+// markPaid moves an order to paid the way 1C does when it reports that the
+// order was paid outside the shop
+func markPaid(t *testing.T, orderID uint, reason string) {
+	var order models.Order
+	require.NoError(t, testDB.First(&order, orderID).Error)
+	require.NoError(t, testDB.Transaction(func(tx *gorm.DB) error {
+		return testMachine.Fire(tx, &order, models.OrderStatusPaid, models.OneCActor, reason)
+	}))
+}
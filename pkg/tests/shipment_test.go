@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/shipping"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestShipments(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "shipper@example.com", FirstName: "Ship", LastName: "Per"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	kettle := models.Product{Name: "Kettle", Price: models.MustParseMoney("25.00"), Stock: 10, SKU: "SHIP-KETTLE"}
+	require.NoError(t, testDB.Create(&kettle).Error)
+	mug := models.Product{Name: "Mug", Price: models.MustParseMoney("5.00"), Stock: 10, SKU: "SHIP-MUG"}
+	require.NoError(t, testDB.Create(&mug).Error)
+
+	call := func(method, path string, body interface{}, role models.Role) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			jsonValue, _ := json.Marshal(body)
+			buf.Write(jsonValue)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, &buf)
+		if role == models.RoleCustomer {
+			authorize(req, user.ID, role)
+		} else {
+			authorize(req, uuid.New(), role)
+		}
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	placeOrder := func() models.Order {
+		w := call("POST", "/api/orders", gin.H{"items": []gin.H{
+			{"product_id": kettle.ID, "quantity": 1},
+			{"product_id": mug.ID, "quantity": 4},
+		}}, models.RoleCustomer)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+		require.NoError(t, testDB.Preload("Items").First(&order, order.ID).Error)
+		return order
+	}
+	pay := func(order models.Order) {
+		w := call("POST", fmt.Sprintf("/api/orders/%d/payments", order.ID), nil, models.RoleCustomer)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var payment models.Payment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &payment))
+
+		payload, header := testPayments.Authorize(payment.IntentID, payment.Amount, payment.Currency)
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/payments/webhook", bytes.NewBuffer(payload))
+		req.Header = header
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	ship := func(order models.Order, items []gin.H) *httptest.ResponseRecorder {
+		var body interface{}
+		if items != nil {
+			body = gin.H{"items": items}
+		}
+		return call("POST", fmt.Sprintf("/api/orders/%d/shipments", order.ID), body, models.RoleAdmin)
+	}
+	orderStatus := func(orderID uint) models.OrderStatus {
+		var order models.Order
+		require.NoError(t, testDB.First(&order, orderID).Error)
+		return order.Status
+	}
+
+	order := placeOrder()
+
+	t.Run("Unpaid orders cannot be shipped", func(t *testing.T) {
+		w := ship(order, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	pay(order)
+	kettleLine, mugLine := order.Items[0], order.Items[1]
+	if kettleLine.ProductID != kettle.ID {
+		kettleLine, mugLine = mugLine, kettleLine
+	}
+
+	t.Run("Customers cannot ship", func(t *testing.T) {
+		w := call("POST", fmt.Sprintf("/api/orders/%d/shipments", order.ID), nil, models.RoleCustomer)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Partial shipment keeps the order paid", func(t *testing.T) {
+		w := ship(order, []gin.H{
+			{"order_item_id": kettleLine.ID, "quantity": 1},
+			{"order_item_id": mugLine.ID, "quantity": 2},
+		})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var shipment models.Shipment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shipment))
+		assert.Equal(t, "fake", shipment.Carrier)
+		assert.NotEmpty(t, shipment.TrackingCode)
+		assert.Equal(t, models.ShipmentInTransit, shipment.Status)
+		assert.Len(t, shipment.Items, 2)
+
+		assert.Equal(t, models.OrderStatusPaid, orderStatus(order.ID))
+	})
+
+	t.Run("Quantities above what is left are rejected", func(t *testing.T) {
+		w := ship(order, []gin.H{{"order_item_id": mugLine.ID, "quantity": 3}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Final shipment ships the order", func(t *testing.T) {
+		w := ship(order, nil)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var shipment models.Shipment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shipment))
+		require.Len(t, shipment.Items, 1)
+		assert.Equal(t, mugLine.ID, shipment.Items[0].OrderItemID)
+		assert.Equal(t, 2, shipment.Items[0].Quantity)
+
+		assert.Equal(t, models.OrderStatusShipped, orderStatus(order.ID))
+
+		// Nothing is left for another shipment
+		w = ship(order, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Order details include the shipments", func(t *testing.T) {
+		w := call("GET", fmt.Sprintf("/api/orders/%d", order.ID), nil, models.RoleCustomer)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Order models.Order `json:"order"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Order.Shipments, 2)
+		assert.Len(t, response.Order.Shipments[0].Items, 2)
+	})
+
+	t.Run("Polling delivers the order with its last parcel", func(t *testing.T) {
+		setStatus := func(tx *gorm.DB, order *models.Order, status models.OrderStatus) error {
+			return testMachine.Fire(tx, order, status, models.SystemActor, "delivered by carrier")
+		}
+
+		// Parcels of the router's carrier take days to arrive
+		inTransit := shipping.NewPoller(testDB, shipping.NewFakeCarrier(shipping.DefaultFakeTransit), time.Minute, setStatus)
+		n, err := inTransit.Poll(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, models.OrderStatusShipped, orderStatus(order.ID))
+
+		arrived := shipping.NewPoller(testDB, shipping.NewFakeCarrier(0), time.Minute, setStatus)
+		n, err = arrived.Poll(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, models.OrderStatusDelivered, orderStatus(order.ID))
+
+		var shipments []models.Shipment
+		require.NoError(t, testDB.Where("order_id = ?", order.ID).Find(&shipments).Error)
+		for _, shipment := range shipments {
+			assert.Equal(t, models.ShipmentDelivered, shipment.Status)
+			assert.NotNil(t, shipment.DeliveredAt)
+		}
+
+		var history models.OrderStatusHistory
+		require.NoError(t, testDB.Where("order_id = ? AND to_status = ?", order.ID, models.OrderStatusDelivered).First(&history).Error)
+		assert.Equal(t, models.ActorSystem, history.ActorType)
+	})
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/inventory"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockReservations(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "reserve@example.com", FirstName: "Reserve", LastName: "User"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	product := models.Product{
+		Name:  "Reserved Product",
+		Price: models.MustParseMoney("10.00"),
+		Stock: 5,
+		SKU:   "TEST-SKU-RESERVE",
+	}
+	require.NoError(t, testDB.Create(&product).Error)
+
+	placeOrder := func(quantity int) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": quantity}}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+	stock := func() (int, int) {
+		var stored models.Product
+		require.NoError(t, testDB.First(&stored, product.ID).Error)
+		return stored.Stock, stored.Reserved
+	}
+
+	t.Run("Reserved stock cannot be ordered again", func(t *testing.T) {
+		w := placeOrder(4)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		w = placeOrder(2)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var response struct {
+			Available int `json:"available"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.Available)
+
+		onHand, reserved := stock()
+		assert.Equal(t, 5, onHand)
+		assert.Equal(t, 4, reserved)
+	})
+
+	t.Run("Payment deducts and cancellation restores", func(t *testing.T) {
+		clearReservations(t, product.ID)
+
+		var order models.Order
+		w := placeOrder(2)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+		markPaid(t, order.ID, "")
+
+		onHand, reserved := stock()
+		assert.Equal(t, 3, onHand)
+		assert.Equal(t, 0, reserved)
+
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/orders/%d/cancel", order.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		onHand, reserved = stock()
+		assert.Equal(t, 5, onHand)
+		assert.Equal(t, 0, reserved)
+	})
+
+	t.Run("Expired reservations cancel the order", func(t *testing.T) {
+		clearReservations(t, product.ID)
+
+		var order models.Order
+		w := placeOrder(3)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+		require.NoError(t, testDB.Model(&models.StockReservation{}).
+			Where("order_id = ?", order.ID).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		sweeper := inventory.NewSweeper(testDB, time.Minute, testMachine.CancelExpired)
+		expired, err := sweeper.Sweep(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+
+		var stored models.Order
+		require.NoError(t, testDB.First(&stored, order.ID).Error)
+		assert.Equal(t, models.OrderStatusCancelled, stored.Status)
+
+		onHand, reserved := stock()
+		assert.Equal(t, 5, onHand)
+		assert.Equal(t, 0, reserved)
+	})
+
+	t.Run("Concurrent orders do not oversell", func(t *testing.T) {
+		clearReservations(t, product.ID)
+
+		var wg sync.WaitGroup
+		codes := make([]int, 10)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i] = placeOrder(1).Code
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, code := range codes {
+			if code == http.StatusCreated {
+				created++
+			}
+		}
+		assert.Equal(t, 5, created)
+
+		onHand, reserved := stock()
+		assert.Equal(t, 5, onHand)
+		assert.Equal(t, 5, reserved)
+	})
+}
+
+// clearReservations resets the product to hold no reservations
+func clearReservations(t *testing.T, productID uint) {
+	require.NoError(t, testDB.Exec("DELETE FROM stock_reservations").Error)
+	require.NoError(t, testDB.Model(&models.Product{}).Where("id = ?", productID).Update("reserved", 0).Error)
+}
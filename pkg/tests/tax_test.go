@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderTaxes(t *testing.T) {
+	clearTables()
+
+	user := models.User{Email: "tax@example.com", FirstName: "Tax", LastName: "User"}
+	require.NoError(t, user.SetPassword("secret123"))
+	require.NoError(t, testDB.Create(&user).Error)
+
+	lamp := models.Product{Name: "Lamp", Price: models.MustParseMoney("122.00"), Stock: 10, SKU: "TAX-LAMP"}
+	bread := models.Product{Name: "Bread", Price: models.MustParseMoney("11.00"), Stock: 10, SKU: "TAX-BREAD", TaxClass: models.TaxClassReduced}
+	require.NoError(t, testDB.Create(&lamp).Error)
+	require.NoError(t, testDB.Create(&bread).Error)
+
+	adminID := uuid.New()
+	placeOrder := func(region string) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(gin.H{
+			"tax_region": region,
+			"items": []gin.H{
+				{"product_id": lamp.ID, "quantity": 1},
+				{"product_id": bread.ID, "quantity": 2},
+			},
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/orders", bytes.NewBuffer(jsonValue))
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		return w
+	}
+
+	var order models.Order
+	t.Run("Orders carry a VAT breakdown", func(t *testing.T) {
+		w := placeOrder("")
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+		assert.Equal(t, models.DefaultTaxRegion, order.TaxRegion)
+		assert.Equal(t, models.MustParseMoney("144.00"), order.Total)
+		assert.Equal(t, models.MustParseMoney("120.00"), order.NetTotal)
+		assert.Equal(t, models.MustParseMoney("24.00"), order.TaxTotal)
+
+		require.Len(t, order.Items, 2)
+		for _, item := range order.Items {
+			switch item.ProductID {
+			case lamp.ID:
+				assert.Equal(t, 22, item.TaxPercent)
+				assert.Equal(t, models.MustParseMoney("22.00"), item.Tax)
+			case bread.ID:
+				assert.Equal(t, 10, item.TaxPercent)
+				assert.Equal(t, models.MustParseMoney("2.00"), item.Tax)
+			}
+		}
+	})
+
+	t.Run("Order details total taxes per rate", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/orders/%d", order.ID), nil)
+		authorize(req, user.ID, models.RoleCustomer)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Taxes []models.TaxLine `json:"taxes"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Taxes, 2)
+		assert.Equal(t, models.TaxLine{
+			Percent: 22,
+			Net:     models.MustParseMoney("100.00"),
+			Tax:     models.MustParseMoney("22.00"),
+			Gross:   models.MustParseMoney("122.00"),
+		}, response.Taxes[0])
+		assert.Equal(t, 10, response.Taxes[1].Percent)
+	})
+
+	t.Run("Unknown region", func(t *testing.T) {
+		w := placeOrder("XX")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Unsupported tax region")
+	})
+
+	t.Run("Rates are configured per region", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(gin.H{"region": "kz", "tax_class": "standard", "percent": 12})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/tax-rates", bytes.NewBuffer(jsonValue))
+		authorize(req, adminID, models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		t.Cleanup(func() { testDB.Where("region = ?", "KZ").Delete(&models.TaxRate{}) })
+
+		// Bread has no reduced rate in KZ
+		w = placeOrder("KZ")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "No tax rate for product")
+	})
+
+	t.Run("Products need a known tax class", func(t *testing.T) {
+		jsonValue, _ := json.Marshal(gin.H{"name": "Yacht", "price": "1000.00", "sku": "TAX-YACHT", "tax_class": "luxury"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/products", bytes.NewBuffer(jsonValue))
+		authorize(req, adminID, models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"fullstacktest/pkg/models"
+	"github.com/alzarasatken/FullStackTest/pkg/models"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,9 +18,12 @@ func TestCreateUser(t *testing.T) {
 
 	// Test case 1: Valid user creation
 	t.Run("Valid user creation", func(t *testing.T) {
-		user := models.User{
-			Name:  "Test User",
-			Email: "test@example.com",
+		user := models.UserInput{
+			Email:     "test@example.com",
+			Password:  "secret123",
+			FirstName: "Test",
+			LastName:  "User",
+			Phone:     "+79990000000",
 		}
 		jsonValue, _ := json.Marshal(user)
 
@@ -33,16 +37,17 @@ func TestCreateUser(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
 		assert.NotZero(t, response.ID)
-		assert.Equal(t, user.Name, response.Name)
+		assert.Equal(t, user.FirstName, response.FirstName)
+		assert.Equal(t, user.LastName, response.LastName)
 		assert.Equal(t, user.Email, response.Email)
 	})
 
 	// Test case 2: Invalid user data
 	t.Run("Invalid user data", func(t *testing.T) {
 		invalidUser := struct {
-			Name string `json:"name"`
+			FirstName string `json:"first_name"`
 		}{
-			Name: "Invalid User",
+			FirstName: "Invalid",
 		}
 		jsonValue, _ := json.Marshal(invalidUser)
 
@@ -60,24 +65,43 @@ func TestGetUsers(t *testing.T) {
 	// Create test users
 	for i := 1; i <= 15; i++ {
 		user := models.User{
-			Name:  fmt.Sprintf("User %d", i),
-			Email: fmt.Sprintf("user%d@example.com", i),
+			FirstName: "User",
+			LastName:  fmt.Sprintf("%d", i),
+			Email:     fmt.Sprintf("user%d@example.com", i),
 		}
 		testDB.Create(&user)
 	}
 
-	// Test case 1: Get first page of users
+	// Test case 1: Get all users
+	t.Run("Get all users", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/users", nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
+		testRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []models.User
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Len(t, response, 15)
+	})
+
+	// Test case 2: Get first page of users
 	t.Run("Get first page", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", "/api/users?page=1&limit=10", nil)
+		req, _ := http.NewRequest("GET", "/api/users/with-orders?page=1&limit=10", nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var response struct {
-			Users      []models.User `json:"users"`
+			Users []struct {
+				UserName string `json:"user_name"`
+			} `json:"users"`
 			Pagination struct {
-				CurrentPage   int   `json:"current_page"`
+				CurrentPage  int   `json:"current_page"`
 				TotalItems   int64 `json:"total_items"`
 				ItemsPerPage int   `json:"items_per_page"`
 				TotalPages   int64 `json:"total_pages"`
@@ -90,22 +114,25 @@ func TestGetUsers(t *testing.T) {
 		assert.Equal(t, int64(2), response.Pagination.TotalPages)
 	})
 
-	// Test case 2: Filter users by name
+	// Test case 3: Filter users by name
 	t.Run("Filter by name", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", "/api/users?name=User 1", nil)
+		req, _ := http.NewRequest("GET", "/api/users/with-orders?name=User%201", nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var response struct {
-			Users []models.User `json:"users"`
+			Users []struct {
+				UserName string `json:"user_name"`
+			} `json:"users"`
 		}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.NotEmpty(t, response.Users)
+		assert.Len(t, response.Users, 7) // User 1 and User 10-15
 		for _, user := range response.Users {
-			assert.Contains(t, user.Name, "User 1")
+			assert.Contains(t, user.UserName, "User 1")
 		}
 	})
 }
@@ -115,14 +142,15 @@ func TestGetUsersWithOrders(t *testing.T) {
 
 	// Create test user with orders
 	user := models.User{
-		Name:  "Test User",
-		Email: "test@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Email:     "test@example.com",
 	}
 	testDB.Create(&user)
 
 	product := models.Product{
 		Name:  "Test Product",
-		Price: 10.0,
+		Price: models.MustParseMoney("10.00"),
 		Stock: 100,
 	}
 	testDB.Create(&product)
@@ -130,12 +158,12 @@ func TestGetUsersWithOrders(t *testing.T) {
 	order := models.Order{
 		UserID: user.ID,
 		Status: models.OrderStatusPending,
-		Total:  20.0,
+		Total:  models.MustParseMoney("20.00"),
 		Items: []models.OrderItem{
 			{
 				ProductID: product.ID,
 				Quantity:  2,
-				Price:    10.0,
+				Price:     models.MustParseMoney("10.00"),
 			},
 		},
 	}
@@ -144,17 +172,18 @@ func TestGetUsersWithOrders(t *testing.T) {
 	t.Run("Get users with orders", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/api/users/with-orders", nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var response struct {
 			Users []struct {
-				UserID        uint     `json:"user_id"`
-				UserName      string   `json:"user_name"`
-				LastOrderID   *uint    `json:"last_order_id"`
-				OrderStatus   *string  `json:"order_status"`
-				OrderTotal    *float64 `json:"order_total"`
+				UserID      uuid.UUID     `json:"user_id"`
+				UserName    string        `json:"user_name"`
+				LastOrderID *uint         `json:"last_order_id"`
+				OrderStatus *string       `json:"order_status"`
+				OrderTotal  *models.Money `json:"order_total"`
 			} `json:"users"`
 		}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
@@ -163,7 +192,7 @@ func TestGetUsersWithOrders(t *testing.T) {
 
 		firstUser := response.Users[0]
 		assert.Equal(t, user.ID, firstUser.UserID)
-		assert.Equal(t, user.Name, firstUser.UserName)
+		assert.Equal(t, "Test User", firstUser.UserName)
 		assert.NotNil(t, firstUser.LastOrderID)
 		assert.NotNil(t, firstUser.OrderStatus)
 		assert.NotNil(t, firstUser.OrderTotal)
@@ -178,21 +207,25 @@ func TestUpdateUser(t *testing.T) {
 
 	// Create test user
 	user := models.User{
-		Name:  "Original Name",
-		Email: "original@example.com",
+		FirstName: "Original",
+		LastName:  "Name",
+		Email:     "original@example.com",
 	}
 	testDB.Create(&user)
 
 	t.Run("Valid update", func(t *testing.T) {
-		updatedUser := models.User{
-			ID:    user.ID,
-			Name:  "Updated Name",
-			Email: "updated@example.com",
+		updatedUser := models.UserInput{
+			Email:     "updated@example.com",
+			Password:  "secret123",
+			FirstName: "Updated",
+			LastName:  "Name",
+			Phone:     "+79990000000",
 		}
 		jsonValue, _ := json.Marshal(updatedUser)
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/users/%d", user.ID), bytes.NewBuffer(jsonValue))
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/users/%s", user.ID), bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
@@ -200,20 +233,24 @@ func TestUpdateUser(t *testing.T) {
 		var response models.User
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, updatedUser.Name, response.Name)
+		assert.Equal(t, updatedUser.FirstName, response.FirstName)
+		assert.Equal(t, updatedUser.LastName, response.LastName)
 		assert.Equal(t, updatedUser.Email, response.Email)
 	})
 
 	t.Run("Non-existent user", func(t *testing.T) {
-		updatedUser := models.User{
-			ID:    9999,
-			Name:  "Non-existent",
-			Email: "nonexistent@example.com",
+		updatedUser := models.UserInput{
+			Email:     "nonexistent@example.com",
+			Password:  "secret123",
+			FirstName: "Non",
+			LastName:  "Existent",
+			Phone:     "+79990000000",
 		}
 		jsonValue, _ := json.Marshal(updatedUser)
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("PUT", "/api/users/9999", bytes.NewBuffer(jsonValue))
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/users/%s", uuid.New()), bytes.NewBuffer(jsonValue))
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
@@ -225,28 +262,31 @@ func TestDeleteUser(t *testing.T) {
 
 	// Create test user
 	user := models.User{
-		Name:  "Test User",
-		Email: "test@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Email:     "test@example.com",
 	}
 	testDB.Create(&user)
 
 	t.Run("Valid deletion", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/users/%d", user.ID), nil)
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/users/%s", user.ID), nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNoContent, w.Code)
 
 		// Verify user is soft deleted
 		var deletedUser models.User
-		err := testDB.Unscoped().First(&deletedUser, user.ID).Error
+		err := testDB.Unscoped().First(&deletedUser, "id = ?", user.ID).Error
 		assert.NoError(t, err)
-		assert.NotNil(t, deletedUser.DeletedAt)
+		assert.True(t, deletedUser.DeletedAt.Valid)
 	})
 
 	t.Run("Non-existent user", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("DELETE", "/api/users/9999", nil)
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/users/%s", uuid.New()), nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
@@ -258,14 +298,15 @@ func TestGetUserOrderSummary(t *testing.T) {
 
 	// Create test user with multiple orders
 	user := models.User{
-		Name:  "Test User",
-		Email: "test@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Email:     "test@example.com",
 	}
 	testDB.Create(&user)
 
 	product := models.Product{
 		Name:  "Test Product",
-		Price: 10.0,
+		Price: models.MustParseMoney("10.00"),
 		Stock: 100,
 	}
 	testDB.Create(&product)
@@ -275,24 +316,24 @@ func TestGetUserOrderSummary(t *testing.T) {
 		{
 			UserID: user.ID,
 			Status: models.OrderStatusPaid,
-			Total:  20.0,
+			Total:  models.MustParseMoney("20.00"),
 			Items: []models.OrderItem{
 				{
 					ProductID: product.ID,
 					Quantity:  2,
-					Price:    10.0,
+					Price:     models.MustParseMoney("10.00"),
 				},
 			},
 		},
 		{
 			UserID: user.ID,
 			Status: models.OrderStatusDelivered,
-			Total:  30.0,
+			Total:  models.MustParseMoney("30.00"),
 			Items: []models.OrderItem{
 				{
 					ProductID: product.ID,
 					Quantity:  3,
-					Price:    10.0,
+					Price:     models.MustParseMoney("10.00"),
 				},
 			},
 		},
@@ -303,22 +344,23 @@ func TestGetUserOrderSummary(t *testing.T) {
 
 	t.Run("Get order summary", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/%d/orders/summary", user.ID), nil)
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/%s/orders/summary", user.ID), nil)
+		authorize(req, uuid.New(), models.RoleAdmin)
 		testRouter.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var response struct {
-			TotalOrders      int     `json:"total_orders"`
-			TotalSpent       float64 `json:"total_spent"`
-			AverageOrderValue float64 `json:"average_order_value"`
-			LastOrderDate    *string `json:"last_order_date"`
+			TotalOrders       int          `json:"total_orders"`
+			TotalSpent        models.Money `json:"total_spent"`
+			AverageOrderValue models.Money `json:"average_order_value"`
+			LastOrderDate     *string      `json:"last_order_date"`
 		}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
 		assert.Equal(t, 2, response.TotalOrders)
-		assert.Equal(t, 50.0, response.TotalSpent)
-		assert.Equal(t, 25.0, response.AverageOrderValue)
+		assert.Equal(t, models.MustParseMoney("50.00"), response.TotalSpent)
+		assert.Equal(t, models.MustParseMoney("25.00"), response.AverageOrderValue)
 		assert.NotNil(t, response.LastOrderDate)
 	})
-} 
\ No newline at end of file
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"os"
+	"time"
+)
+
+// DurationFromEnv parses a time.Duration from the environment, falling back
+// to def when the variable is unset, malformed or not positive
+func DurationFromEnv(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationFromEnv(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"":      time.Minute,
+		"90s":   90 * time.Second,
+		"soon":  time.Minute,
+		"0s":    time.Minute,
+		"-5m":   time.Minute,
+		"2h30m": 150 * time.Minute,
+	} {
+		t.Setenv("TEST_DURATION", value)
+		assert.Equal(t, want, DurationFromEnv("TEST_DURATION", time.Minute), "value %q", value)
+	}
+}
@@ -41,7 +41,7 @@ sleep 10
 
 # Run database migrations
 echo "Running database migrations..."
-docker-compose exec app ./main migrate
+docker-compose exec api ./main migrate up
 
 echo "Development environment initialized successfully!"
 echo "Access the application at https://localhost"